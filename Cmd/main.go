@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"log"
 
 	routers "loan-tracker/Delivery/Routers"
 	infrastructure "loan-tracker/Infrastructure"
@@ -16,6 +17,15 @@ func main() {
 	if err != nil {
 		fmt.Print("Error in env.load")
 	}
+	// Client IPs are read from X-Forwarded-For only when the request comes through one of our own
+	// proxies; otherwise a client could pick the IP its login attempts are counted under.
+	var trustedProxies []string
+	if len(config.TrustedProxies) > 0 {
+		trustedProxies = config.TrustedProxies
+	}
+	if err := server.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatal(err)
+	}
 	db := infrastructure.NewDatabase()
 	
 	routers.Routers(server, db, config)
@@ -1,10 +1,13 @@
 package controllers
 
 import (
+	"errors"
 	domain "loan-tracker/Domain"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
 )
 
 type AdminControllers struct{
@@ -68,7 +71,7 @@ func (ac *AdminControllers) DeleteUser(c *gin.Context){
 			Status:  500,
 		})
 	}
-	deleted, err := ac.AdminUseCase.DeleteUser(id, user_id)
+	deleted, err := ac.AdminUseCase.DeleteUser(id, c.Query("reason"), user_id, clientInfo(c))
 	if err != nil{
 		c.JSON(400, domain.ErrorResponse{
 			Message: err.Error(),
@@ -119,3 +122,622 @@ func (ac *AdminControllers) GetAllLoans(c *gin.Context){
 	})
 }
 
+
+func (ac *AdminControllers) RevokeUserSessions(c *gin.Context){
+	id := c.Param("id")
+	if id == ""{
+		c.JSON(400, domain.ErrorResponse{
+			Message: "id is required",
+			Status: 400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	err := ac.AdminUseCase.RevokeUserSessions(id, user_id, clientInfo(c))
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "User sessions revoked successfully",
+		Status: 200,
+	})
+}
+
+
+func (ac *AdminControllers) UnlockUser(c *gin.Context){
+	id := c.Param("id")
+	if id == ""{
+		c.JSON(400, domain.ErrorResponse{
+			Message: "id is required",
+			Status: 400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	err := ac.AdminUseCase.UnlockUser(id, user_id, clientInfo(c))
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "User account unlocked successfully",
+		Status: 200,
+	})
+}
+
+
+func (ac *AdminControllers) GetUser(c *gin.Context){
+	id := c.Param("id")
+	if id == ""{
+		c.JSON(400, domain.ErrorResponse{
+			Message: "id is required",
+			Status: 400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	detail, err := ac.AdminUseCase.GetUser(id, user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "success",
+		Data: detail,
+		Status: 200,
+	})
+}
+
+
+// SearchUsers filters users by email, name, status and registration date (from/to as YYYY-MM-DD).
+func (ac *AdminControllers) SearchUsers(c *gin.Context){
+	pageNo := c.Query("pageNo")
+	pageSize := c.Query("pageSize")
+
+	if pageNo == ""{
+		pageNo = "1"
+	}
+	if pageSize == ""{
+		pageSize = "10"
+	}
+	filter := domain.UserSearchFilter{
+		Email: strings.TrimSpace(c.Query("email")),
+		Name: strings.TrimSpace(c.Query("name")),
+		Status: strings.ToLower(c.Query("status")),
+	}
+	if from := c.Query("from"); from != ""{
+		date, err := time.Parse("2006-01-02", from)
+		if err != nil{
+			c.JSON(400, domain.ErrorResponse{
+				Message: "from must be a date in YYYY-MM-DD format",
+				Status: 400,
+			})
+			return
+		}
+		filter.RegisteredAfter = date
+	}
+	if to := c.Query("to"); to != ""{
+		date, err := time.Parse("2006-01-02", to)
+		if err != nil{
+			c.JSON(400, domain.ErrorResponse{
+				Message: "to must be a date in YYYY-MM-DD format",
+				Status: 400,
+			})
+			return
+		}
+		// The end date is inclusive.
+		filter.RegisteredBefore = date.AddDate(0, 0, 1)
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	users, err := ac.AdminUseCase.SearchUsers(filter, pageNo, pageSize, user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "success",
+		Data: users,
+		Status: 200,
+	})
+}
+
+
+func (ac *AdminControllers) SuspendUser(c *gin.Context){
+	id := c.Param("id")
+	if id == ""{
+		c.JSON(400, domain.ErrorResponse{
+			Message: "id is required",
+			Status: 400,
+		})
+		return
+	}
+	var request domain.SuspendUserRequest
+	err := c.BindJSON(&request)
+	if err != nil || validator.New().Struct(request) != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Invalid request",
+			Status: 400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	err = ac.AdminUseCase.SuspendUser(id, request.Reason, user_id, clientInfo(c))
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "User suspended successfully",
+		Status: 200,
+	})
+}
+
+
+func (ac *AdminControllers) ReactivateUser(c *gin.Context){
+	id := c.Param("id")
+	if id == ""{
+		c.JSON(400, domain.ErrorResponse{
+			Message: "id is required",
+			Status: 400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	err := ac.AdminUseCase.ReactivateUser(id, user_id, clientInfo(c))
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "User reactivated successfully",
+		Status: 200,
+	})
+}
+
+
+func (ac *AdminControllers) ForceVerifyUser(c *gin.Context){
+	id := c.Param("id")
+	if id == ""{
+		c.JSON(400, domain.ErrorResponse{
+			Message: "id is required",
+			Status: 400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	err := ac.AdminUseCase.ForceVerifyUser(id, user_id, clientInfo(c))
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "User verified successfully",
+		Status: 200,
+	})
+}
+
+
+func (ac *AdminControllers) TriggerPasswordReset(c *gin.Context){
+	id := c.Param("id")
+	if id == ""{
+		c.JSON(400, domain.ErrorResponse{
+			Message: "id is required",
+			Status: 400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	err := ac.AdminUseCase.TriggerPasswordReset(id, user_id, clientInfo(c))
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Password reset email sent",
+		Status: 200,
+	})
+}
+
+
+func (ac *AdminControllers) ChangeUserRole(c *gin.Context){
+	id := c.Param("id")
+	if id == ""{
+		c.JSON(400, domain.ErrorResponse{
+			Message: "id is required",
+			Status: 400,
+		})
+		return
+	}
+	var request domain.ChangeRoleRequest
+	err := c.BindJSON(&request)
+	if err != nil || validator.New().Struct(request) != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Invalid request",
+			Status: 400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	err = ac.AdminUseCase.ChangeUserRole(id, request.Role, user_id, clientInfo(c))
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "User role updated successfully",
+		Status: 200,
+	})
+}
+
+
+func (ac *AdminControllers) RestoreUser(c *gin.Context){
+	id := c.Param("id")
+	if id == ""{
+		c.JSON(400, domain.ErrorResponse{
+			Message: "id is required",
+			Status: 400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	err := ac.AdminUseCase.RestoreUser(id, user_id, clientInfo(c))
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "User restored successfully",
+		Status: 200,
+	})
+}
+
+
+func (ac *AdminControllers) ListEmailTemplates(c *gin.Context){
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	templates, err := ac.AdminUseCase.ListEmailTemplates(user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "success",
+		Data: templates,
+		Status: 200,
+	})
+}
+
+
+// PreviewEmail renders a template with sample values. With ?format=html or ?format=text the
+// rendered body is returned as is, so it can be opened directly in a browser.
+func (ac *AdminControllers) PreviewEmail(c *gin.Context){
+	name := c.Param("name")
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	preview, err := ac.AdminUseCase.PreviewEmail(name, c.Query("locale"), user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	switch c.Query("format"){
+	case "html":
+		c.Data(200, "text/html; charset=utf-8", []byte(preview.HTML))
+	case "text":
+		c.Data(200, "text/plain; charset=utf-8", []byte(preview.Text))
+	default:
+		c.JSON(200, domain.SuccessResponse{
+			Message: "success",
+			Data: preview,
+			Status: 200,
+		})
+	}
+}
+
+
+func (ac *AdminControllers) ListDeliveries(c *gin.Context){
+	pageNo := c.Query("pageNo")
+	pageSize := c.Query("pageSize")
+
+	if pageNo == ""{
+		pageNo = "1"
+	}
+	if pageSize == ""{
+		pageSize = "10"
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	messages, err := ac.AdminUseCase.ListDeliveries(c.Query("status"), pageNo, pageSize, user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "success",
+		Data: messages,
+		Status: 200,
+	})
+}
+
+
+func (ac *AdminControllers) RetryDelivery(c *gin.Context){
+	id := c.Param("id")
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	err := ac.AdminUseCase.RetryDelivery(id, user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Delivery queued for retry",
+		Status: 200,
+	})
+}
+
+
+func (ac *AdminControllers) ApproveLoan(c *gin.Context){
+	id := c.Param("id")
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	err := ac.LoanUseCase.ApproveLoan(id, user_id, clientInfo(c))
+	if errors.Is(err, domain.ErrLoanChanged){
+		c.JSON(409, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 409,
+		})
+		return
+	}
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Loan approved successfully",
+		Status: 200,
+	})
+}
+
+
+func (ac *AdminControllers) RejectLoan(c *gin.Context){
+	id := c.Param("id")
+	var request domain.RejectLoanRequest
+	err := c.BindJSON(&request)
+	if err != nil || validator.New().Struct(request) != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Invalid request",
+			Status: 400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	err = ac.LoanUseCase.RejectLoan(id, request.Reason, user_id, clientInfo(c))
+	if errors.Is(err, domain.ErrLoanChanged){
+		c.JSON(409, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 409,
+		})
+		return
+	}
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Loan rejected successfully",
+		Status: 200,
+	})
+}
+
+
+func (ac *AdminControllers) DisburseLoan(c *gin.Context){
+	id := c.Param("id")
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	err := ac.LoanUseCase.DisburseLoan(id, user_id, clientInfo(c))
+	if errors.Is(err, domain.ErrLoanChanged){
+		c.JSON(409, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 409,
+		})
+		return
+	}
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Loan disbursed successfully",
+		Status: 200,
+	})
+}
+
+
+func (ac *AdminControllers) GetDelivery(c *gin.Context){
+	id := c.Param("id")
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	message, err := ac.AdminUseCase.GetDelivery(id, user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "success",
+		Data: message,
+		Status: 200,
+	})
+}
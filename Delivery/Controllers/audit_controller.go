@@ -0,0 +1,211 @@
+package controllers
+
+import (
+	"encoding/csv"
+	domain "loan-tracker/Domain"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var auditCSVHeader = []string{"sequence", "created_at", "category", "action", "actor_id", "target_type", "target_id", "changes", "ip", "user_agent", "request_id", "prev_hash", "hash"}
+
+type AuditControllers struct{
+	AuditUseCase domain.AuditUseCaseInterface
+}
+
+func NewAuditControllers(auditUseCase domain.AuditUseCaseInterface) *AuditControllers {
+	return &AuditControllers{
+		AuditUseCase: auditUseCase,
+	}
+}
+
+
+// auditFilter reads the audit log filters from the query string (from/to as YYYY-MM-DD).
+func auditFilter(c *gin.Context) (domain.AuditFilter, string){
+	filter := domain.AuditFilter{
+		Category: strings.ToLower(c.Query("category")),
+		Action: strings.TrimSpace(c.Query("action")),
+		ActorID: strings.TrimSpace(c.Query("actor_id")),
+		TargetType: strings.TrimSpace(c.Query("target_type")),
+		TargetID: strings.TrimSpace(c.Query("target_id")),
+		RequestID: strings.TrimSpace(c.Query("request_id")),
+	}
+	if from := c.Query("from"); from != ""{
+		date, err := time.Parse("2006-01-02", from)
+		if err != nil{
+			return filter, "from must be a date in YYYY-MM-DD format"
+		}
+		filter.From = date
+	}
+	if to := c.Query("to"); to != ""{
+		date, err := time.Parse("2006-01-02", to)
+		if err != nil{
+			return filter, "to must be a date in YYYY-MM-DD format"
+		}
+		// The end date is inclusive.
+		filter.To = date.AddDate(0, 0, 1)
+	}
+	return filter, ""
+}
+
+
+func (ac *AuditControllers) GetRecords(c *gin.Context){
+	pageNo := c.Query("pageNo")
+	pageSize := c.Query("pageSize")
+
+	if pageNo == ""{
+		pageNo = "1"
+	}
+	if pageSize == ""{
+		pageSize = "10"
+	}
+	filter, message := auditFilter(c)
+	if message != ""{
+		c.JSON(400, domain.ErrorResponse{
+			Message: message,
+			Status: 400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	records, err := ac.AuditUseCase.GetRecords(filter, pageNo, pageSize, user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "success",
+		Data: records,
+		Status: 200,
+	})
+}
+
+
+// ExportRecords streams the matching records as CSV, oldest first. The response only starts with
+// the first record, so a refused export is still answered with a JSON error.
+func (ac *AuditControllers) ExportRecords(c *gin.Context){
+	filter, message := auditFilter(c)
+	if message != ""{
+		c.JSON(400, domain.ErrorResponse{
+			Message: message,
+			Status: 400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	writer := csv.NewWriter(c.Writer)
+	started := false
+	start := func() error{
+		started = true
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="audit-log.csv"`)
+		c.Status(200)
+		return writer.Write(auditCSVHeader)
+	}
+	err := ac.AuditUseCase.ExportRecords(filter, user_id, func(record domain.AuditRecord) error{
+		if !started{
+			if err := start(); err != nil{
+				return err
+			}
+		}
+		return writer.Write(auditCSVRow(record))
+	})
+	if err != nil && !started{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	if err != nil{
+		// The status line is already sent: the truncated file is all the client gets.
+		log.Println("error exporting audit log:", err)
+	}
+	if !started{
+		start()
+	}
+	writer.Flush()
+}
+
+
+func (ac *AuditControllers) VerifyChain(c *gin.Context){
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	verification, err := ac.AuditUseCase.VerifyChain(user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "success",
+		Data: verification,
+		Status: 200,
+	})
+}
+
+
+func auditCSVRow(record domain.AuditRecord) []string{
+	changes := make([]string, len(record.Changes))
+	for i, change := range record.Changes{
+		changes[i] = change.Field + ": " + change.Before + " -> " + change.After
+	}
+	row := []string{
+		strconv.FormatInt(record.Sequence, 10),
+		record.Created_At.UTC().Format(time.RFC3339),
+		record.Category,
+		record.Action,
+		record.ActorID,
+		record.TargetType,
+		record.TargetID,
+		strings.Join(changes, "; "),
+		record.IP,
+		record.UserAgent,
+		record.RequestID,
+		record.PrevHash,
+		record.Hash,
+	}
+	for i, value := range row{
+		row[i] = csvSafe(value)
+	}
+	return row
+}
+
+
+// csvSafe keeps spreadsheets from evaluating a value that a user controls, such as a user agent,
+// as a formula.
+func csvSafe(value string) string{
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])){
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	domain "loan-tracker/Domain"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type DataJobControllers struct{
+	DataJobUseCase domain.DataJobUseCaseInterface
+}
+
+func NewDataJobControllers(dataJobUseCase domain.DataJobUseCaseInterface) *DataJobControllers {
+	return &DataJobControllers{
+		DataJobUseCase: dataJobUseCase,
+	}
+}
+
+// RequestExport queues an export of the user's data. Poll the returned job until it is completed.
+func (dc *DataJobControllers) RequestExport(c *gin.Context){
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	job, err := dc.DataJobUseCase.RequestExport(user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status:  400,
+		})
+		return
+	}
+	c.JSON(202, domain.SuccessResponse{
+		Message: "Export queued",
+		Data: job,
+		Status:  202,
+	})
+}
+
+
+func (dc *DataJobControllers) GetExports(c *gin.Context){
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	jobs, err := dc.DataJobUseCase.GetExports(user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status:  400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "success",
+		Data: jobs,
+		Status:  200,
+	})
+}
+
+
+func (dc *DataJobControllers) GetExport(c *gin.Context){
+	id := c.Param("id")
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	job, err := dc.DataJobUseCase.GetExport(id, user_id)
+	if err != nil{
+		c.JSON(404, domain.ErrorResponse{
+			Message: err.Error(),
+			Status:  404,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "success",
+		Data: job,
+		Status:  200,
+	})
+}
+
+
+func (dc *DataJobControllers) DownloadExport(c *gin.Context){
+	id := c.Param("id")
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	path, err := dc.DataJobUseCase.GetExportFile(id, user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status:  400,
+		})
+		return
+	}
+	c.FileAttachment(path, "loan-tracker-export-"+id+".zip")
+}
+
+
+func (dc *DataJobControllers) RequestErasure(c *gin.Context){
+	var request domain.ErasureRequest
+	if err := c.BindJSON(&request); err != nil || validator.New().Struct(request) != nil {
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Invalid request",
+			Status:  400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	job, err := dc.DataJobUseCase.RequestErasure(user_id, request.Password)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status:  400,
+		})
+		return
+	}
+	c.JSON(202, domain.SuccessResponse{
+		Message: "Erasure queued. You will be signed out once it completes",
+		Data: job,
+		Status:  202,
+	})
+}
+
+
+func (dc *DataJobControllers) GetErasure(c *gin.Context){
+	id := c.Param("id")
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	job, err := dc.DataJobUseCase.GetErasure(id, user_id)
+	if err != nil{
+		c.JSON(404, domain.ErrorResponse{
+			Message: err.Error(),
+			Status:  404,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "success",
+		Data: job,
+		Status:  200,
+	})
+}
@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	domain "loan-tracker/Domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+type InboxControllers struct{
+	InboxUseCase domain.InboxUseCaseInterface
+}
+
+func NewInboxControllers(inboxUseCase domain.InboxUseCaseInterface) *InboxControllers {
+	return &InboxControllers{
+		InboxUseCase: inboxUseCase,
+	}
+}
+
+// GetNotifications lists the user's notifications, newest first. Pass unread=true to only list
+// the unread ones.
+func (ic *InboxControllers) GetNotifications(c *gin.Context){
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	pageNo := c.Query("pageNo")
+	pageSize := c.Query("pageSize")
+	if pageNo == ""{
+		pageNo = "1"
+	}
+	if pageSize == ""{
+		pageSize = "10"
+	}
+	page, err := ic.InboxUseCase.GetNotifications(user_id, c.Query("unread") == "true", pageNo, pageSize)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status:  400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Notifications",
+		Data: page,
+		Status:  200,
+	})
+}
+
+
+func (ic *InboxControllers) CountUnread(c *gin.Context){
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	unread, err := ic.InboxUseCase.CountUnread(user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status:  400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Unread notifications",
+		Data: map[string]int64{"unread": unread},
+		Status:  200,
+	})
+}
+
+
+func (ic *InboxControllers) MarkRead(c *gin.Context){
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	err := ic.InboxUseCase.MarkRead(c.Param("id"), user_id)
+	if err != nil{
+		c.JSON(404, domain.ErrorResponse{
+			Message: err.Error(),
+			Status:  404,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Notification marked as read",
+		Status:  200,
+	})
+}
+
+
+func (ic *InboxControllers) MarkAllRead(c *gin.Context){
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	count, err := ic.InboxUseCase.MarkAllRead(user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status:  400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Notifications marked as read",
+		Data: map[string]int64{"marked": count},
+		Status:  200,
+	})
+}
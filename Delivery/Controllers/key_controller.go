@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	domain "loan-tracker/Domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+type KeyControllers struct{
+	KeySet domain.KeySetProviderInterface
+}
+
+func NewKeyControllers(keySet domain.KeySetProviderInterface) *KeyControllers {
+	return &KeyControllers{
+		KeySet: keySet,
+	}
+}
+
+// JWKS publishes the public keys that verify our tokens. It is served as a bare JWK Set
+// rather than a SuccessResponse so standard JOSE libraries can consume it directly.
+func (kc *KeyControllers) JWKS(c *gin.Context){
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(200, kc.KeySet.JWKS())
+}
@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"errors"
+	domain "loan-tracker/Domain"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// maxPaymentNotificationBytes caps the body a payment provider may post.
+const maxPaymentNotificationBytes = 64 << 10
+
+type PaymentControllers struct{
+	PaymentUseCase domain.PaymentUseCaseInterface
+}
+
+func NewPaymentControllers(paymentUseCase domain.PaymentUseCaseInterface) *PaymentControllers {
+	return &PaymentControllers{
+		PaymentUseCase: paymentUseCase,
+	}
+}
+
+// ReceivePayment accepts a payment notification from the provider named in the URL. Any response
+// other than 2xx asks the provider to send the notification again, so only failures of ours are
+// reported as 500.
+func (pc *PaymentControllers) ReceivePayment(c *gin.Context){
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxPaymentNotificationBytes)
+	body, err := c.GetRawData()
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Invalid request",
+			Status:  400,
+		})
+		return
+	}
+	payment, err := pc.PaymentUseCase.ReceivePayment(c.Param("provider"), c.Request.Header, body)
+	if err != nil{
+		status := 500
+		message := "error processing payment"
+		switch {
+		case errors.Is(err, domain.ErrUnknownPaymentProvider):
+			status, message = 404, err.Error()
+		case errors.Is(err, domain.ErrInvalidPaymentSignature):
+			status, message = 401, err.Error()
+		case errors.Is(err, domain.ErrInvalidPaymentNotification):
+			status, message = 400, err.Error()
+		}
+		c.JSON(status, domain.ErrorResponse{
+			Message: message,
+			Status:  status,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Payment " + payment.Status,
+		Data: map[string]string{"id": payment.ID.Hex(), "status": payment.Status},
+		Status:  200,
+	})
+}
+
+
+func (pc *PaymentControllers) GetPayments(c *gin.Context){
+	pageNo := c.Query("pageNo")
+	pageSize := c.Query("pageSize")
+
+	if pageNo == ""{
+		pageNo = "1"
+	}
+	if pageSize == ""{
+		pageSize = "10"
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	payments, err := pc.PaymentUseCase.GetPayments(c.Query("status"), pageNo, pageSize, user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "success",
+		Data: payments,
+		Status: 200,
+	})
+}
+
+
+func (pc *PaymentControllers) GetPayment(c *gin.Context){
+	id := c.Param("id")
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	payment, err := pc.PaymentUseCase.GetPayment(id, user_id)
+	if err != nil{
+		c.JSON(404, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 404,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "success",
+		Data: payment,
+		Status: 200,
+	})
+}
+
+
+func (pc *PaymentControllers) AssignPayment(c *gin.Context){
+	var request domain.AssignPaymentRequest
+	err := c.BindJSON(&request)
+	if err != nil || validator.New().Struct(request) != nil {
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Invalid request",
+			Status:  400,
+		})
+		return
+	}
+	id := c.Param("id")
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	payment, err := pc.PaymentUseCase.AssignPayment(id, request.LoanID, user_id, clientInfo(c))
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Payment posted to the loan",
+		Data: payment,
+		Status: 200,
+	})
+}
+
+
+// SimulatePayment sends a payment through the local simulator, for development.
+func (pc *PaymentControllers) SimulatePayment(c *gin.Context){
+	var request domain.SimulatePaymentRequest
+	err := c.BindJSON(&request)
+	if err != nil || validator.New().Struct(request) != nil {
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Invalid request",
+			Status:  400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	payment, err := pc.PaymentUseCase.SimulatePayment(request, user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Payment " + payment.Status,
+		Data: payment,
+		Status: 200,
+	})
+}
@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"encoding/csv"
+	domain "loan-tracker/Domain"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReportControllers struct{
+	ReportUseCase domain.ReportUseCaseInterface
+}
+
+func NewReportControllers(reportUseCase domain.ReportUseCaseInterface) *ReportControllers {
+	return &ReportControllers{
+		ReportUseCase: reportUseCase,
+	}
+}
+
+
+// reportFilter reads the product, grouping and date range (from/to as YYYY-MM-DD) of a report.
+func reportFilter(c *gin.Context) (domain.ReportFilter, string){
+	filter := domain.ReportFilter{
+		Product: strings.TrimSpace(c.Query("product")),
+		GroupBy: strings.ToLower(c.Query("group_by")),
+	}
+	if from := c.Query("from"); from != ""{
+		date, err := time.Parse("2006-01-02", from)
+		if err != nil{
+			return filter, "from must be a date in YYYY-MM-DD format"
+		}
+		filter.From = date
+	}
+	if to := c.Query("to"); to != ""{
+		date, err := time.Parse("2006-01-02", to)
+		if err != nil{
+			return filter, "to must be a date in YYYY-MM-DD format"
+		}
+		// The end date is inclusive.
+		filter.To = date.AddDate(0, 0, 1)
+	}
+	return filter, ""
+}
+
+
+// report answers a report request with the rows get returns for the filter.
+func report[T any](c *gin.Context, get func(filter domain.ReportFilter, user_id string) (T, error)){
+	filter, message := reportFilter(c)
+	if message != ""{
+		c.JSON(400, domain.ErrorResponse{
+			Message: message,
+			Status: 400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	data, err := get(filter, user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "success",
+		Data: data,
+		Status: 200,
+	})
+}
+
+
+func (rc *ReportControllers) GetDashboard(c *gin.Context){
+	report(c, rc.ReportUseCase.GetDashboard)
+}
+
+
+func (rc *ReportControllers) GetLoanActivity(c *gin.Context){
+	report(c, rc.ReportUseCase.GetLoanActivity)
+}
+
+
+func (rc *ReportControllers) GetPortfolio(c *gin.Context){
+	report(c, rc.ReportUseCase.GetPortfolio)
+}
+
+
+func (rc *ReportControllers) GetCollections(c *gin.Context){
+	report(c, rc.ReportUseCase.GetCollections)
+}
+
+
+func (rc *ReportControllers) GetVintages(c *gin.Context){
+	report(c, rc.ReportUseCase.GetVintages)
+}
+
+
+// ExportVintages downloads the vintage report as CSV, one row per cohort and month on book.
+func (rc *ReportControllers) ExportVintages(c *gin.Context){
+	filter, message := reportFilter(c)
+	if message != ""{
+		c.JSON(400, domain.ErrorResponse{
+			Message: message,
+			Status: 400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	vintages, err := rc.ReportUseCase.GetVintages(filter, user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="vintages.csv"`)
+	c.Status(200)
+	writer := csv.NewWriter(c.Writer)
+	writer.Write([]string{"cohort", "loans", "disbursed_volume", "month_on_book", "observed_at", "repayment_rate", "delinquent_30_rate", "delinquent_90_rate", "outstanding_principal"})
+	for _, cohort := range vintages.Cohorts{
+		for _, point := range cohort.Points{
+			writer.Write([]string{
+				cohort.Cohort,
+				strconv.Itoa(cohort.Loans),
+				strconv.FormatFloat(cohort.DisbursedVolume, 'f', 2, 64),
+				strconv.Itoa(point.MonthOnBook),
+				point.ObservedAt.Format("2006-01-02"),
+				strconv.FormatFloat(point.RepaymentRate, 'f', 4, 64),
+				strconv.FormatFloat(point.Delinquent30Rate, 'f', 4, 64),
+				strconv.FormatFloat(point.Delinquent90Rate, 'f', 4, 64),
+				strconv.FormatFloat(point.OutstandingPrincipal, 'f', 2, 64),
+			})
+		}
+	}
+	writer.Flush()
+}
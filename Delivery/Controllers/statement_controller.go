@@ -0,0 +1,253 @@
+package controllers
+
+import (
+	"io"
+	domain "loan-tracker/Domain"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// maxStatementBytes caps the size of an uploaded statement.
+const maxStatementBytes = 5 << 20
+
+type StatementControllers struct{
+	StatementUseCase domain.StatementUseCaseInterface
+}
+
+func NewStatementControllers(statementUseCase domain.StatementUseCaseInterface) *StatementControllers {
+	return &StatementControllers{
+		StatementUseCase: statementUseCase,
+	}
+}
+
+
+func (sc *StatementControllers) GetMappings(c *gin.Context){
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	mappings, err := sc.StatementUseCase.GetMappings(user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "success",
+		Data: mappings,
+		Status: 200,
+	})
+}
+
+
+func (sc *StatementControllers) SaveMapping(c *gin.Context){
+	var request domain.StatementMapping
+	err := c.BindJSON(&request)
+	if err != nil || validator.New().Struct(request) != nil {
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Invalid request",
+			Status:  400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	mapping, err := sc.StatementUseCase.SaveMapping(c.Param("bank"), request, user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Mapping saved",
+		Data: mapping,
+		Status: 200,
+	})
+}
+
+
+func (sc *StatementControllers) DeleteMapping(c *gin.Context){
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	err := sc.StatementUseCase.DeleteMapping(c.Param("bank"), user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Mapping deleted",
+		Status: 200,
+	})
+}
+
+
+// ImportStatement takes a multipart upload with the statement in "file" and the bank in "bank".
+func (sc *StatementControllers) ImportStatement(c *gin.Context){
+	bank := c.PostForm("bank")
+	header, err := c.FormFile("file")
+	if err != nil || bank == "" || header.Size > maxStatementBytes {
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Invalid request",
+			Status:  400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	file, err := header.Open()
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Invalid request",
+			Status:  400,
+		})
+		return
+	}
+	defer file.Close()
+	content, err := io.ReadAll(io.LimitReader(file, maxStatementBytes))
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Invalid request",
+			Status:  400,
+		})
+		return
+	}
+	statement, err := sc.StatementUseCase.ImportStatement(bank, header.Filename, content, user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Statement " + statement.Status,
+		Data: statement,
+		Status: 200,
+	})
+}
+
+
+func (sc *StatementControllers) GetImports(c *gin.Context){
+	pageNo := c.Query("pageNo")
+	pageSize := c.Query("pageSize")
+
+	if pageNo == ""{
+		pageNo = "1"
+	}
+	if pageSize == ""{
+		pageSize = "10"
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	statements, err := sc.StatementUseCase.GetImports(pageNo, pageSize, user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "success",
+		Data: statements,
+		Status: 200,
+	})
+}
+
+
+func (sc *StatementControllers) GetImport(c *gin.Context){
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	statement, err := sc.StatementUseCase.GetImport(c.Param("id"), user_id)
+	if err != nil{
+		c.JSON(404, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 404,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "success",
+		Data: statement,
+		Status: 200,
+	})
+}
+
+
+// ConfirmImport posts the listed lines, or every matched line when the body has none.
+func (sc *StatementControllers) ConfirmImport(c *gin.Context){
+	var request domain.ConfirmStatementRequest
+	if c.Request.ContentLength != 0 {
+		err := c.BindJSON(&request)
+		if err != nil || validator.New().Struct(request) != nil {
+			c.JSON(400, domain.ErrorResponse{
+				Message: "Invalid request",
+				Status:  400,
+			})
+			return
+		}
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	statement, err := sc.StatementUseCase.ConfirmImport(c.Param("id"), request, user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Statement confirmed",
+		Data: statement,
+		Status: 200,
+	})
+}
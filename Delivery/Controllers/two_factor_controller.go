@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	domain "loan-tracker/Domain"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+func (uc *UserControllers) EnrollTwoFactor(c *gin.Context){
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	enrollment, err := uc.userUserCase.EnrollTwoFactor(user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status:  400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Scan the provisioning URI with your authenticator app and confirm with a code",
+		Data: enrollment,
+		Status:  200,
+	})
+}
+
+
+func (uc *UserControllers) ConfirmTwoFactor(c *gin.Context){
+	var request domain.TwoFactorCodeRequest
+	if err := c.BindJSON(&request); err != nil || validator.New().Struct(request) != nil {
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Invalid request",
+			Status:  400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	codes, err := uc.userUserCase.ConfirmTwoFactor(user_id, c.GetString("session_id"), request.Code, clientInfo(c))
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status:  400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Two-factor authentication enabled. Store the recovery codes somewhere safe",
+		Data: map[string][]string{
+			"recovery_codes": codes,
+		},
+		Status:  200,
+	})
+}
+
+
+func (uc *UserControllers) DisableTwoFactor(c *gin.Context){
+	var request domain.TwoFactorCodeRequest
+	if err := c.BindJSON(&request); err != nil || validator.New().Struct(request) != nil {
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Invalid request",
+			Status:  400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	err := uc.userUserCase.DisableTwoFactor(user_id, request.Code, clientInfo(c))
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status:  400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Two-factor authentication disabled",
+		Status:  200,
+	})
+}
+
+
+func (uc *UserControllers) RegenerateRecoveryCodes(c *gin.Context){
+	var request domain.TwoFactorCodeRequest
+	if err := c.BindJSON(&request); err != nil || validator.New().Struct(request) != nil {
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Invalid request",
+			Status:  400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	codes, err := uc.userUserCase.RegenerateRecoveryCodes(user_id, request.Code, clientInfo(c))
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status:  400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Recovery codes regenerated",
+		Data: map[string][]string{
+			"recovery_codes": codes,
+		},
+		Status:  200,
+	})
+}
+
+
+func (uc *UserControllers) VerifyTwoFactorLogin(c *gin.Context){
+	var request domain.TwoFactorLoginRequest
+	if err := c.BindJSON(&request); err != nil || validator.New().Struct(request) != nil {
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Invalid request",
+			Status:  400,
+		})
+		return
+	}
+	client := clientInfo(c)
+	client.Device = request.Device
+	response, err := uc.userUserCase.VerifyTwoFactorLogin(request.ChallengeToken, request.Code, client)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status:  400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "User Logged in successfully",
+		Data: response,
+		Status:  200,
+	})
+}
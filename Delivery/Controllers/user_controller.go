@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	domain "loan-tracker/Domain"
 
@@ -47,6 +48,7 @@ func (uc *UserControllers) RegisterUser(c *gin.Context){
 		fmt.Println(err.Error())
 		c.JSON(400, domain.ErrorResponse{
 			Message: err.Error(),
+			Data: passwordViolations(err),
 			Status:  400,
 		})
 		return
@@ -71,7 +73,7 @@ func (uc *UserControllers)VerifyEmail(c *gin.Context){
 		return
 	}
 
-	err := uc.userUserCase.VerifyEmail(email, token)
+	err := uc.userUserCase.VerifyEmail(email, token, c.Query("expires"), c.Query("signature"))
 	if err != nil{
 		c.JSON(400, domain.ErrorResponse{
 			Message: err.Error(),
@@ -109,7 +111,9 @@ func (uc *UserControllers)Login(c *gin.Context){
 		return
 	}
 	copier.Copy(&user, &loginRequest)
-	response, err := uc.userUserCase.Login(user)
+	client := clientInfo(c)
+	client.Device = loginRequest.Device
+	response, err := uc.userUserCase.Login(user, client)
 	if err != nil{
 		c.JSON(400, domain.ErrorResponse{
 			Message: err.Error(),
@@ -194,14 +198,7 @@ func (uc *UserControllers)ResetPassword(c *gin.Context){
 		})
 		return
 	}
-	user_id := c.GetString("user_id")
-	if user_id == "" {
-		c.JSON(500, domain.ErrorResponse{
-			Message: "Unauthorized: Authorization header required",
-			Status:  500,
-		})
-	}
-	err = uc.userUserCase.ResetPassword(request.Email, user_id)
+	err = uc.userUserCase.ResetPassword(request.Email)
 	if err != nil{
 		c.JSON(400, domain.ErrorResponse{
 			Message: err.Error(),
@@ -210,7 +207,7 @@ func (uc *UserControllers)ResetPassword(c *gin.Context){
 		return
 	}
 	c.JSON(200, domain.SuccessResponse{
-		Message: "Password reset link sent successfully",
+		Message: "If an account exists for this email, a password reset link has been sent",
 		Status:  200,
 	})
 }
@@ -237,14 +234,56 @@ func (uc *UserControllers) ResetPasswordVerify(c *gin.Context){
 		return 
 	}
 
+	err = uc.userUserCase.ResetPasswordVerify(email, token, newPassword.NewPassword, clientInfo(c))
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Data: passwordViolations(err),
+			Status:  400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Password updated successfully",
+		Status:  200,})
+}
+
+
+func (uc *UserControllers) Logout(c *gin.Context){
+	user_id := c.GetString("user_id")
+	session_id := c.GetString("session_id")
+	if user_id == "" || session_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	err := uc.userUserCase.Logout(session_id, user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status:  400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "User logged out successfully",
+		Status:  200,
+	})
+}
+
+
+func (uc *UserControllers) GetSessions(c *gin.Context){
 	user_id := c.GetString("user_id")
 	if user_id == "" {
 		c.JSON(500, domain.ErrorResponse{
 			Message: "Unauthorized: Authorization header required",
 			Status:  500,
 		})
+		return
 	}
-	err = uc.userUserCase.ResetPasswordVerify(email, token, user_id, newPassword.NewPassword)
+	sessions, err := uc.userUserCase.GetSessions(user_id, c.GetString("session_id"))
 	if err != nil{
 		c.JSON(400, domain.ErrorResponse{
 			Message: err.Error(),
@@ -253,6 +292,406 @@ func (uc *UserControllers) ResetPasswordVerify(c *gin.Context){
 		return
 	}
 	c.JSON(200, domain.SuccessResponse{
-		Message: "Password updated successfully",
-		Status:  200,})
+		Message: "Active sessions",
+		Data: sessions,
+		Status:  200,
+	})
+}
+
+
+func (uc *UserControllers) RevokeSession(c *gin.Context){
+	id := c.Param("id")
+	if id == ""{
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Session id required",
+			Status:  400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	err := uc.userUserCase.RevokeSession(id, user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status:  400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Session revoked successfully",
+		Status:  200,
+	})
+}
+
+
+func (uc *UserControllers) ResendVerificationEmail(c *gin.Context){
+	var request domain.ResetPasswordRequest
+	err := c.ShouldBind(&request)
+	if err != nil {
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Invalid request",
+			Status:  400,
+		})
+		return
+	}
+	err = uc.userUserCase.ResendVerificationEmail(request.Email)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status:  400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "If an unverified account exists for this email, a new verification link has been sent",
+		Status:  200,
+	})
+}
+
+
+func (uc *UserControllers) UpdateProfile(c *gin.Context){
+	var request domain.UpdateProfileRequest
+	err := c.BindJSON(&request)
+	if err != nil {
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Invalid request",
+			Status:  400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	profile, err := uc.userUserCase.UpdateProfile(user_id, request)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status:  400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "User profile updated successfully",
+		Data: profile,
+		Status:  200,
+	})
+}
+
+
+func (uc *UserControllers) RequestEmailChange(c *gin.Context){
+	var request domain.EmailChangeRequest
+	err := c.BindJSON(&request)
+	if err != nil {
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Invalid request",
+			Status:  400,
+		})
+		return
+	}
+	if err := validator.New().Struct(request); err != nil {
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Invalid request",
+			Status:  400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	err = uc.userUserCase.RequestEmailChange(user_id, request.NewEmail, request.Password)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status:  400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Confirmation link sent to the new email address",
+		Status:  200,
+	})
+}
+
+
+func (uc *UserControllers) ConfirmEmailChange(c *gin.Context){
+	token := c.Query("token")
+	user := c.Query("user")
+
+	if token == "" || user == "" {
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Both token and user required",
+			Status:  400,
+		})
+		return
+	}
+	err := uc.userUserCase.ConfirmEmailChange(user, token)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status:  400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Email changed successfully",
+		Status:  200,
+	})
+}
+
+
+func (uc *UserControllers) ChangePassword(c *gin.Context){
+	var request domain.ChangePasswordRequest
+	err := c.BindJSON(&request)
+	if err != nil {
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Invalid request",
+			Status:  400,
+		})
+		return
+	}
+	if err := validator.New().Struct(request); err != nil {
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Invalid request",
+			Status:  400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	err = uc.userUserCase.ChangePassword(user_id, c.GetString("session_id"), request.CurrentPassword, request.NewPassword, clientInfo(c))
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Data: passwordViolations(err),
+			Status:  400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Password changed successfully. Other sessions have been signed out",
+		Status:  200,
+	})
+}
+
+
+
+func (uc *UserControllers) CloseAccount(c *gin.Context){
+	var request domain.CloseAccountRequest
+	err := c.BindJSON(&request)
+	if err != nil {
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Invalid request",
+			Status:  400,
+		})
+		return
+	}
+	if err := validator.New().Struct(request); err != nil {
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Invalid request",
+			Status:  400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	err = uc.userUserCase.CloseAccount(user_id, request.Password, request.Reason)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status:  400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Account closed successfully",
+		Status:  200,
+	})
+}
+
+// clientInfo describes the client making the request, for sessions and the audit log.
+func clientInfo(c *gin.Context) domain.ClientInfo {
+	return domain.ClientInfo{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		RequestID: c.GetString("request_id"),
+	}
+}
+
+// passwordViolations exposes every broken rule of a password policy error to the client.
+func passwordViolations(err error) interface{} {
+	var policyErr *domain.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		return policyErr.Violations
+	}
+	return nil
+}
+
+
+func (uc *UserControllers) GetNotificationPreferences(c *gin.Context){
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	preferences, err := uc.userUserCase.GetNotificationPreferences(user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status:  400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Notification preferences retrieved successfully",
+		Data: preferences,
+		Status:  200,
+	})
+}
+
+
+func (uc *UserControllers) UpdateNotificationPreferences(c *gin.Context){
+	var request domain.UpdateNotificationPreferencesRequest
+	err := c.BindJSON(&request)
+	if err != nil || validator.New().Struct(request) != nil {
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Invalid request",
+			Status:  400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	preferences, err := uc.userUserCase.UpdateNotificationPreferences(user_id, request)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status:  400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Notification preferences updated successfully",
+		Data: preferences,
+		Status:  200,
+	})
+}
+
+
+func (uc *UserControllers) GetPushPublicKey(c *gin.Context){
+	key := uc.userUserCase.PushPublicKey()
+	if key == ""{
+		c.JSON(404, domain.ErrorResponse{
+			Message: "Push notifications are not configured",
+			Status:  404,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "success",
+		Data: map[string]string{"public_key": key},
+		Status:  200,
+	})
+}
+
+
+func (uc *UserControllers) AddPushSubscription(c *gin.Context){
+	var request domain.PushSubscriptionRequest
+	err := c.BindJSON(&request)
+	if err != nil || validator.New().Struct(request) != nil {
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Invalid request",
+			Status:  400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	err = uc.userUserCase.AddPushSubscription(user_id, request)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status:  400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Push subscription saved",
+		Status:  200,
+	})
+}
+
+
+func (uc *UserControllers) RemovePushSubscription(c *gin.Context){
+	endpoint := c.Query("endpoint")
+	if endpoint == ""{
+		c.JSON(400, domain.ErrorResponse{
+			Message: "endpoint is required",
+			Status:  400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	err := uc.userUserCase.RemovePushSubscription(user_id, endpoint)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status:  400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Push subscription removed",
+		Status:  200,
+	})
 }
@@ -0,0 +1,234 @@
+package controllers
+
+import (
+	domain "loan-tracker/Domain"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type WebhookControllers struct{
+	WebhookUseCase domain.WebhookUseCaseInterface
+}
+
+func NewWebhookControllers(webhookUseCase domain.WebhookUseCaseInterface) *WebhookControllers {
+	return &WebhookControllers{
+		WebhookUseCase: webhookUseCase,
+	}
+}
+
+
+func (wc *WebhookControllers) GetEventTypes(c *gin.Context){
+	c.JSON(200, domain.SuccessResponse{
+		Message: "success",
+		Data: map[string]any{"events": wc.WebhookUseCase.EventTypes(), "version": domain.WebhookSchemaVersion},
+		Status: 200,
+	})
+}
+
+
+func (wc *WebhookControllers) CreateWebhook(c *gin.Context){
+	var request domain.CreateWebhookRequest
+	err := c.BindJSON(&request)
+	if err != nil || validator.New().Struct(request) != nil {
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Invalid request",
+			Status:  400,
+		})
+		return
+	}
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	endpoint, err := wc.WebhookUseCase.CreateWebhook(request, user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(201, domain.SuccessResponse{
+		Message: "Webhook created successfully",
+		Data: endpoint,
+		Status: 201,
+	})
+}
+
+
+func (wc *WebhookControllers) GetWebhooks(c *gin.Context){
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	endpoints, err := wc.WebhookUseCase.GetWebhooks(user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "success",
+		Data: endpoints,
+		Status: 200,
+	})
+}
+
+
+func (wc *WebhookControllers) GetWebhook(c *gin.Context){
+	id := c.Param("id")
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	endpoint, err := wc.WebhookUseCase.GetWebhook(id, user_id)
+	if err != nil{
+		c.JSON(404, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 404,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "success",
+		Data: endpoint,
+		Status: 200,
+	})
+}
+
+
+func (wc *WebhookControllers) UpdateWebhook(c *gin.Context){
+	var request domain.UpdateWebhookRequest
+	err := c.BindJSON(&request)
+	if err != nil || validator.New().Struct(request) != nil {
+		c.JSON(400, domain.ErrorResponse{
+			Message: "Invalid request",
+			Status:  400,
+		})
+		return
+	}
+	id := c.Param("id")
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	endpoint, err := wc.WebhookUseCase.UpdateWebhook(id, request, user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Webhook updated successfully",
+		Data: endpoint,
+		Status: 200,
+	})
+}
+
+
+func (wc *WebhookControllers) DeleteWebhook(c *gin.Context){
+	id := c.Param("id")
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	err := wc.WebhookUseCase.DeleteWebhook(id, user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "Webhook deleted successfully",
+		Status: 200,
+	})
+}
+
+
+// GetDeliveries lists the deliveries to an endpoint, each with the log of its attempts.
+func (wc *WebhookControllers) GetDeliveries(c *gin.Context){
+	pageNo := c.Query("pageNo")
+	pageSize := c.Query("pageSize")
+
+	if pageNo == ""{
+		pageNo = "1"
+	}
+	if pageSize == ""{
+		pageSize = "10"
+	}
+	id := c.Param("id")
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	deliveries, err := wc.WebhookUseCase.GetDeliveries(id, c.Query("status"), pageNo, pageSize, user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(200, domain.SuccessResponse{
+		Message: "success",
+		Data: deliveries,
+		Status: 200,
+	})
+}
+
+
+func (wc *WebhookControllers) ReplayDelivery(c *gin.Context){
+	id := c.Param("id")
+	delivery_id := c.Param("delivery_id")
+	user_id := c.GetString("user_id")
+	if user_id == "" {
+		c.JSON(500, domain.ErrorResponse{
+			Message: "Unauthorized: Authorization header required",
+			Status:  500,
+		})
+		return
+	}
+	err := wc.WebhookUseCase.ReplayDelivery(id, delivery_id, user_id)
+	if err != nil{
+		c.JSON(400, domain.ErrorResponse{
+			Message: err.Error(),
+			Status: 400,
+		})
+		return
+	}
+	c.JSON(202, domain.SuccessResponse{
+		Message: "Delivery queued for replay",
+		Status: 202,
+	})
+}
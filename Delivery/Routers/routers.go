@@ -1,60 +1,261 @@
 package routers
 
 import (
+	"context"
+	"expvar"
 	controllers "loan-tracker/Delivery/Controllers"
+	domain "loan-tracker/Domain"
 	infrastructure "loan-tracker/Infrastructure"
 	repository "loan-tracker/Repository"
 	useCase "loan-tracker/Usecase"
+	"log"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 
 func Routers(server *gin.Engine, db *infrastructure.Db, config *infrastructure.Config) {
+	server.Use(infrastructure.RequestIDMiddleware())
 	user_collection := db.CreateDb(config.DatabaseUrl, config.DbName, config.UserCollection)
 	loan_collection := db.CreateDb(config.DatabaseUrl, config.DbName, config.LoanCollection)
+	session_collection := db.CreateDb(config.DatabaseUrl, config.DbName, config.SessionCollection)
+	data_job_collection := db.CreateDb(config.DatabaseUrl, config.DbName, config.DataJobCollection)
+	outbox_collection := db.CreateDb(config.DatabaseUrl, config.DbName, config.OutboxCollection)
+	reminder_collection := db.CreateDb(config.DatabaseUrl, config.DbName, config.ReminderCollection)
+	inbox_collection := db.CreateDb(config.DatabaseUrl, config.DbName, config.InboxCollection)
+	webhook_collection := db.CreateDb(config.DatabaseUrl, config.DbName, config.WebhookCollection)
+	payment_collection := db.CreateDb(config.DatabaseUrl, config.DbName, config.PaymentCollection)
+	statement_mapping_collection := db.CreateDb(config.DatabaseUrl, config.DbName, config.StatementMappingCollection)
+	statement_import_collection := db.CreateDb(config.DatabaseUrl, config.DbName, config.StatementImportCollection)
+	audit_collection := db.CreateDb(config.DatabaseUrl, config.DbName, config.AuditCollection)
 
 	user_repository := repository.NewUserRepository(user_collection, config)
+	if err := user_repository.EnsureIndexes(); err != nil {
+		log.Fatal(err)
+	}
 	loan_repository := repository.NewLoanRepository(loan_collection, config)
 	admin_repository := repository.NewAdminRepository(user_collection, config)
+	session_repository := repository.NewSessionRepository(session_collection, config)
+	data_job_repository := repository.NewDataJobRepository(data_job_collection, config)
+	outbox_repository := repository.NewOutboxRepository(outbox_collection, config)
+	reminder_repository := repository.NewReminderRepository(reminder_collection, config)
+	if err := reminder_repository.EnsureIndexes(); err != nil {
+		log.Fatal(err)
+	}
+	inbox_repository := repository.NewInboxRepository(inbox_collection, config)
+	if err := inbox_repository.EnsureIndexes(); err != nil {
+		log.Fatal(err)
+	}
+	webhook_repository := repository.NewWebhookRepository(webhook_collection, config)
+	payment_repository := repository.NewPaymentRepository(payment_collection, config)
+	if err := payment_repository.EnsureIndexes(); err != nil {
+		log.Fatal(err)
+	}
+	if err := loan_repository.EnsureIndexes(); err != nil {
+		log.Fatal(err)
+	}
+	statement_repository := repository.NewStatementRepository(statement_mapping_collection, statement_import_collection, config)
+	if err := statement_repository.EnsureIndexes(); err != nil {
+		log.Fatal(err)
+	}
+	report_repository := repository.NewReportRepository(loan_collection, payment_collection, config)
+	if err := report_repository.EnsureIndexes(); err != nil {
+		log.Fatal(err)
+	}
+	audit_repository := repository.NewAuditRepository(audit_collection, config)
+	if err := audit_repository.EnsureIndexes(); err != nil {
+		log.Fatal(err)
+	}
+	event_bus := useCase.NewEventBus(config)
+	unit_of_work := event_bus.AfterCommit(infrastructure.NewMongoUnitOfWork(db.ConnectToDatabase(config.DatabaseUrl), config))
 
-	password_service := infrastructure.NewPasswordService()
-	user_useCase := useCase.NewUserUseCase(user_repository, *password_service, config)
-	loan_usecase := useCase.NewLoanUseCase(loan_repository, *password_service, config, user_repository)
-	admin_useCase := useCase.NewAdminUseCase(admin_repository, *password_service, config, user_repository)
+	login_attempt_store := infrastructure.NewInMemoryLoginAttemptStore(time.Duration(config.LoginAttemptWindowMinutes) * time.Minute)
+	password_service, err := infrastructure.NewPasswordService(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	token_service, err := infrastructure.NewTokenService(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	secret_box, err := infrastructure.NewSecretBox(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	mailer, err := infrastructure.NewMailer(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	email_templates, err := infrastructure.NewEmailTemplates(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sms_sender, err := infrastructure.NewSMSSender(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	push_sender, err := infrastructure.NewPushSender(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	webhook_publisher := useCase.NewWebhookPublisher(webhook_repository, outbox_repository)
+	notifier := useCase.NewNotifier(outbox_repository, inbox_repository, email_templates, config)
+	useCase.NewEmailSubscriber(notifier, outbox_repository, email_templates, config).Register(event_bus)
+	useCase.NewWebhookSubscriber(webhook_publisher).Register(event_bus)
+	useCase.NewAuditSubscriber(outbox_repository).Register(event_bus)
+	useCase.NewMetricsSubscriber().Register(event_bus)
+	user_useCase := useCase.NewUserUseCase(user_repository, session_repository, login_attempt_store, *password_service, token_service, secret_box, config, loan_repository, unit_of_work, email_templates, event_bus)
+	loan_usecase := useCase.NewLoanUseCase(loan_repository, *password_service, config, user_repository, unit_of_work, event_bus)
+	admin_useCase := useCase.NewAdminUseCase(admin_repository, *password_service, config, user_repository, session_repository, login_attempt_store, loan_repository, outbox_repository, unit_of_work, email_templates, event_bus)
+
+	data_job_usecase := useCase.NewDataJobUseCase(data_job_repository, user_repository, loan_repository, payment_repository, session_repository, inbox_repository, outbox_repository, audit_repository, statement_repository, *password_service, config)
+	go data_job_usecase.Run(context.Background())
+	outbox_worker := useCase.NewOutboxWorker(outbox_repository, mailer, sms_sender, push_sender, infrastructure.NewWebhookSender(config), webhook_repository, audit_repository, config)
+	go outbox_worker.Run(context.Background())
+	reminder_scheduler := useCase.NewReminderScheduler(loan_repository, user_repository, reminder_repository, unit_of_work, event_bus, config)
+	go reminder_scheduler.Run(context.Background())
+	inbox_usecase := useCase.NewInboxUseCase(inbox_repository)
+	payment_tolerance := time.Duration(config.PaymentSignatureToleranceSeconds) * time.Second
+	payment_providers := []domain.PaymentProvider{}
+	if config.PaymentGatewaySecret != "" {
+		payment_providers = append(payment_providers, infrastructure.NewJSONPaymentGateway(infrastructure.PaymentProviderGateway, config.PaymentGatewaySecret, payment_tolerance))
+	}
+	if config.PaymentSimulator {
+		payment_simulator, err := infrastructure.NewPaymentSimulator(payment_tolerance)
+		if err != nil {
+			log.Fatal(err)
+		}
+		payment_providers = append(payment_providers, payment_simulator)
+	}
+	payment_usecase := useCase.NewPaymentUseCase(payment_repository, loan_repository, user_repository, unit_of_work, event_bus, payment_providers, config)
+	statement_usecase := useCase.NewStatementUseCase(statement_repository, payment_repository, payment_usecase, loan_repository, user_repository, config)
+	webhook_usecase := useCase.NewWebhookUseCase(webhook_repository, outbox_repository)
+	audit_usecase := useCase.NewAuditUseCase(audit_repository)
+	report_usecase := useCase.NewReportUseCase(report_repository, config)
 
 	userControllers := controllers.NewUserControllers(user_useCase)
+	dataJobControllers := controllers.NewDataJobControllers(data_job_usecase)
+	inboxControllers := controllers.NewInboxControllers(inbox_usecase)
+	webhookControllers := controllers.NewWebhookControllers(webhook_usecase)
+	paymentControllers := controllers.NewPaymentControllers(payment_usecase)
+	statementControllers := controllers.NewStatementControllers(statement_usecase)
+	auditControllers := controllers.NewAuditControllers(audit_usecase)
+	reportControllers := controllers.NewReportControllers(report_usecase)
 
 	adminControllers := controllers.NewAdminControllers(admin_useCase, loan_usecase)
 
 	loan_controller := controllers.NewLoanControllers(loan_usecase)
+	keyControllers := controllers.NewKeyControllers(token_service)
 	
-	authMiddleWare := infrastructure.NewAuthMiddleware(*config).AuthenticationMiddleware()
+	authenticator := infrastructure.NewAuthMiddleware(*config, token_service, session_repository, user_repository)
+	authMiddleWare := authenticator.AuthenticationMiddleware()
+	twoFactorMiddleWare := authenticator.TwoFactorMiddleware()
+	adminMiddleWare := authenticator.AdminMiddleware()
 
 
 	nonAuth := server.Group("users")
 	nonAuth.POST("/register", userControllers.RegisterUser)
 	nonAuth.GET("/verify-email", userControllers.VerifyEmail)
+	nonAuth.POST("/verify-email/resend", userControllers.ResendVerificationEmail)
+	nonAuth.GET("/email-change/confirm", userControllers.ConfirmEmailChange)
 	nonAuth.POST("/login", userControllers.Login)
+	nonAuth.POST("/login/2fa", userControllers.VerifyTwoFactorLogin)
+	nonAuth.POST("/password-reset", userControllers.ResetPassword)
+	nonAuth.POST("/password-update", userControllers.ResetPasswordVerify)
+	nonAuth.GET("/push/public-key", userControllers.GetPushPublicKey)
 
 
-	adminRoute := server.Group("admin")
-	adminRoute.GET("/users", authMiddleWare, adminControllers.GetAllUsers)
-	adminRoute.DELETE("/users/:id", authMiddleWare, adminControllers.DeleteUser)
-	adminRoute.GET("/loans", authMiddleWare, adminControllers.GetAllLoans)
+	adminRoute := server.Group("admin", authMiddleWare, twoFactorMiddleWare, adminMiddleWare)
+	adminRoute.GET("/users", adminControllers.GetAllUsers)
+	adminRoute.GET("/users/search", adminControllers.SearchUsers)
+	adminRoute.GET("/users/:id", adminControllers.GetUser)
+	adminRoute.DELETE("/users/:id", adminControllers.DeleteUser)
+	adminRoute.DELETE("/users/:id/sessions", adminControllers.RevokeUserSessions)
+	adminRoute.POST("/users/:id/unlock", adminControllers.UnlockUser)
+	adminRoute.POST("/users/:id/suspend", adminControllers.SuspendUser)
+	adminRoute.POST("/users/:id/reactivate", adminControllers.ReactivateUser)
+	adminRoute.POST("/users/:id/verify", adminControllers.ForceVerifyUser)
+	adminRoute.POST("/users/:id/password-reset", adminControllers.TriggerPasswordReset)
+	adminRoute.PATCH("/users/:id/role", adminControllers.ChangeUserRole)
+	adminRoute.POST("/users/:id/restore", adminControllers.RestoreUser)
+	adminRoute.GET("/loans", adminControllers.GetAllLoans)
+	adminRoute.POST("/loans/:id/approve", adminControllers.ApproveLoan)
+	adminRoute.POST("/loans/:id/reject", adminControllers.RejectLoan)
+	adminRoute.POST("/loans/:id/disburse", adminControllers.DisburseLoan)
+	adminRoute.GET("/emails/templates", adminControllers.ListEmailTemplates)
+	adminRoute.GET("/emails/templates/:name/preview", adminControllers.PreviewEmail)
+	adminRoute.GET("/outbox", adminControllers.ListDeliveries)
+	adminRoute.GET("/outbox/:id", adminControllers.GetDelivery)
+	adminRoute.POST("/outbox/:id/retry", adminControllers.RetryDelivery)
+	adminRoute.GET("/payments", paymentControllers.GetPayments)
+	adminRoute.POST("/payments/simulate", paymentControllers.SimulatePayment)
+	adminRoute.GET("/payments/:id", paymentControllers.GetPayment)
+	adminRoute.POST("/payments/:id/assign", paymentControllers.AssignPayment)
+	adminRoute.GET("/statements/banks", statementControllers.GetMappings)
+	adminRoute.PUT("/statements/banks/:bank", statementControllers.SaveMapping)
+	adminRoute.DELETE("/statements/banks/:bank", statementControllers.DeleteMapping)
+	adminRoute.POST("/statements", statementControllers.ImportStatement)
+	adminRoute.GET("/statements", statementControllers.GetImports)
+	adminRoute.GET("/statements/:id", statementControllers.GetImport)
+	adminRoute.POST("/statements/:id/confirm", statementControllers.ConfirmImport)
+	adminRoute.GET("/webhooks/events", webhookControllers.GetEventTypes)
+	adminRoute.POST("/webhooks", webhookControllers.CreateWebhook)
+	adminRoute.GET("/webhooks", webhookControllers.GetWebhooks)
+	adminRoute.GET("/webhooks/:id", webhookControllers.GetWebhook)
+	adminRoute.PATCH("/webhooks/:id", webhookControllers.UpdateWebhook)
+	adminRoute.DELETE("/webhooks/:id", webhookControllers.DeleteWebhook)
+	adminRoute.GET("/webhooks/:id/deliveries", webhookControllers.GetDeliveries)
+	adminRoute.POST("/webhooks/:id/deliveries/:delivery_id/replay", webhookControllers.ReplayDelivery)
+	adminRoute.GET("/metrics", gin.WrapH(expvar.Handler()))
+	adminRoute.GET("/audit", auditControllers.GetRecords)
+	adminRoute.GET("/audit/export", auditControllers.ExportRecords)
+	adminRoute.GET("/audit/verify", auditControllers.VerifyChain)
+	adminRoute.GET("/reports/dashboard", reportControllers.GetDashboard)
+	adminRoute.GET("/reports/loans", reportControllers.GetLoanActivity)
+	adminRoute.GET("/reports/portfolio", reportControllers.GetPortfolio)
+	adminRoute.GET("/reports/collections", reportControllers.GetCollections)
+	adminRoute.GET("/reports/vintages", reportControllers.GetVintages)
+	adminRoute.GET("/reports/vintages/export", reportControllers.ExportVintages)
 	
 	
 	
 	auth := server.Group("users")
 	auth.GET("/profile", authMiddleWare, userControllers.GetUserProfile)
-	auth.POST("/password-reset", authMiddleWare, userControllers.ResetPassword)
-	auth.POST("/password-update", authMiddleWare, userControllers.ResetPasswordVerify)
+	auth.PATCH("/profile", authMiddleWare, userControllers.UpdateProfile)
+	auth.POST("/email-change", authMiddleWare, userControllers.RequestEmailChange)
+	auth.GET("/notification-preferences", authMiddleWare, userControllers.GetNotificationPreferences)
+	auth.PUT("/notification-preferences", authMiddleWare, userControllers.UpdateNotificationPreferences)
+	auth.GET("/notifications", authMiddleWare, inboxControllers.GetNotifications)
+	auth.GET("/notifications/unread-count", authMiddleWare, inboxControllers.CountUnread)
+	auth.POST("/notifications/read-all", authMiddleWare, inboxControllers.MarkAllRead)
+	auth.POST("/notifications/:id/read", authMiddleWare, inboxControllers.MarkRead)
+	auth.POST("/push-subscriptions", authMiddleWare, userControllers.AddPushSubscription)
+	auth.DELETE("/push-subscriptions", authMiddleWare, userControllers.RemovePushSubscription)
+	auth.POST("/password-change", authMiddleWare, userControllers.ChangePassword)
+	auth.POST("/account/close", authMiddleWare, userControllers.CloseAccount)
+	auth.POST("/logout", authMiddleWare, userControllers.Logout)
+	auth.GET("/sessions", authMiddleWare, userControllers.GetSessions)
+	auth.DELETE("/sessions/:id", authMiddleWare, userControllers.RevokeSession)
+	auth.POST("/2fa/enroll", authMiddleWare, userControllers.EnrollTwoFactor)
+	auth.POST("/2fa/confirm", authMiddleWare, userControllers.ConfirmTwoFactor)
+	auth.POST("/2fa/disable", authMiddleWare, userControllers.DisableTwoFactor)
+	auth.POST("/2fa/recovery-codes", authMiddleWare, userControllers.RegenerateRecoveryCodes)
+	auth.POST("/me/export", authMiddleWare, dataJobControllers.RequestExport)
+	auth.GET("/me/export", authMiddleWare, dataJobControllers.GetExports)
+	auth.GET("/me/export/:id", authMiddleWare, dataJobControllers.GetExport)
+	auth.GET("/me/export/:id/download", authMiddleWare, dataJobControllers.DownloadExport)
+	auth.POST("/me/erasure", authMiddleWare, dataJobControllers.RequestErasure)
+	auth.GET("/me/erasure/:id", authMiddleWare, dataJobControllers.GetErasure)
 
 	loanRoute := server.Group("loans")
 	loanRoute.POST("", authMiddleWare, loan_controller.CreateLoan)
 	loanRoute.GET("/:id", authMiddleWare, loan_controller.CheckLoanStatus)
 
 
+	server.GET("/.well-known/jwks.json", keyControllers.JWKS)
+	server.POST("/payments/webhooks/:provider", paymentControllers.ReceivePayment)
+
 	tokenGroup := server.Group("token")
 	tokenGroup.POST("/refresh", authMiddleWare, userControllers.RefreshToken)
 
@@ -0,0 +1,122 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ChannelAudit is the outbox channel of audit records. They are queued in the unit of work of the
+// action they record and appended to the audit log by the outbox worker, which retries failures.
+const ChannelAudit = "audit"
+
+// Categories of audit records.
+const (
+	AuditAdmin    = "admin"
+	AuditSecurity = "security"
+)
+
+// Audited admin actions.
+const (
+	AuditUserDeleted             = "user.deleted"
+	AuditUserRestored            = "user.restored"
+	AuditUserSuspended           = "user.suspended"
+	AuditUserReactivated         = "user.reactivated"
+	AuditUserVerified            = "user.verified"
+	AuditUserUnlocked            = "user.unlocked"
+	AuditUserSessionsRevoked     = "user.sessions_revoked"
+	AuditUserPasswordResetIssued = "user.password_reset_issued"
+	AuditUserRoleChanged         = "user.role_changed"
+	AuditLoanApproved            = "loan.approved"
+	AuditLoanRejected            = "loan.rejected"
+	AuditLoanDisbursed           = "loan.disbursed"
+	AuditPaymentAssigned         = "payment.assigned"
+)
+
+// Audited security events.
+const (
+	AuditLoginSucceeded           = "login.succeeded"
+	AuditLoginFailed              = "login.failed"
+	AuditAccountLocked            = "account.locked"
+	AuditPasswordChanged          = "password.changed"
+	AuditPasswordReset            = "password.reset"
+	AuditTwoFactorEnabled         = "two_factor.enabled"
+	AuditTwoFactorDisabled        = "two_factor.disabled"
+	AuditRecoveryCodesRegenerated = "two_factor.recovery_codes_regenerated"
+)
+
+// AuditChange is one field changed by an action. Values are kept as text so a record hashes the
+// same after a round trip through the database.
+type AuditChange struct {
+	Field  string `bson:"field" json:"field"`
+	Before string `bson:"before" json:"before"`
+	After  string `bson:"after" json:"after"`
+}
+
+// AuditRecord is one entry of the audit log. Records are only ever appended. Each holds the hash
+// of the record before it, and its own Hash covers its content and that link, so editing,
+// removing or reordering records breaks the chain from that point on. The client's IP and user
+// agent are covered through ClientDigest, a salted hash of them: erasing a user's personal data
+// removes the IP, user agent and salt of their records and leaves the chain intact.
+type AuditRecord struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Sequence     int64              `bson:"sequence" json:"sequence"`
+	Category     string             `bson:"category" json:"category"`
+	Action       string             `bson:"action" json:"action"`
+	ActorID      string             `bson:"actor_id,omitempty" json:"actor_id,omitempty"`
+	TargetType   string             `bson:"target_type,omitempty" json:"target_type,omitempty"`
+	TargetID     string             `bson:"target_id,omitempty" json:"target_id,omitempty"`
+	Changes      []AuditChange      `bson:"changes,omitempty" json:"changes,omitempty"`
+	IP           string             `bson:"ip,omitempty" json:"ip,omitempty"`
+	UserAgent    string             `bson:"user_agent,omitempty" json:"user_agent,omitempty"`
+	ClientSalt   string             `bson:"client_salt,omitempty" json:"-"`
+	ClientDigest string             `bson:"client_digest" json:"-"`
+	RequestID    string             `bson:"request_id,omitempty" json:"request_id,omitempty"`
+	Created_At   time.Time          `bson:"created_at" json:"created_at"`
+	PrevHash     string             `bson:"prev_hash" json:"prev_hash"`
+	Hash         string             `bson:"hash" json:"hash"`
+}
+
+// AuditFilter narrows an audit log query. Empty fields do not filter.
+type AuditFilter struct {
+	Category   string
+	Action     string
+	ActorID    string
+	TargetType string
+	TargetID   string
+	RequestID  string
+	From       time.Time
+	To         time.Time
+}
+
+// AuditVerification is the result of checking the hash chain. BrokenAt is the sequence of the
+// first record that does not match.
+type AuditVerification struct {
+	Valid    bool   `json:"valid"`
+	Records  int64  `json:"records"`
+	BrokenAt int64  `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// ActionPerformed is published for every audited action. Its record is queued for the audit log in
+// the action's unit of work.
+type ActionPerformed struct {
+	Record AuditRecord
+}
+
+func (e ActionPerformed) EventName() string   { return "ActionPerformed" }
+func (e ActionPerformed) AggregateID() string { return e.Record.TargetID }
+
+type AuditUseCaseInterface interface {
+	GetRecords(filter AuditFilter, pageNo, pageSize string, user_id string) ([]AuditRecord, error)
+	ExportRecords(filter AuditFilter, user_id string, fn func(record AuditRecord) error) error
+	VerifyChain(user_id string) (AuditVerification, error)
+}
+
+type AuditRepositoryInterface interface {
+	EnsureIndexes() error
+	Append(record AuditRecord) (AuditRecord, error)
+	Find(filter AuditFilter, pageNo, pageSize int64) ([]AuditRecord, error)
+	Each(filter AuditFilter, fn func(record AuditRecord) error) error
+	EraseClientDetails(user_id string) (int64, error)
+}
@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Kinds of personal data jobs.
+const (
+	DataJobExport  = "export"
+	DataJobErasure = "erasure"
+)
+
+// Statuses a personal data job moves through.
+const (
+	DataJobPending   = "pending"
+	DataJobRunning   = "running"
+	DataJobCompleted = "completed"
+	DataJobFailed    = "failed"
+	DataJobExpired   = "expired"
+)
+
+// DataJob is a personal data export or erasure request processed in the background.
+type DataJob struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID      primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Type        string             `bson:"type" json:"type"`
+	Status      string             `bson:"status" json:"status"`
+	Error       string             `bson:"error" json:"error,omitempty"`
+	FilePath    string             `bson:"file_path" json:"-"`
+	Created_At  time.Time          `bson:"created_at" json:"created_at"`
+	StartedAt   *time.Time         `bson:"started_at" json:"started_at,omitempty"`
+	CompletedAt *time.Time         `bson:"completed_at" json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time         `bson:"expires_at" json:"expires_at,omitempty"`
+}
+
+// ArchiveSection is one dataset of a personal data export. Records is written as JSON and
+// Header and Rows as CSV, so the archive is readable both by programs and in a spreadsheet.
+type ArchiveSection struct {
+	Name    string
+	Records interface{}
+	Header  []string
+	Rows    [][]string
+}
+
+type ErasureRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+type DataJobUseCaseInterface interface {
+	RequestExport(user_id string) (DataJob, error)
+	GetExports(user_id string) ([]DataJob, error)
+	GetExport(id string, user_id string) (DataJob, error)
+	GetExportFile(id string, user_id string) (string, error)
+	RequestErasure(user_id string, password string) (DataJob, error)
+	GetErasure(id string, user_id string) (DataJob, error)
+}
+
+type DataJobRepositoryInterface interface {
+	CreateJob(job DataJob) (DataJob, error)
+	FindJobByID(id string) (DataJob, error)
+	FindJobsByUserID(user_id string, job_type string) ([]DataJob, error)
+	FindActiveJob(user_id string, job_type string) (DataJob, error)
+	ClaimNextJob(stale_before time.Time) (DataJob, error)
+	UpdateJob(job DataJob) error
+	FindExpiredExports(now time.Time) ([]DataJob, error)
+}
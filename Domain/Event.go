@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// DomainEvent is something that happened to a user or a loan. Use cases publish events; the
+// emails, webhooks, audit records and metrics that follow from them are sent by subscribers.
+// AggregateID is the id of the user or loan the event is about.
+type DomainEvent interface {
+	EventName() string
+	AggregateID() string
+}
+
+// EventHandler handles the events a subscriber registered for.
+type EventHandler func(ctx context.Context, event DomainEvent) error
+
+// EventBusInterface publishes domain events. Published within a unit of work, an event is handled
+// as part of it: a synchronous handler that fails makes the publish, and so the unit of work, fail.
+type EventBusInterface interface {
+	Publish(ctx context.Context, event DomainEvent) error
+}
+
+// UserRegistered is published when an account is created. VerificationLink carries the one-time
+// verification token and must not be stored.
+type UserRegistered struct {
+	User             User
+	VerificationLink string
+}
+
+// VerificationRequested is published when an unverified user asks for a new verification link.
+type VerificationRequested struct {
+	User             User
+	VerificationLink string
+}
+
+// UserVerified is published when an email address is verified, by its owner or, with VerifiedBy
+// set, by an admin.
+type UserVerified struct {
+	User       User
+	VerifiedAt time.Time
+	VerifiedBy string
+}
+
+// PasswordResetRequested is published when a reset link is issued, by the user or, with
+// RequestedBy set, by an admin.
+type PasswordResetRequested struct {
+	User        User
+	ResetLink   string
+	RequestedBy string
+}
+
+type PasswordChanged struct {
+	User User
+}
+
+// EmailChangeRequested is published when a user asks to move their account to NewEmail.
+type EmailChangeRequested struct {
+	User             User
+	NewEmail         string
+	ConfirmationLink string
+}
+
+// AccountLocked is published when too many failed logins lock an account until Until.
+type AccountLocked struct {
+	User  User
+	Until time.Time
+}
+
+type LoanSubmitted struct {
+	Loan     Loan
+	Borrower User
+}
+
+// LoanStatusChanged is published when an admin approves, rejects or disburses a loan. Previous is
+// the status the loan had before.
+type LoanStatusChanged struct {
+	Loan      Loan
+	Borrower  User
+	Previous  string
+	ChangedBy string
+}
+
+// PaymentPosted is published when a payment is applied to a loan. Loan is the loan after the payment.
+type PaymentPosted struct {
+	Payment  Payment
+	Loan     Loan
+	Borrower User
+}
+
+// PaymentReminderDue is published for each stage of an installment's reminder cadence. Offset is
+// the number of days from the due date, negative before it.
+type PaymentReminderDue struct {
+	Loan        Loan
+	Installment Installment
+	Borrower    User
+	Offset      int
+}
+
+func (e UserRegistered) EventName() string         { return "UserRegistered" }
+func (e VerificationRequested) EventName() string  { return "VerificationRequested" }
+func (e UserVerified) EventName() string           { return "UserVerified" }
+func (e PasswordResetRequested) EventName() string { return "PasswordResetRequested" }
+func (e PasswordChanged) EventName() string        { return "PasswordChanged" }
+func (e EmailChangeRequested) EventName() string   { return "EmailChangeRequested" }
+func (e AccountLocked) EventName() string          { return "AccountLocked" }
+func (e LoanSubmitted) EventName() string          { return "LoanSubmitted" }
+func (e LoanStatusChanged) EventName() string      { return "LoanStatusChanged" }
+func (e PaymentPosted) EventName() string          { return "PaymentPosted" }
+func (e PaymentReminderDue) EventName() string     { return "PaymentReminderDue" }
+
+func (e UserRegistered) AggregateID() string         { return e.User.ID.Hex() }
+func (e VerificationRequested) AggregateID() string  { return e.User.ID.Hex() }
+func (e UserVerified) AggregateID() string           { return e.User.ID.Hex() }
+func (e PasswordResetRequested) AggregateID() string { return e.User.ID.Hex() }
+func (e PasswordChanged) AggregateID() string        { return e.User.ID.Hex() }
+func (e EmailChangeRequested) AggregateID() string   { return e.User.ID.Hex() }
+func (e AccountLocked) AggregateID() string          { return e.User.ID.Hex() }
+func (e LoanSubmitted) AggregateID() string          { return e.Loan.ID.Hex() }
+func (e LoanStatusChanged) AggregateID() string      { return e.Loan.ID.Hex() }
+func (e PaymentPosted) AggregateID() string          { return e.Loan.ID.Hex() }
+func (e PaymentReminderDue) AggregateID() string     { return e.Loan.ID.Hex() }
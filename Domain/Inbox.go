@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// InboxNotification is an entry of a user's in-app notification inbox. Type is the name of the
+// template it was rendered from, such as loan_approved.
+type InboxNotification struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID     primitive.ObjectID `bson:"user_id" json:"-"`
+	Type       string             `bson:"type" json:"type"`
+	Title      string             `bson:"title" json:"title"`
+	Body       string             `bson:"body" json:"body"`
+	Link       string             `bson:"link,omitempty" json:"link,omitempty"`
+	Reference  string             `bson:"reference,omitempty" json:"reference,omitempty"`
+	Created_At time.Time          `bson:"created_at" json:"created_at"`
+	ReadAt     *time.Time         `bson:"read_at,omitempty" json:"read_at,omitempty"`
+}
+
+type InboxPage struct {
+	Notifications []InboxNotification `json:"notifications"`
+	Unread        int64               `json:"unread"`
+}
+
+type InboxUseCaseInterface interface {
+	GetNotifications(user_id string, unread_only bool, pageNo, pageSize string) (InboxPage, error)
+	CountUnread(user_id string) (int64, error)
+	MarkRead(id string, user_id string) error
+	MarkAllRead(user_id string) (int64, error)
+}
+
+type InboxRepositoryInterface interface {
+	EnsureIndexes() error
+	Create(ctx context.Context, notification InboxNotification) error
+	FindByUser(user_id string, unread_only bool, pageNo, pageSize int64) ([]InboxNotification, error)
+	CountUnread(user_id string) (int64, error)
+	MarkRead(id string, user_id string, at time.Time) error
+	MarkAllRead(user_id string, at time.Time) (int64, error)
+	DeleteByUser(user_id string) (int64, error)
+}
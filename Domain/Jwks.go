@@ -0,0 +1,21 @@
+package domain
+
+// JSONWebKey is the public part of a token signing key as published in the JWKS document (RFC 7517).
+type JSONWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+type KeySetProviderInterface interface {
+	JWKS() JSONWebKeySet
+}
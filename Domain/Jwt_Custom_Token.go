@@ -5,6 +5,14 @@ import (
 )
 
 type JwtCustomClaims struct {
-	ID string `json:"id"`
+	ID        string `json:"id"`
+	SessionID string `json:"sid"`
+	Purpose   string `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
-}
\ No newline at end of file
+}
+
+// Purpose of refresh tokens. Access tokens carry no purpose.
+const RefreshTokenPurpose = "refresh"
+
+// Purpose of the short-lived token Login returns when the user still has to pass two-factor authentication.
+const TwoFactorChallengePurpose = "2fa_challenge"
@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"context"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -10,8 +11,40 @@ type Loan struct {
 	ID     primitive.ObjectID `bson:"_id,omitempity" json:"id" `
 	Amount float64            `bson:"amount" json:"amount" validate:"required"`
 	UserId  primitive.ObjectID             `bson:"user_id" json:"user_id" validate:"required"`
+	ReferenceNumber string    `bson:"reference_number,omitempty" json:"reference_number,omitempty"`
+	Product string            `bson:"product" json:"product"`
+	TermMonths int            `bson:"term_months" json:"term_months"`
 	LoanStatus string         `bson:"loan_status" json:"loan_status"`
 	Created_at time.Time	  `bson:"created_at" json:"created_at"`
+	OutstandingBalance float64 `bson:"outstanding_balance" json:"outstanding_balance"`
+	DecidedAt  *time.Time     `bson:"decided_at,omitempty" json:"decided_at,omitempty"`
+	DecidedBy  string         `bson:"decided_by" json:"decided_by,omitempty"`
+	RejectionReason string    `bson:"rejection_reason" json:"rejection_reason,omitempty"`
+	DisbursedAt *time.Time    `bson:"disbursed_at,omitempty" json:"disbursed_at,omitempty"`
+	Installments []Installment `bson:"installments" json:"installments,omitempty"`
+	PaymentIDs []primitive.ObjectID `bson:"payment_ids,omitempty" json:"-"`
+}
+
+// Installment is one scheduled repayment of a disbursed loan.
+type Installment struct {
+	Number     int       `bson:"number" json:"number"`
+	DueDate    time.Time `bson:"due_date" json:"due_date"`
+	Amount     float64   `bson:"amount" json:"amount"`
+	PaidAmount float64   `bson:"paid_amount" json:"paid_amount"`
+	Paid       bool      `bson:"paid" json:"paid"`
+}
+
+// Statuses a loan moves through.
+const (
+	LoanPending   = "pending"
+	LoanApproved  = "approved"
+	LoanRejected  = "rejected"
+	LoanDisbursed = "disbursed"
+	LoanRepaid    = "repaid"
+)
+
+type RejectLoanRequest struct {
+	Reason string `json:"reason" validate:"required"`
 }
 
 
@@ -19,10 +52,21 @@ type LoanUseCaseInterface interface {
 	CreateLoan(loan Loan, user_id string) error
 	CheckLoanStatus(id string, user_id string) (string, error)
 	GetAllLoans(status string, order string, user_id string) ([]Loan, error)
+	ApproveLoan(id string, user_id string, client ClientInfo) error
+	RejectLoan(id string, reason string, user_id string, client ClientInfo) error
+	DisburseLoan(id string, user_id string, client ClientInfo) error
 }
 
 type LoanRepositoryInterface interface {
+	EnsureIndexes() error
 	CreateLoan(loan Loan) error
+	CreateLoanWithContext(ctx context.Context, loan Loan) error
 	GetAllLoans(status string, order string) ([]Loan, error)
 	FindLoanByID(id string)(Loan , error)
+	FindLoansByReference(reference string) ([]Loan, error)
+	FindLoansByUserID(user_id string) ([]Loan, error)
+	CountOpenLoans(user_id string) (int64, error)
+	UpdateLoanWithContext(ctx context.Context, loan Loan, previous_status string) error
+	ApplyPaymentWithContext(ctx context.Context, loan Loan, payment_id primitive.ObjectID, previous_balance float64) error
+	FindLoansWithUnpaidInstallments(due_from time.Time, due_to time.Time) ([]Loan, error)
 }
\ No newline at end of file
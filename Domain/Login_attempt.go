@@ -0,0 +1,17 @@
+package domain
+
+import "time"
+
+// LoginAttempt is the failed login state tracked for one key (an account or a client IP).
+type LoginAttempt struct {
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+}
+
+type LoginAttemptStoreInterface interface {
+	Get(key string) LoginAttempt
+	RecordFailure(key string, at time.Time) LoginAttempt
+	Lock(key string, until time.Time)
+	Reset(key string)
+}
@@ -3,4 +3,5 @@ package domain
 type LoginRequest struct {
 	Email    string `json:"email" validate:"required"`
 	Password string `json:"password" validate:"required"`
+	Device   string `json:"device"`
 }
@@ -0,0 +1,90 @@
+package domain
+
+type EmailAttachment struct {
+	Filename    string
+	ContentType string
+	Content     []byte
+}
+
+// EmailMessage is a transactional email. When both Text and HTML are set the message is sent as
+// multipart/alternative, so clients that cannot render HTML show the text part. Summary is not
+// sent; it is the one-line version other channels use when the template defines one.
+type EmailMessage struct {
+	To          []string
+	Subject     string
+	Summary     string
+	Text        string
+	HTML        string
+	Attachments []EmailAttachment
+}
+
+// Mailer delivers email. The driver is picked from the configuration (SMTP, a directory of
+// .eml files for local development, or an in-memory capture for tests).
+type Mailer interface {
+	Send(message EmailMessage) error
+}
+
+// Names of the transactional email templates.
+const (
+	EmailVerification            = "verification"
+	EmailPasswordReset           = "password_reset"
+	EmailAccountLocked           = "account_locked"
+	EmailEmailChangeConfirmation = "email_change_confirmation"
+	EmailEmailChangeNotice       = "email_change_notice"
+	EmailPasswordChanged         = "password_changed"
+	EmailLoanSubmitted           = "loan_submitted"
+	EmailLoanApproved            = "loan_approved"
+	EmailLoanRejected            = "loan_rejected"
+	EmailLoanDisbursed           = "loan_disbursed"
+	EmailPaymentReceived         = "payment_received"
+	EmailPaymentDue              = "payment_due"
+	EmailPaymentOverdue          = "payment_overdue"
+)
+
+// EmailBranding is exposed to every template as .Brand.
+type EmailBranding struct {
+	Name         string `json:"name"`
+	LogoURL      string `json:"logo_url"`
+	SupportEmail string `json:"support_email"`
+	PrimaryColor string `json:"primary_color"`
+}
+
+// EmailData holds the values templates can use. Each template only reads the fields it needs;
+// Brand and BaseURL are filled in by the renderer.
+type EmailData struct {
+	Name        string
+	Link        string
+	NewEmail    string
+	Until       string
+	ChangedAt   string
+	Reference   string
+	Amount      string
+	Balance     string
+	Reason      string
+	DueDate     string
+	DaysOverdue int
+	Brand       EmailBranding
+	BaseURL     string
+}
+
+type EmailPreview struct {
+	Template string `json:"template"`
+	Locale   string `json:"locale"`
+	Subject  string `json:"subject"`
+	Summary  string `json:"summary,omitempty"`
+	Text     string `json:"text"`
+	HTML     string `json:"html"`
+}
+
+type EmailTemplateList struct {
+	Templates []string `json:"templates"`
+	Locales   []string `json:"locales"`
+}
+
+type EmailTemplatesInterface interface {
+	Render(name string, locale string, data EmailData) (EmailMessage, error)
+	Preview(name string, locale string) (EmailPreview, error)
+	Templates() []string
+	Locales() []string
+	SupportsLocale(locale string) bool
+}
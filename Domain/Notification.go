@@ -0,0 +1,108 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Channels a notification can be delivered through.
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+	ChannelPush  = "push"
+)
+
+// NotificationChannels lists the channels a user can choose from.
+var NotificationChannels = []string{ChannelEmail, ChannelSMS, ChannelPush}
+
+// Categories of notifications. Users can opt out of every category but security.
+const (
+	NotificationSecurity = "security"
+	NotificationLoan     = "loan"
+	NotificationPayment  = "payment"
+)
+
+// NotificationCategories lists the categories a user can opt out of.
+var NotificationCategories = []string{NotificationLoan, NotificationPayment}
+
+// NotificationPreferences are the channels a user wants to be notified on and the categories they
+// opted out of. No channels means the default, email.
+type NotificationPreferences struct {
+	Channels []string `bson:"channels" json:"channels"`
+	OptOuts  []string `bson:"opt_outs" json:"opt_outs"`
+}
+
+type UpdateNotificationPreferencesRequest struct {
+	Channels []string `json:"channels" validate:"required"`
+	OptOuts  []string `json:"opt_outs"`
+}
+
+// PushSubscription is a browser's Web Push subscription, as returned by PushManager.subscribe.
+type PushSubscription struct {
+	Endpoint  string    `bson:"endpoint" json:"endpoint" validate:"required,url"`
+	P256dh    string    `bson:"p256dh" json:"p256dh" validate:"required"`
+	Auth      string    `bson:"auth" json:"auth" validate:"required"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+type PushSubscriptionRequest struct {
+	Endpoint string `json:"endpoint" validate:"required,url"`
+	Keys     struct {
+		P256dh string `json:"p256dh" validate:"required"`
+		Auth   string `json:"auth" validate:"required"`
+	} `json:"keys"`
+}
+
+type SMSMessage struct {
+	To   string `bson:"to" json:"to"`
+	Body string `bson:"body" json:"body"`
+}
+
+// SMSSender delivers text messages, through an HTTP gateway or a local fake.
+type SMSSender interface {
+	Send(message SMSMessage) error
+}
+
+type PushMessage struct {
+	Subscription PushSubscription `bson:"subscription" json:"-"`
+	Title        string           `bson:"title" json:"title"`
+	Body         string           `bson:"body" json:"body"`
+	URL          string           `bson:"url" json:"url,omitempty"`
+}
+
+// PushSender delivers Web Push notifications.
+type PushSender interface {
+	Send(message PushMessage) error
+}
+
+// ErrDeliveryRejected marks a failure that retrying cannot fix, such as an expired push subscription.
+var ErrDeliveryRejected = errors.New("delivery rejected")
+
+// NotifierInterface delivers a notification to a user on the channels they chose. Pass the context
+// of a unit of work to queue it together with the change it reports.
+type NotifierInterface interface {
+	Notify(ctx context.Context, user User, category string, name string, data EmailData) error
+}
+
+// ReminderLog records that the reminder of one stage was sent for an installment. The pair of
+// installment and stage is unique, so a reminder goes out at most once across API replicas.
+type ReminderLog struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	LoanID      primitive.ObjectID `bson:"loan_id" json:"loan_id"`
+	UserID      primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Installment int                `bson:"installment" json:"installment"`
+	Stage       string             `bson:"stage" json:"stage"`
+	Channels    []string           `bson:"channels" json:"channels"`
+	SentAt      time.Time          `bson:"sent_at" json:"sent_at"`
+}
+
+// ErrReminderAlreadySent is returned when the reminder of a stage was already recorded.
+var ErrReminderAlreadySent = errors.New("reminder already sent")
+
+type ReminderRepositoryInterface interface {
+	EnsureIndexes() error
+	Record(ctx context.Context, reminder ReminderLog) error
+}
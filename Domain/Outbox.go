@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Statuses of an outbox message.
+const (
+	OutboxPending = "pending"
+	OutboxSending = "sending"
+	OutboxSent    = "sent"
+	OutboxDead    = "dead"
+)
+
+// OutboxMessage is an email, text message, push notification, webhook or audit record waiting to be
+// delivered. It is written in the same unit of work as the state change it reports, and a
+// background worker delivers it. Log keeps every delivery attempt.
+type OutboxMessage struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	NotificationID primitive.ObjectID `bson:"notification_id,omitempty" json:"notification_id,omitempty"`
+	UserID         primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	Template       string             `bson:"template,omitempty" json:"template,omitempty"`
+	Channel        string             `bson:"channel" json:"channel"`
+	Message        EmailMessage       `bson:"message" json:"message"`
+	SMS            *SMSMessage        `bson:"sms,omitempty" json:"sms,omitempty"`
+	Push           *PushMessage       `bson:"push,omitempty" json:"push,omitempty"`
+	Webhook        *WebhookDelivery   `bson:"webhook,omitempty" json:"webhook,omitempty"`
+	Audit          *AuditRecord       `bson:"audit,omitempty" json:"audit,omitempty"`
+	Status         string             `bson:"status" json:"status"`
+	Attempts       int                `bson:"attempts" json:"attempts"`
+	LastError      string             `bson:"last_error" json:"last_error,omitempty"`
+	NextAttemptAt  time.Time          `bson:"next_attempt_at" json:"next_attempt_at"`
+	LockedUntil    time.Time          `bson:"locked_until" json:"-"`
+	Created_At     time.Time          `bson:"created_at" json:"created_at"`
+	SentAt         *time.Time         `bson:"sent_at" json:"sent_at,omitempty"`
+	Log            []DeliveryAttempt  `bson:"log" json:"log"`
+}
+
+// DeliveryAttempt is one try at delivering an outbox message. Error is empty when it succeeded.
+type DeliveryAttempt struct {
+	At    time.Time `bson:"at" json:"at"`
+	Error string    `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// ErrOutboxLeaseLost is returned when a worker stores the outcome of a message whose lease ran out
+// and was claimed by another worker, which now owns the outcome.
+var ErrOutboxLeaseLost = errors.New("outbox message lease lost")
+
+type OutboxRepositoryInterface interface {
+	Enqueue(ctx context.Context, message EmailMessage) error
+	EnqueueMessage(ctx context.Context, message OutboxMessage) error
+	FindByID(id string) (OutboxMessage, error)
+	ClaimDue(now time.Time, lease time.Duration) (OutboxMessage, error)
+	MarkSent(message OutboxMessage, at time.Time) error
+	MarkFailed(message OutboxMessage) error
+	FindByStatus(status string, pageNo, pageSize int64) ([]OutboxMessage, error)
+	FindByWebhookEndpoint(endpoint_id string, status string, pageNo, pageSize int64) ([]OutboxMessage, error)
+	Retry(id string) error
+	DeleteForRecipient(user_id string, addresses []string) (int64, error)
+}
+
+// UnitOfWorkInterface runs a function whose writes must succeed or fail together. Repository
+// methods taking a context join the unit of work when given the context passed to fn.
+type UnitOfWorkInterface interface {
+	Do(fn func(ctx context.Context) error) error
+}
@@ -0,0 +1,16 @@
+package domain
+
+import "strings"
+
+// PasswordPolicyError lists every password rule a candidate password broke.
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password does not meet the policy: " + strings.Join(e.Violations, "; ")
+}
+
+type BreachedPasswordCheckerInterface interface {
+	IsBreached(password string) (bool, error)
+}
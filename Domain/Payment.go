@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Statuses of an incoming payment. A payment is received, then either posted to a loan or parked in
+// suspense until an admin assigns it.
+const (
+	PaymentStatusReceived = "received"
+	PaymentStatusPosted   = "posted"
+	PaymentStatusSuspense = "suspense"
+)
+
+var (
+	ErrUnknownPaymentProvider     = errors.New("unknown payment provider")
+	ErrInvalidPaymentSignature    = errors.New("invalid payment signature")
+	ErrInvalidPaymentNotification = errors.New("invalid payment notification")
+	ErrDuplicatePayment           = errors.New("payment already received")
+	ErrLoanChanged                = errors.New("loan was changed concurrently")
+)
+
+// PaymentNotification is a payment as reported by a provider, after its signature was verified.
+type PaymentNotification struct {
+	TransactionID string
+	Reference     string
+	Amount        float64
+	Currency      string
+	PaidAt        time.Time
+}
+
+// PaymentProvider verifies and decodes the payment notifications a gateway posts to us.
+type PaymentProvider interface {
+	Name() string
+	Parse(header http.Header, body []byte) (PaymentNotification, error)
+}
+
+// PaymentSimulator produces signed notifications of a local provider, for development.
+type PaymentSimulator interface {
+	PaymentProvider
+	Simulate(notification PaymentNotification) (http.Header, []byte, error)
+}
+
+type Payment struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Provider       string             `bson:"provider" json:"provider"`
+	TransactionID  string             `bson:"transaction_id" json:"transaction_id"`
+	Reference      string             `bson:"reference" json:"reference"`
+	Amount         float64            `bson:"amount" json:"amount"`
+	Currency       string             `bson:"currency,omitempty" json:"currency,omitempty"`
+	PaidAt         time.Time          `bson:"paid_at" json:"paid_at"`
+	Status         string             `bson:"status" json:"status"`
+	SuspenseReason string             `bson:"suspense_reason,omitempty" json:"suspense_reason,omitempty"`
+	LoanID         primitive.ObjectID `bson:"loan_id,omitempty" json:"loan_id,omitempty"`
+	AssignedBy     string             `bson:"assigned_by,omitempty" json:"assigned_by,omitempty"`
+	Received_At    time.Time          `bson:"received_at" json:"received_at"`
+	PostedAt       time.Time          `bson:"posted_at,omitempty" json:"posted_at,omitempty"`
+}
+
+type AssignPaymentRequest struct {
+	LoanID string `json:"loan_id" validate:"required"`
+}
+
+type SimulatePaymentRequest struct {
+	Reference     string  `json:"reference" validate:"required"`
+	Amount        float64 `json:"amount" validate:"required,gt=0"`
+	TransactionID string  `json:"transaction_id"`
+}
+
+type PaymentUseCaseInterface interface {
+	ReceivePayment(provider string, header http.Header, body []byte) (Payment, error)
+	PostPayment(payment Payment, loan_id string, assigned_by string) (Payment, error)
+	GetPayments(status string, pageNo, pageSize string, user_id string) ([]Payment, error)
+	GetPayment(id string, user_id string) (Payment, error)
+	AssignPayment(id string, loan_id string, user_id string, client ClientInfo) (Payment, error)
+	SimulatePayment(request SimulatePaymentRequest, user_id string) (Payment, error)
+}
+
+type PaymentRepositoryInterface interface {
+	EnsureIndexes() error
+	Create(payment Payment) (Payment, error)
+	FindByID(id string) (Payment, error)
+	FindByTransaction(provider string, transaction_id string) (Payment, error)
+	FindByStatus(status string, pageNo, pageSize int64) ([]Payment, error)
+	FindByLoanIDs(loan_ids []primitive.ObjectID) ([]Payment, error)
+	UpdateWithContext(ctx context.Context, payment Payment) error
+}
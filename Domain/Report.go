@@ -0,0 +1,129 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Dimensions a report can be grouped by. Without one, a report has a single row for the whole
+// portfolio.
+const (
+	ReportGroupProduct = "product"
+	ReportGroupStatus  = "status"
+	ReportGroupMonth   = "month"
+)
+
+// ReportTotal is the group of the single row of an ungrouped report.
+const ReportTotal = "total"
+
+// ReportFilter narrows a report to a product and a date range, and chooses how its rows are
+// grouped. Each report applies the range to the date of the event it counts.
+type ReportFilter struct {
+	Product string
+	GroupBy string
+	From    time.Time
+	To      time.Time
+}
+
+// LoanActivityRow counts the applications, decisions and disbursements of a group. Applications
+// are dated by their creation, decisions by when they were made and disbursements by when they
+// were paid out, so with month grouping each is counted in the month it happened.
+type LoanActivityRow struct {
+	Group            string  `bson:"_id" json:"group"`
+	Applied          int64   `bson:"applied" json:"applied"`
+	Approved         int64   `bson:"approved" json:"approved"`
+	Rejected         int64   `bson:"rejected" json:"rejected"`
+	ApprovalRate     float64 `bson:"-" json:"approval_rate"`
+	AvgDecisionHours float64 `bson:"avg_decision_hours" json:"avg_decision_hours"`
+	Disbursed        int64   `bson:"disbursed" json:"disbursed"`
+	DisbursedVolume  float64 `bson:"disbursed_volume" json:"disbursed_volume"`
+}
+
+// PortfolioRow is the state of the disbursed loans of a group when the report runs. A loan is in
+// arrears when one of its installments is unpaid for more than the given number of days; portfolio
+// at risk is the share of the outstanding principal held by loans in arrears.
+type PortfolioRow struct {
+	Group                string  `bson:"_id" json:"group"`
+	Loans                int64   `bson:"loans" json:"loans"`
+	ActiveLoans          int64   `bson:"active_loans" json:"active_loans"`
+	OutstandingPrincipal float64 `bson:"outstanding_principal" json:"outstanding_principal"`
+	Arrears1             float64 `bson:"arrears_1" json:"arrears_1"`
+	Arrears30            float64 `bson:"arrears_30" json:"arrears_30"`
+	Arrears90            float64 `bson:"arrears_90" json:"arrears_90"`
+	PAR1                 float64 `bson:"-" json:"par_1"`
+	PAR30                float64 `bson:"-" json:"par_30"`
+	PAR90                float64 `bson:"-" json:"par_90"`
+}
+
+// CollectionsRow sums the payments posted to the loans of a group.
+type CollectionsRow struct {
+	Group    string  `bson:"_id" json:"group"`
+	Payments int64   `bson:"payments" json:"payments"`
+	Amount   float64 `bson:"amount" json:"amount"`
+}
+
+// PortfolioDashboard gathers the ungrouped totals of every report.
+type PortfolioDashboard struct {
+	AsOf        time.Time       `json:"as_of"`
+	Activity    LoanActivityRow `json:"activity"`
+	Portfolio   PortfolioRow    `json:"portfolio"`
+	Collections CollectionsRow  `json:"collections"`
+}
+
+// VintagePayment is a payment posted to a loan of a vintage report.
+type VintagePayment struct {
+	Amount   float64   `bson:"amount"`
+	PostedAt time.Time `bson:"posted_at"`
+}
+
+// VintageLoan is a disbursed loan with its schedule and the payments posted to it, from which its
+// state at the end of each month on book is rebuilt.
+type VintageLoan struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Amount       float64            `bson:"amount"`
+	DisbursedAt  time.Time          `bson:"disbursed_at"`
+	Installments []Installment      `bson:"installments"`
+	Payments     []VintagePayment   `bson:"payments"`
+}
+
+// VintagePoint is the state of a cohort at the end of a month on book. Rates are shares of the
+// volume the cohort disbursed: the principal repaid so far and the principal still owed by loans
+// more than 30 or 90 days past due. Loans cannot be written off yet, so no write-off rate is given.
+type VintagePoint struct {
+	MonthOnBook          int       `json:"month_on_book"`
+	ObservedAt           time.Time `json:"observed_at"`
+	RepaymentRate        float64   `json:"repayment_rate"`
+	Delinquent30Rate     float64   `json:"delinquent_30_rate"`
+	Delinquent90Rate     float64   `json:"delinquent_90_rate"`
+	OutstandingPrincipal float64   `json:"outstanding_principal"`
+}
+
+// VintageCohort gathers the loans disbursed in a month.
+type VintageCohort struct {
+	Cohort          string         `json:"cohort"`
+	Loans           int            `json:"loans"`
+	DisbursedVolume float64        `json:"disbursed_volume"`
+	Points          []VintagePoint `json:"points"`
+}
+
+type VintageReport struct {
+	AsOf    time.Time       `json:"as_of"`
+	Cohorts []VintageCohort `json:"cohorts"`
+}
+
+type ReportUseCaseInterface interface {
+	GetDashboard(filter ReportFilter, user_id string) (PortfolioDashboard, error)
+	GetLoanActivity(filter ReportFilter, user_id string) ([]LoanActivityRow, error)
+	GetPortfolio(filter ReportFilter, user_id string) ([]PortfolioRow, error)
+	GetCollections(filter ReportFilter, user_id string) ([]CollectionsRow, error)
+	GetVintages(filter ReportFilter, user_id string) (VintageReport, error)
+}
+
+type ReportRepositoryInterface interface {
+	EnsureIndexes() error
+	LoanActivity(filter ReportFilter) ([]LoanActivityRow, error)
+	Portfolio(filter ReportFilter, as_of time.Time) ([]PortfolioRow, error)
+	Collections(filter ReportFilter) ([]CollectionsRow, error)
+	VintageLoans(filter ReportFilter) ([]VintageLoan, error)
+}
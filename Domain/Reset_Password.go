@@ -2,4 +2,9 @@ package domain
 
 type ResetPassword struct {
 	NewPassword string `json:"new_password" validate:"required"`
-}
\ No newline at end of file
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Session struct {
+	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID            primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Device            string             `bson:"device" json:"device"`
+	IP                string             `bson:"ip" json:"ip"`
+	UserAgent         string             `bson:"user_agent" json:"user_agent"`
+	Created_At        time.Time          `bson:"created_at" json:"created_at"`
+	LastUsed_At       time.Time          `bson:"last_used_at" json:"last_used_at"`
+	ExpiresAt         time.Time          `bson:"expires_at" json:"expires_at"`
+	TwoFactorVerified bool               `bson:"two_factor_verified" json:"two_factor_verified"`
+	Revoked           bool               `bson:"revoked" json:"revoked"`
+	RevokedAt         time.Time          `bson:"revoked_at" json:"revoked_at"`
+	Current           bool               `bson:"-" json:"current"`
+}
+
+// ClientInfo describes the client a request came from. It is recorded on the session created at login
+// and on the audit records of the request.
+type ClientInfo struct {
+	Device    string
+	IP        string
+	UserAgent string
+	RequestID string
+}
+
+type SessionRepositoryInterface interface {
+	CreateSessionWithContext(ctx context.Context, session Session) (Session, error)
+	FindSessionByID(id string) (Session, error)
+	FindSessionsByUserID(user_id string) ([]Session, error)
+	TouchSession(id string, at time.Time) error
+	MarkSessionTwoFactorVerified(id string) error
+	RevokeSession(id string) error
+	RevokeUserSessions(user_id string, except string) error
+	RevokeUserSessionsWithContext(ctx context.Context, user_id string, except string) error
+	FindSessionHistory(user_id string) ([]Session, error)
+	AnonymizeUserSessions(user_id string) error
+}
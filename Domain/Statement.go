@@ -0,0 +1,118 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// StatementMapping tells how to read one bank's statement CSV. Columns are named by their header.
+// Amount is read from AmountColumn, or from CreditColumn for banks that split credits and debits;
+// only credits are imported. DateFormat is a Go time layout.
+type StatementMapping struct {
+	Bank                string    `bson:"_id" json:"bank"`
+	Delimiter           string    `bson:"delimiter" json:"delimiter"`
+	SkipRows            int       `bson:"skip_rows" json:"skip_rows"`
+	DateColumn          string    `bson:"date_column" json:"date_column" validate:"required"`
+	DateFormat          string    `bson:"date_format" json:"date_format"`
+	AmountColumn        string    `bson:"amount_column" json:"amount_column" validate:"required_without=CreditColumn"`
+	CreditColumn        string    `bson:"credit_column" json:"credit_column"`
+	DecimalComma        bool      `bson:"decimal_comma" json:"decimal_comma"`
+	ReferenceColumn     string    `bson:"reference_column" json:"reference_column"`
+	NameColumn          string    `bson:"name_column" json:"name_column"`
+	DescriptionColumn   string    `bson:"description_column" json:"description_column"`
+	TransactionIDColumn string    `bson:"transaction_id_column" json:"transaction_id_column"`
+	Updated_At          time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// Statuses of a statement import.
+const (
+	StatementPreview   = "preview"
+	StatementConfirmed = "confirmed"
+)
+
+// Statuses of a statement line. Lines are matched, in review or unmatched in the preview. Confirming
+// posts the chosen ones, or sends them to the payment suspense queue when the loan cannot take
+// them, and skips the others.
+const (
+	LineMatched   = "matched"
+	LineReview    = "review"
+	LineUnmatched = "unmatched"
+	LineIgnored   = "ignored"
+	LineInvalid   = "invalid"
+	LineDuplicate = "duplicate"
+	LinePosted    = "posted"
+	LineSuspense  = "suspense"
+	LineSkipped   = "skipped"
+	LineFailed    = "failed"
+)
+
+// StatementLine is a credit read from a statement with the loan proposed for it. Confidence goes
+// from 0 to 100 and Reasons lists what it is made of. TransactionKey identifies the bank
+// transaction across uploads; posted lines are stored as payments under it.
+type StatementLine struct {
+	Number         int                `bson:"number" json:"number"`
+	Date           time.Time          `bson:"date" json:"date"`
+	Amount         float64            `bson:"amount" json:"amount"`
+	Reference      string             `bson:"reference" json:"reference,omitempty"`
+	Name           string             `bson:"name" json:"name,omitempty"`
+	Description    string             `bson:"description" json:"description,omitempty"`
+	TransactionKey string             `bson:"transaction_key" json:"transaction_key"`
+	Status         string             `bson:"status" json:"status"`
+	Error          string             `bson:"error,omitempty" json:"error,omitempty"`
+	LoanID         primitive.ObjectID `bson:"loan_id,omitempty" json:"loan_id,omitempty"`
+	Confidence     int                `bson:"confidence" json:"confidence"`
+	Reasons        []string           `bson:"reasons,omitempty" json:"reasons,omitempty"`
+	PaymentID      primitive.ObjectID `bson:"payment_id,omitempty" json:"payment_id,omitempty"`
+}
+
+// StatementImport is an uploaded statement file. The same file uploaded again for the same bank
+// returns this import instead of creating another. It stays a preview until lines are confirmed;
+// lines skipped or failed then can still be confirmed later.
+type StatementImport struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Bank        string             `bson:"bank" json:"bank"`
+	Filename    string             `bson:"filename" json:"filename"`
+	FileHash    string             `bson:"file_hash" json:"file_hash"`
+	Status      string             `bson:"status" json:"status"`
+	Lines       []StatementLine    `bson:"lines" json:"lines,omitempty"`
+	Created_At  time.Time          `bson:"created_at" json:"created_at"`
+	CreatedBy   string             `bson:"created_by" json:"created_by"`
+	ConfirmedAt time.Time          `bson:"confirmed_at,omitempty" json:"confirmed_at,omitempty"`
+	ConfirmedBy string             `bson:"confirmed_by,omitempty" json:"confirmed_by,omitempty"`
+}
+
+// StatementConfirmation confirms one line. LoanID overrides the proposed loan.
+type StatementConfirmation struct {
+	Line   int    `json:"line" validate:"required"`
+	LoanID string `json:"loan_id"`
+}
+
+// ConfirmStatementRequest lists the lines to post. Without lines, every matched line is posted.
+type ConfirmStatementRequest struct {
+	Lines []StatementConfirmation `json:"lines" validate:"dive"`
+}
+
+type StatementUseCaseInterface interface {
+	GetMappings(user_id string) ([]StatementMapping, error)
+	SaveMapping(bank string, mapping StatementMapping, user_id string) (StatementMapping, error)
+	DeleteMapping(bank string, user_id string) error
+	ImportStatement(bank string, filename string, content []byte, user_id string) (StatementImport, error)
+	GetImports(pageNo, pageSize string, user_id string) ([]StatementImport, error)
+	GetImport(id string, user_id string) (StatementImport, error)
+	ConfirmImport(id string, request ConfirmStatementRequest, user_id string) (StatementImport, error)
+}
+
+type StatementRepositoryInterface interface {
+	EnsureIndexes() error
+	FindMappings() ([]StatementMapping, error)
+	FindMapping(bank string) (StatementMapping, error)
+	SaveMapping(mapping StatementMapping) error
+	DeleteMapping(bank string) error
+	CreateImport(statement StatementImport) (StatementImport, error)
+	FindImportByHash(bank string, file_hash string) (StatementImport, error)
+	FindImportByID(id string) (StatementImport, error)
+	FindImports(pageNo, pageSize int64) ([]StatementImport, error)
+	UpdateImport(statement StatementImport) error
+	EraseLineNames(loan_ids []primitive.ObjectID) (int64, error)
+}
@@ -11,5 +11,19 @@ type UserProfile struct {
 	User_Name  string             `bson:"user_name"  json:"user_name"`
 	Email      string             `bson:"email" validate:"required,email" json:"email"`
 	Contact    string             `bson:"contact" json:"contact"`
+	PendingEmail string           `bson:"pending_email" json:"pending_email,omitempty"`
+	Locale     string             `bson:"locale" json:"locale"`
 	Created_At time.Time          `bson:"created_at" json:"created_at"`
-}
\ No newline at end of file
+}
+
+// UpdateProfileRequest carries the profile fields a user may change. Omitted fields are left as they are.
+type UpdateProfileRequest struct {
+	User_Name *string `json:"user_name"`
+	Contact   *string `json:"contact"`
+	Locale    *string `json:"locale"`
+}
+
+type EmailChangeRequest struct {
+	NewEmail string `json:"new_email" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
@@ -0,0 +1,154 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ChannelWebhook is the outbox channel of webhook deliveries. Unlike the notification channels it
+// cannot be chosen by users.
+const ChannelWebhook = "webhook"
+
+// Types of the events sent to webhook endpoints.
+const (
+	EventLoanCreated     = "loan.created"
+	EventLoanApproved    = "loan.approved"
+	EventLoanRejected    = "loan.rejected"
+	EventLoanDisbursed   = "loan.disbursed"
+	EventLoanOverdue     = "loan.overdue"
+	EventPaymentReceived = "payment.received"
+	EventUserVerified    = "user.verified"
+)
+
+var WebhookEventTypes = []string{
+	EventLoanCreated,
+	EventLoanApproved,
+	EventLoanRejected,
+	EventLoanDisbursed,
+	EventLoanOverdue,
+	EventPaymentReceived,
+	EventUserVerified,
+}
+
+// WebhookSchemaVersion is the version of the event payloads. Fields may be added within a version;
+// renaming, retyping or removing one requires a new version.
+const WebhookSchemaVersion = 1
+
+// WebhookEvent is the JSON body posted to webhook endpoints. Data is one of WebhookLoan (loan.*
+// except loan.overdue), WebhookOverdue, WebhookPayment or WebhookUser.
+type WebhookEvent struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+type WebhookLoan struct {
+	LoanID             string     `json:"loan_id"`
+	ReferenceNumber    string     `json:"reference_number"`
+	UserID             string     `json:"user_id"`
+	Status             string     `json:"status"`
+	Product            string     `json:"product"`
+	Amount             float64    `json:"amount"`
+	TermMonths         int        `json:"term_months"`
+	OutstandingBalance float64    `json:"outstanding_balance"`
+	RejectionReason    string     `json:"rejection_reason,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	DecidedAt          *time.Time `json:"decided_at,omitempty"`
+	DisbursedAt        *time.Time `json:"disbursed_at,omitempty"`
+}
+
+type WebhookOverdue struct {
+	LoanID      string    `json:"loan_id"`
+	UserID      string    `json:"user_id"`
+	Installment int       `json:"installment"`
+	DueDate     time.Time `json:"due_date"`
+	AmountDue   float64   `json:"amount_due"`
+	DaysOverdue int       `json:"days_overdue"`
+}
+
+type WebhookPayment struct {
+	LoanID             string    `json:"loan_id"`
+	UserID             string    `json:"user_id"`
+	Amount             float64   `json:"amount"`
+	OutstandingBalance float64   `json:"outstanding_balance"`
+	TransactionID      string    `json:"transaction_id,omitempty"`
+	ReceivedAt         time.Time `json:"received_at"`
+}
+
+type WebhookUser struct {
+	UserID     string    `json:"user_id"`
+	VerifiedAt time.Time `json:"verified_at"`
+}
+
+// WebhookEndpoint is a partner URL subscribed to some event types. Deliveries are signed with Secret.
+type WebhookEndpoint struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	URL         string             `bson:"url" json:"url"`
+	Secret      string             `bson:"secret" json:"-"`
+	Events      []string           `bson:"events" json:"events"`
+	Description string             `bson:"description" json:"description,omitempty"`
+	Active      bool               `bson:"active" json:"active"`
+	Created_At  time.Time          `bson:"created_at" json:"created_at"`
+	Updated_At  time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+type CreateWebhookRequest struct {
+	URL         string   `json:"url" validate:"required,url"`
+	Secret      string   `json:"secret" validate:"required,min=16"`
+	Events      []string `json:"events" validate:"required,min=1"`
+	Description string   `json:"description"`
+}
+
+// UpdateWebhookRequest changes the fields that are set and leaves the others as they are.
+type UpdateWebhookRequest struct {
+	URL         *string  `json:"url" validate:"omitempty,url"`
+	Secret      *string  `json:"secret" validate:"omitempty,min=16"`
+	Events      []string `json:"events" validate:"omitempty,min=1"`
+	Description *string  `json:"description"`
+	Active      *bool    `json:"active"`
+}
+
+// WebhookDelivery is the content of a webhook outbox message. Body is the event JSON, kept as
+// serialized so that retries and replays send the same bytes. ReplayOf is set on replays.
+type WebhookDelivery struct {
+	EndpointID primitive.ObjectID `bson:"endpoint_id" json:"endpoint_id"`
+	EventID    string             `bson:"event_id" json:"event_id"`
+	Event      string             `bson:"event" json:"event"`
+	Body       string             `bson:"body" json:"body"`
+	ReplayOf   primitive.ObjectID `bson:"replay_of,omitempty" json:"replay_of,omitempty"`
+}
+
+// WebhookSender posts a delivery to its endpoint. Errors wrapping ErrDeliveryRejected are not retried.
+type WebhookSender interface {
+	Send(endpoint WebhookEndpoint, delivery WebhookDelivery) error
+}
+
+// WebhookPublisherInterface queues an event for every active endpoint subscribed to its type, in
+// the unit of work carried by ctx.
+type WebhookPublisherInterface interface {
+	Publish(ctx context.Context, event string, data any) error
+}
+
+type WebhookUseCaseInterface interface {
+	EventTypes() []string
+	CreateWebhook(request CreateWebhookRequest, user_id string) (WebhookEndpoint, error)
+	GetWebhooks(user_id string) ([]WebhookEndpoint, error)
+	GetWebhook(id string, user_id string) (WebhookEndpoint, error)
+	UpdateWebhook(id string, request UpdateWebhookRequest, user_id string) (WebhookEndpoint, error)
+	DeleteWebhook(id string, user_id string) error
+	GetDeliveries(id string, status string, pageNo, pageSize string, user_id string) ([]OutboxMessage, error)
+	ReplayDelivery(id string, delivery_id string, user_id string) error
+}
+
+type WebhookRepositoryInterface interface {
+	Create(endpoint WebhookEndpoint) (WebhookEndpoint, error)
+	FindByID(id string) (WebhookEndpoint, error)
+	FindAll() ([]WebhookEndpoint, error)
+	FindSubscribed(ctx context.Context, event string) ([]WebhookEndpoint, error)
+	Update(endpoint WebhookEndpoint) error
+	Delete(id string) error
+}
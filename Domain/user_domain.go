@@ -1,55 +1,168 @@
 package domain
 
 import (
+	"context"
+	"errors"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+var (
+	ErrEmailTaken    = errors.New("email already in use")
+	ErrUserNameTaken = errors.New("user name already taken")
+)
+
 type User struct {
 	ID                   primitive.ObjectID   `bson:"_id,omitempity" json:"id" `
 	User_Name            string               `bson:"user_name"  json:"user_name"`
 	Email 				 string 			  `bson:"email" validate:"required,email" json:"email"`
-    Password             string               `bson:"password" json:"password" validate:"required"`
+    Password             string               `bson:"password" json:"-" validate:"required"`
     Contact              string               `bson:"contact" json:"contact"`
+	Locale               string               `bson:"locale" json:"locale"`
+	NotificationPreferences NotificationPreferences `bson:"notification_preferences" json:"notification_preferences"`
+	PushSubscriptions    []PushSubscription   `bson:"push_subscriptions" json:"-"`
 	IsVerified			 bool 				  `bson:"is_verified" json:"is_verified"`
 	Created_At		     time.Time			  `bson:"created_at" json:"created_at"`
-    ResetPasswordToken   string               `bson:"reset_password_token" json:"reset_password_token"`
-    ResetPasswordExpires time.Time            `bson:"reset_password_expires" json:"reset_password_expires"`
-	VerificationToken    string               `bson:"verification_token" json:"verification_token"`
+    ResetPasswordToken   string               `bson:"reset_password_token" json:"-"`
+    ResetPasswordExpires time.Time            `bson:"reset_password_expires" json:"-"`
+	VerificationToken    string               `bson:"verification_token" json:"-"`
 	VerificationExpires  time.Time            `bson:"verification_expires" json:"verification_expires"`
+	VerificationSentAt   time.Time            `bson:"verification_sent_at" json:"-"`
+	VerificationSendCount int                 `bson:"verification_send_count" json:"-"`
+	PendingEmail         string               `bson:"pending_email" json:"pending_email,omitempty"`
+	EmailChangeToken     string               `bson:"email_change_token" json:"-"`
+	EmailChangeExpires   time.Time            `bson:"email_change_expires" json:"-"`
+	PasswordHistory      []string             `bson:"password_history" json:"-"`
+	PasswordChangedAt    time.Time            `bson:"password_changed_at" json:"password_changed_at"`
 	Role 			   	 string               `bson:"role" json:"role"`
+	TwoFactorEnabled     bool                 `bson:"two_factor_enabled" json:"two_factor_enabled"`
+	TwoFactorSecret      string               `bson:"two_factor_secret" json:"-"`
+	TwoFactorPendingSecret string             `bson:"two_factor_pending_secret" json:"-"`
+	TwoFactorLastStep    int64                `bson:"two_factor_last_step" json:"-"`
+	RecoveryCodes        []string             `bson:"recovery_codes" json:"-"`
+	IsSuspended          bool                 `bson:"is_suspended" json:"is_suspended"`
+	SuspendedAt          time.Time            `bson:"suspended_at" json:"suspended_at,omitempty"`
+	SuspendedBy          string               `bson:"suspended_by" json:"suspended_by,omitempty"`
+	SuspensionReason     string               `bson:"suspension_reason" json:"suspension_reason,omitempty"`
+	DeletedAt            *time.Time           `bson:"deleted_at" json:"deleted_at,omitempty"`
+	DeletedBy            string               `bson:"deleted_by" json:"deleted_by,omitempty"`
+	DeletionReason       string               `bson:"deletion_reason" json:"deletion_reason,omitempty"`
+	ErasedAt             *time.Time           `bson:"erased_at" json:"erased_at,omitempty"`
+}
+
+// Roles a user can hold.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Account statuses the admin user search can filter by.
+const (
+	UserStatusActive     = "active"
+	UserStatusSuspended  = "suspended"
+	UserStatusUnverified = "unverified"
+	UserStatusDeleted    = "deleted"
+)
+
+// UserSearchFilter narrows the admin user search. Empty fields do not filter. Deleted users
+// are only returned when Status is UserStatusDeleted.
+type UserSearchFilter struct {
+	Email            string
+	Name             string
+	Status           string
+	RegisteredAfter  time.Time
+	RegisteredBefore time.Time
+}
+
+// AdminUserDetail is the full record of one user as seen by an admin.
+type AdminUserDetail struct {
+	User  User   `json:"user"`
+	Loans []Loan `json:"loans"`
+}
+
+type SuspendUserRequest struct {
+	Reason string `json:"reason" validate:"required"`
+}
+
+type ChangeRoleRequest struct {
+	Role string `json:"role" validate:"required"`
+}
+
+type CloseAccountRequest struct {
+	Password string `json:"password" validate:"required"`
+	Reason   string `json:"reason"`
 }
 
 
 type UserUseCaseInterface interface {
 	RegisterUser(user User) error
-	VerifyEmail(email string, token string) error
-	Login(user User)(LoginResponse, error)
-	CreateAccessToken(user *User, secret string, expiry int) (accessToken string, err error)
-	CreateRefreshToken(user *User, secret string, expiry int) (refreshToken string, err error)
+	VerifyEmail(email string, token string, expires string, signature string) error
+	ResendVerificationEmail(email string) error
+	Login(user User, client ClientInfo)(LoginResponse, error)
+	CreateAccessToken(user *User, session_id string, expiry int) (accessToken string, err error)
+	CreateRefreshToken(user *User, session_id string, expiry int) (refreshToken string, err error)
 	RefreshToken(request RefreshTokenRequest, user_id string) (RefreshTokenResponse, error)
+	Logout(session_id string, user_id string) error
+	GetSessions(user_id string, current_session string) ([]Session, error)
+	RevokeSession(id string, user_id string) error
+	EnrollTwoFactor(user_id string) (TwoFactorEnrollment, error)
+	ConfirmTwoFactor(user_id string, session_id string, code string, client ClientInfo) ([]string, error)
+	DisableTwoFactor(user_id string, code string, client ClientInfo) error
+	RegenerateRecoveryCodes(user_id string, code string, client ClientInfo) ([]string, error)
+	VerifyTwoFactorLogin(challenge_token string, code string, client ClientInfo) (LoginResponse, error)
 	GetUserProfile(id string)(UserProfile, error)
-	ResetPassword(email string, user_id string)error
-	ResetPasswordVerify(email string, token string, user_id string, password string) error
+	UpdateProfile(id string, request UpdateProfileRequest)(UserProfile, error)
+	GetNotificationPreferences(id string) (NotificationPreferences, error)
+	UpdateNotificationPreferences(id string, request UpdateNotificationPreferencesRequest) (NotificationPreferences, error)
+	PushPublicKey() string
+	AddPushSubscription(id string, request PushSubscriptionRequest) error
+	RemovePushSubscription(id string, endpoint string) error
+	RequestEmailChange(id string, new_email string, password string) error
+	ConfirmEmailChange(id string, token string) error
+	ResetPassword(email string)error
+	ResetPasswordVerify(email string, token string, password string, client ClientInfo) error
+	ChangePassword(id string, session_id string, current_password string, new_password string, client ClientInfo) error
+	CloseAccount(id string, password string, reason string) error
 }
 
 
 type UserRepositoryInterface interface {
+	EnsureIndexes() error
 	RegisterUser(user User) error
+	RegisterUserWithContext(ctx context.Context, user User) error
 	FindUserByEmail(email string) (User, error)
 	UpdateUser(user User) error
+	UpdateUserWithContext(ctx context.Context, user User) error
 	FindUserByUserName(username string) (User, error)
 	FindUserByID(id string)(User, error)
+	DeleteUserWithContext(ctx context.Context, id string, deleted_by string, reason string, deleted_at time.Time) error
 }
 
 
 type AdminUseCaseInterface interface {
 	GetAllUsers(pageNo, pageSize string, user_id string) ([]User, error)
-	DeleteUser(id string, user_id string) (bool, error)
+	DeleteUser(id string, reason string, user_id string, client ClientInfo) (bool, error)
+	RestoreUser(id string, user_id string, client ClientInfo) error
+	ListEmailTemplates(user_id string) (EmailTemplateList, error)
+	PreviewEmail(name string, locale string, user_id string) (EmailPreview, error)
+	ListDeliveries(status string, pageNo, pageSize string, user_id string) ([]OutboxMessage, error)
+	GetDelivery(id string, user_id string) (OutboxMessage, error)
+	RetryDelivery(id string, user_id string) error
+	RevokeUserSessions(id string, user_id string, client ClientInfo) error
+	UnlockUser(id string, user_id string, client ClientInfo) error
+	GetUser(id string, user_id string) (AdminUserDetail, error)
+	SearchUsers(filter UserSearchFilter, pageNo, pageSize string, user_id string) ([]User, error)
+	SuspendUser(id string, reason string, user_id string, client ClientInfo) error
+	ReactivateUser(id string, user_id string, client ClientInfo) error
+	ForceVerifyUser(id string, user_id string, client ClientInfo) error
+	TriggerPasswordReset(id string, user_id string, client ClientInfo) error
+	ChangeUserRole(id string, role string, user_id string, client ClientInfo) error
 }
 
 type AdminRepositoryInterface interface {
 	GetAllUsers(pageNo, pageSize int64) ([]User, error)
-	DeleteUser(id string) error
+	SearchUsers(filter UserSearchFilter, pageNo, pageSize int64) ([]User, error)
+	FindDeletedUserByID(id string) (User, error)
+	RestoreUserWithContext(ctx context.Context, id string) error
 }
\ No newline at end of file
@@ -2,9 +2,18 @@ package infrastructure
 
 import (
 	"errors"
+	"fmt"
+	domain "loan-tracker/Domain"
+	"log"
 	"regexp"
+	"strings"
 )
 
+// NormalizeEmail lower-cases an email address, the form emails are stored and looked up in.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
 func ValidateEmail(email string) error {
 	regex := regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
 	if !regex.MatchString(email) {
@@ -13,31 +22,83 @@ func ValidateEmail(email string) error {
 	return nil
 }
 
-func ValidatePassword(password string) error {
-	if len(password) < 8 || len(password) > 30 {
-		return errors.New("password must be between 8 and 30 characters long")
+func ValidateContact(contact string) error {
+	regex := regexp.MustCompile(`^\+?[0-9][0-9 ()-]{5,18}[0-9]$`)
+	if !regex.MatchString(contact) {
+		return errors.New("invalid contact format: use a phone number such as +251911234567")
 	}
+	return nil
+}
+
+// PasswordPolicy holds the configurable rules a new password has to satisfy.
+type PasswordPolicy struct {
+	MinLength          int
+	MaxLength          int
+	RequireUpper       bool
+	RequireLower       bool
+	RequireNumber      bool
+	RequireSpecial     bool
+	RejectPersonalInfo bool
+	BannedWords        []string
+	Breached           domain.BreachedPasswordCheckerInterface
+}
+
+var (
+	hasUpper   = regexp.MustCompile(`[A-Z]`).MatchString
+	hasLower   = regexp.MustCompile(`[a-z]`).MatchString
+	hasNumber  = regexp.MustCompile(`[0-9]`).MatchString
+	hasSpecial = regexp.MustCompile(`[!@#~$%^&*()_+|<>?:{}]`).MatchString
+)
 
-	var (
-		hasUpper   = regexp.MustCompile(`[A-Z]`).MatchString
-		hasLower   = regexp.MustCompile(`[a-z]`).MatchString
-		hasNumber  = regexp.MustCompile(`[0-9]`).MatchString
-		hasSpecial = regexp.MustCompile(`[!@#~$%^&*()_+|<>?:{}]`).MatchString
-	)
+// Validate checks the password against every rule and returns a *domain.PasswordPolicyError listing
+// all the rules it breaks. The user name and email are used to reject passwords built from them.
+func (policy PasswordPolicy) Validate(password string, userName string, email string) error {
+	violations := []string{}
 
-	if !hasUpper(password) {
-		return errors.New("password must contain at least one uppercase letter")
+	if len(password) < policy.MinLength || len(password) > policy.MaxLength {
+		violations = append(violations, fmt.Sprintf("password must be between %d and %d characters long", policy.MinLength, policy.MaxLength))
 	}
-	if !hasLower(password) {
-		return errors.New("password must contain at least one lowercase letter")
+	if policy.RequireUpper && !hasUpper(password) {
+		violations = append(violations, "password must contain at least one uppercase letter")
 	}
-	if !hasNumber(password) {
-		return errors.New("password must contain at least one number")
+	if policy.RequireLower && !hasLower(password) {
+		violations = append(violations, "password must contain at least one lowercase letter")
 	}
-	if !hasSpecial(password) {
-		return errors.New("password must contain at least one special character")
+	if policy.RequireNumber && !hasNumber(password) {
+		violations = append(violations, "password must contain at least one number")
+	}
+	if policy.RequireSpecial && !hasSpecial(password) {
+		violations = append(violations, "password must contain at least one special character")
+	}
+
+	lower := strings.ToLower(password)
+	if policy.RejectPersonalInfo {
+		localPart := strings.SplitN(email, "@", 2)[0]
+		for _, personal := range []string{userName, localPart} {
+			personal = strings.ToLower(strings.TrimSpace(personal))
+			if len(personal) >= 3 && strings.Contains(lower, personal) {
+				violations = append(violations, "password must not contain your user name or email")
+				break
+			}
+		}
+	}
+	for _, word := range policy.BannedWords {
+		if word != "" && strings.Contains(lower, strings.ToLower(word)) {
+			violations = append(violations, fmt.Sprintf("password must not contain the word %q", word))
+		}
 	}
 
+	if policy.Breached != nil {
+		breached, err := policy.Breached.IsBreached(password)
+		if err != nil {
+			log.Println("error checking breached passwords:", err)
+		} else if breached {
+			violations = append(violations, "password appears in a list of breached passwords")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &domain.PasswordPolicyError{Violations: violations}
+	}
 	return nil
 }
-
@@ -1,21 +1,18 @@
 package infrastructure
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"net/url"
+	"strconv"
 	"time"
-
-	"gopkg.in/gomail.v2"
 )
 
 
 const (
-    SmtpHost      = "smtp.gmail.com"        
-    SmtpPort      = 465                     
-    EmailFrom     = "bezueyerusalem@gmail.com" 
-    EmailPassword = "fxtf szty wpmy sznn"        
-    ServerHost    = "http://localhost:8080"   
     TokenTTlL      = time.Hour               
 )
 
@@ -28,57 +25,57 @@ func GenerateVerificationToken() (string, error) {
     return hex.EncodeToString(bytes), nil
 }
 
-// Sends the password reset email
-func SendVerificationEmail(to, token string) error {
-	verificationLink := fmt.Sprintf("%s/users/verify-email?token=%s&email=%s", ServerHost, token, to)
-    body := fmt.Sprintf(`
-        Hi,
-		Please Verify Your Account by clicking the link below:
-        %s
-
-        If you did not request this, please ignore this email.
-    `, verificationLink)
-
-    m := gomail.NewMessage()
-    m.SetHeader("From", fmt.Sprintf("%s <%s>", "Eyerusalem Loan Tracking Project", EmailFrom))
-    m.SetHeader("To", to)
-    m.SetHeader("Subject", "Email Verification")
-    m.SetBody("text/plain", body)
+// HashToken returns the hex encoded SHA-256 of a token so it can be stored instead of the token itself
+func HashToken(token string) string {
+    sum := sha256.Sum256([]byte(token))
+    return hex.EncodeToString(sum[:])
+}
 
-    d := gomail.NewDialer(SmtpHost, SmtpPort, EmailFrom, EmailPassword)
-    d.SSL = true
+func verificationSignature(email string, token string, expires int64, secret string) string {
+    mac := hmac.New(sha256.New, []byte(secret))
+    mac.Write([]byte(email + "\n" + token + "\n" + strconv.FormatInt(expires, 10)))
+    return hex.EncodeToString(mac.Sum(nil))
+}
 
-    if err := d.DialAndSend(m); err != nil {
-        return err
+// VerificationLink builds the email verification link under the public base URL. When a secret is given the link also carries
+// its expiry and an HMAC signature over the email, token and expiry, so tokens read from a database
+// dump cannot be turned into working links without the secret.
+func VerificationLink(baseURL string, email string, token string, expires time.Time, secret string) string {
+    query := url.Values{}
+    query.Set("email", email)
+    query.Set("token", token)
+    if secret != "" {
+        query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+        query.Set("signature", verificationSignature(email, token, expires.Unix(), secret))
     }
-
-
-    return nil
+    return fmt.Sprintf("%s/users/verify-email?%s", baseURL, query.Encode())
 }
 
+// ValidVerificationSignature checks the signature and expiry carried by a signed verification link.
+func ValidVerificationSignature(email string, token string, expires string, signature string, secret string) bool {
+    expiresAt, err := strconv.ParseInt(expires, 10, 64)
+    if err != nil || time.Now().Unix() > expiresAt {
+        return false
+    }
+    expected := verificationSignature(email, token, expiresAt, secret)
+    return hmac.Equal([]byte(expected), []byte(signature))
+}
 
-// Sends the password reset email
-func SendResetPasswordVerificationEmail(to, token string) error {
-	verificationLink := fmt.Sprintf("%s/users/password-update?token=%s&email=%s", ServerHost, token, to)
-    body := fmt.Sprintf(`
-        Hi,
-		Please Click the link below to reset your password:
-        %s
 
-        If you did not request this, please ignore this email.
-    `, verificationLink)
+// EmailChangeLink builds the link that confirms a pending email change
+func EmailChangeLink(baseURL string, user_id string, token string) string {
+    query := url.Values{}
+    query.Set("user", user_id)
+    query.Set("token", token)
+    return fmt.Sprintf("%s/users/email-change/confirm?%s", baseURL, query.Encode())
+}
 
-    m := gomail.NewMessage()
-    m.SetHeader("From", fmt.Sprintf("%s <%s>", "Eyerusalem Loan Tracking Project", EmailFrom))
-    m.SetHeader("To", to)
-    m.SetHeader("Subject", "Reset Password Verification")
-    m.SetBody("text/plain", body)
+// PasswordResetLink builds the link that completes a password reset
+func PasswordResetLink(baseURL string, email string, token string) string {
+    query := url.Values{}
+    query.Set("token", token)
+    query.Set("email", email)
+    return fmt.Sprintf("%s/users/password-update?%s", baseURL, query.Encode())
+}
 
-    d := gomail.NewDialer(SmtpHost, SmtpPort, EmailFrom, EmailPassword)
-    d.SSL = true
 
-    if err := d.DialAndSend(m); err != nil {
-        return err
-    }
-    return nil
-}
\ No newline at end of file
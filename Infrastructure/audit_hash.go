@@ -0,0 +1,68 @@
+package infrastructure
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	domain "loan-tracker/Domain"
+)
+
+// AuditTimestamp rounds a time to what MongoDB stores, so a record hashes the same once read back.
+func AuditTimestamp(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Millisecond)
+}
+
+// NewAuditSalt returns a random salt for the client digest of an audit record.
+func NewAuditSalt() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// AuditClientDigest returns the hex SHA-256 of a record's salt, IP and user agent. Without the salt,
+// which is erased with them, the digest cannot be traced back to the client.
+func AuditClientDigest(salt string, ip string, user_agent string) string {
+	sum := sha256.Sum256([]byte(salt + "\x00" + ip + "\x00" + user_agent))
+	return hex.EncodeToString(sum[:])
+}
+
+// HashAuditRecord returns the hex SHA-256 of the record's content, sequence and previous hash. The
+// database id and the hash itself are left out, and the client is covered by its digest.
+func HashAuditRecord(record domain.AuditRecord) string {
+	changes := record.Changes
+	if len(changes) == 0 {
+		changes = nil
+	}
+	content, _ := json.Marshal(struct {
+		Sequence   int64                `json:"sequence"`
+		Category   string               `json:"category"`
+		Action     string               `json:"action"`
+		ActorID    string               `json:"actor_id"`
+		TargetType string               `json:"target_type"`
+		TargetID   string               `json:"target_id"`
+		Changes    []domain.AuditChange `json:"changes"`
+		Client     string               `json:"client"`
+		RequestID  string               `json:"request_id"`
+		CreatedAt  string               `json:"created_at"`
+		PrevHash   string               `json:"prev_hash"`
+	}{
+		Sequence:   record.Sequence,
+		Category:   record.Category,
+		Action:     record.Action,
+		ActorID:    record.ActorID,
+		TargetType: record.TargetType,
+		TargetID:   record.TargetID,
+		Changes:    changes,
+		Client:     record.ClientDigest,
+		RequestID:  record.RequestID,
+		CreatedAt:  AuditTimestamp(record.Created_At).Format(time.RFC3339Nano),
+		PrevHash:   record.PrevHash,
+	})
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -1,23 +1,35 @@
 package infrastructure
 
 import (
-	"fmt"
+	domain "loan-tracker/Domain"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// How stale a session's last-used time may get before a request refreshes it.
+const sessionTouchInterval = time.Minute
+
 type Auth struct{
 	env Config
+	tokens *TokenService
+	sessions domain.SessionRepositoryInterface
+	users domain.UserRepositoryInterface
 }
 type AuthInterface interface{
 	AuthenticationMiddleware() gin.HandlerFunc
+	TwoFactorMiddleware() gin.HandlerFunc
+	AdminMiddleware() gin.HandlerFunc
 }
 
-func NewAuthMiddleware (env Config)*Auth{
+func NewAuthMiddleware (env Config, tokens *TokenService, sessions domain.SessionRepositoryInterface, users domain.UserRepositoryInterface)*Auth{
 	return &Auth{
 		env : env,
+		tokens: tokens,
+		sessions: sessions,
+		users: users,
 	}
 }
 func (authenticate *Auth) AuthenticationMiddleware() gin.HandlerFunc{
@@ -38,8 +50,30 @@ func (authenticate *Auth) AuthenticationMiddleware() gin.HandlerFunc{
 			c.Abort()
 			return
 		}
-		claims, err := ExtractIDFromToken(auth[1], authenticate.env.AccessTokenSecret)
-		fmt.Println(claims)
+		claims, err := authenticate.tokens.ExtractClaimsFromToken(auth[1])
+		if err != nil || claims.ID == "" || claims.SessionID == "" || claims.Purpose != ""{
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"message" : "Unauthorized",
+			})
+			c.Abort()
+			return
+		}
+
+		// A token stays cryptographically valid until it expires, so the session it
+		// belongs to is checked on every request to honour logouts and revocations.
+		session, err := authenticate.sessions.FindSessionByID(claims.SessionID)
+		if err != nil || session.Revoked || session.UserID.Hex() != claims.ID || session.ExpiresAt.Before(time.Now()){
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"message" : "Session expired or revoked",
+			})
+			c.Abort()
+			return
+		}
+		if time.Since(session.LastUsed_At) > sessionTouchInterval{
+			authenticate.sessions.TouchSession(claims.SessionID, time.Now())
+		}
+
+		user, err := authenticate.users.FindUserByID(claims.ID)
 		if err != nil{
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"message" : "Unauthorized",
@@ -47,8 +81,48 @@ func (authenticate *Auth) AuthenticationMiddleware() gin.HandlerFunc{
 			c.Abort()
 			return
 		}
-		c.Set("user_id" , claims)
+		if user.IsSuspended{
+			c.JSON(http.StatusForbidden, gin.H{
+				"message" : "Account suspended",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set("user_id" , claims.ID)
+		c.Set("session_id", claims.SessionID)
+		c.Set("role", user.Role)
+		c.Set("two_factor_verified", session.TwoFactorVerified)
+		c.Next()
+	}
+}
+
+// TwoFactorMiddleware refuses sessions that did not pass two-factor authentication when the
+// user's role requires it. It must run after AuthenticationMiddleware.
+func (authenticate *Auth) TwoFactorMiddleware() gin.HandlerFunc{
+	return func(c *gin.Context){
+		if authenticate.env.RequiresTwoFactor(c.GetString("role")) && !c.GetBool("two_factor_verified"){
+			c.JSON(http.StatusForbidden, gin.H{
+				"message" : "Two-factor authentication required for this resource",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+
+// AdminMiddleware refuses callers who are not admins. It must run after AuthenticationMiddleware.
+func (authenticate *Auth) AdminMiddleware() gin.HandlerFunc{
+	return func(c *gin.Context){
+		if c.GetString("role") != domain.RoleAdmin{
+			c.JSON(http.StatusForbidden, gin.H{
+				"message" : "Only admin can access this resource",
+			})
+			c.Abort()
+			return
+		}
 		c.Next()
-		// Can check the expiration time of the token if it is valid or not
 	}
 }
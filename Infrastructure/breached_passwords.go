@@ -0,0 +1,88 @@
+package infrastructure
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HashListBreachChecker looks passwords up in a local copy of a breached password corpus in the
+// k-anonymity range format: the upper-case SHA-1 of the password is split into a 5 character
+// prefix and a 35 character suffix, and only the suffixes sharing the prefix are compared.
+//
+// The list is either a directory holding one range file per prefix (named <PREFIX> or <PREFIX>.txt,
+// with SUFFIX:COUNT lines, as produced by range downloaders), read on demand, or a single file of
+// HASH:COUNT lines that is loaded into memory, which suits smaller lists.
+type HashListBreachChecker struct {
+	dir    string
+	ranges map[string]map[string]struct{}
+}
+
+func NewHashListBreachChecker(path string) (*HashListBreachChecker, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &HashListBreachChecker{dir: path}, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	checker := &HashListBreachChecker{ranges: map[string]map[string]struct{}{}}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		hash := strings.ToUpper(strings.TrimSpace(strings.SplitN(scanner.Text(), ":", 2)[0]))
+		if len(hash) != 40 {
+			continue
+		}
+		prefix, suffix := hash[:5], hash[5:]
+		if checker.ranges[prefix] == nil {
+			checker.ranges[prefix] = map[string]struct{}{}
+		}
+		checker.ranges[prefix][suffix] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return checker, nil
+}
+
+func (checker *HashListBreachChecker) IsBreached(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	if checker.dir == "" {
+		_, found := checker.ranges[prefix][suffix]
+		return found, nil
+	}
+
+	file, err := os.Open(filepath.Join(checker.dir, prefix+".txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		file, err = os.Open(filepath.Join(checker.dir, prefix))
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if strings.EqualFold(strings.TrimSpace(strings.SplitN(scanner.Text(), ":", 2)[0]), suffix) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
@@ -0,0 +1,62 @@
+package infrastructure
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	domain "loan-tracker/Domain"
+)
+
+// WriteDataArchive writes a zip archive holding <name>.json and <name>.csv for every section.
+// The archive is written next to path and renamed into place, so a partial file is never served.
+func WriteDataArchive(path string, sections []domain.ArchiveSection) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".export-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	archive := zip.NewWriter(tmp)
+	for _, section := range sections {
+		jsonFile, err := archive.Create(section.Name + ".json")
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		encoder := json.NewEncoder(jsonFile)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(section.Records); err != nil {
+			tmp.Close()
+			return err
+		}
+
+		csvFile, err := archive.Create(section.Name + ".csv")
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		writer := csv.NewWriter(csvFile)
+		if err := writer.Write(section.Header); err != nil {
+			tmp.Close()
+			return err
+		}
+		if err := writer.WriteAll(section.Rows); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
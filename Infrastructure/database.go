@@ -3,12 +3,17 @@ package infrastructure
 import (
 	"context"
 	"log"
+	"sync"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// Db keeps one client per URI, so every collection shares a connection pool and a
+// unit of work can span collections.
 type Db struct{
+	mu      sync.Mutex
+	clients map[string]*mongo.Client
 }
 
 type DB interface{
@@ -18,7 +23,7 @@ type DB interface{
 }
 
 func NewDatabase()*Db{
-	return &Db{}
+	return &Db{clients: map[string]*mongo.Client{}}
 }
 
 func (db *Db)Connection(URI string) *mongo.Client {
@@ -37,7 +42,13 @@ func (db *Db)Connection(URI string) *mongo.Client {
 }
 
 func (db *Db)ConnectToDatabase(dbHost string)*mongo.Client{
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if connection, ok := db.clients[dbHost]; ok {
+		return connection
+	}
 	connection := db.Connection(dbHost)
+	db.clients[dbHost] = connection
 	return connection
 } 
 
@@ -0,0 +1,220 @@
+package infrastructure
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	domain "loan-tracker/Domain"
+)
+
+//go:embed email_templates
+var emailTemplateFiles embed.FS
+
+type emailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// EmailTemplates renders the embedded transactional emails. Every template lives in
+// email_templates/<locale>/<name>.tmpl and defines a "subject", a "text" and an "html" block,
+// and optionally a one-line "summary" for in-app, SMS and push notifications; the HTML is
+// wrapped in the shared branded layout and each locale supplies its own footer.
+// A template missing from a locale falls back to the default locale.
+type EmailTemplates struct {
+	defaultLocale string
+	baseURL       string
+	brand         domain.EmailBranding
+	locales       map[string]map[string]emailTemplate
+}
+
+func NewEmailTemplates(config *Config) (*EmailTemplates, error) {
+	et := &EmailTemplates{
+		defaultLocale: NormalizeLocale(config.MailDefaultLocale),
+		baseURL:       config.PublicBaseURL,
+		brand: domain.EmailBranding{
+			Name:         config.BrandName,
+			LogoURL:      config.BrandLogoURL,
+			SupportEmail: config.BrandSupportEmail,
+			PrimaryColor: config.BrandPrimaryColor,
+		},
+		locales: map[string]map[string]emailTemplate{},
+	}
+
+	layout, err := emailTemplateFiles.ReadFile("email_templates/layout.html")
+	if err != nil {
+		return nil, err
+	}
+	dirs, err := fs.ReadDir(emailTemplateFiles, "email_templates")
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		locale := dir.Name()
+		templates, err := parseLocale(locale, string(layout))
+		if err != nil {
+			return nil, err
+		}
+		et.locales[locale] = templates
+	}
+
+	if _, ok := et.locales[et.defaultLocale]; !ok {
+		return nil, fmt.Errorf("no email templates for the default locale %q", et.defaultLocale)
+	}
+	return et, nil
+}
+
+func parseLocale(locale string, layout string) (map[string]emailTemplate, error) {
+	dir := path.Join("email_templates", locale)
+	footer, err := emailTemplateFiles.ReadFile(path.Join(dir, "footer.tmpl"))
+	if err != nil {
+		return nil, fmt.Errorf("email templates for %q: %w", locale, err)
+	}
+	files, err := fs.Glob(emailTemplateFiles, path.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+
+	templates := map[string]emailTemplate{}
+	for _, file := range files {
+		name := strings.TrimSuffix(path.Base(file), ".tmpl")
+		if name == "footer" {
+			continue
+		}
+		content, err := emailTemplateFiles.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		text, err := texttemplate.New(name).Parse(string(footer) + string(content))
+		if err != nil {
+			return nil, fmt.Errorf("email template %s: %w", file, err)
+		}
+		html, err := htmltemplate.New(name).Parse(layout + string(footer) + string(content))
+		if err != nil {
+			return nil, fmt.Errorf("email template %s: %w", file, err)
+		}
+		templates[name] = emailTemplate{text: text, html: html}
+	}
+	return templates, nil
+}
+
+// NormalizeLocale reduces a locale such as "fr-FR" or "FR_fr" to its language code.
+func NormalizeLocale(locale string) string {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+	return locale
+}
+
+func (et *EmailTemplates) lookup(name string, locale string) (emailTemplate, string, bool) {
+	locale = NormalizeLocale(locale)
+	if template, ok := et.locales[locale][name]; ok {
+		return template, locale, true
+	}
+	template, ok := et.locales[et.defaultLocale][name]
+	return template, et.defaultLocale, ok
+}
+
+func (et *EmailTemplates) render(name string, locale string, data domain.EmailData) (domain.EmailPreview, error) {
+	template, locale, ok := et.lookup(name, locale)
+	if !ok {
+		return domain.EmailPreview{}, fmt.Errorf("unknown email template %q", name)
+	}
+	data.Brand = et.brand
+	data.BaseURL = et.baseURL
+
+	var subject, summary, text, html bytes.Buffer
+	if err := template.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return domain.EmailPreview{}, err
+	}
+	if template.text.Lookup("summary") != nil {
+		if err := template.text.ExecuteTemplate(&summary, "summary", data); err != nil {
+			return domain.EmailPreview{}, err
+		}
+	}
+	if err := template.text.ExecuteTemplate(&text, "text", data); err != nil {
+		return domain.EmailPreview{}, err
+	}
+	text.WriteString("\n\n")
+	if err := template.text.ExecuteTemplate(&text, "footer_text", data); err != nil {
+		return domain.EmailPreview{}, err
+	}
+	if err := template.html.ExecuteTemplate(&html, "layout", data); err != nil {
+		return domain.EmailPreview{}, err
+	}
+	return domain.EmailPreview{
+		Template: name,
+		Locale:   locale,
+		Subject:  strings.TrimSpace(subject.String()),
+		Summary:  strings.TrimSpace(summary.String()),
+		Text:     strings.TrimSpace(text.String()) + "\n",
+		HTML:     html.String(),
+	}, nil
+}
+
+// Render builds the email from the template in the given locale. The caller sets the recipients.
+func (et *EmailTemplates) Render(name string, locale string, data domain.EmailData) (domain.EmailMessage, error) {
+	rendered, err := et.render(name, locale, data)
+	if err != nil {
+		return domain.EmailMessage{}, err
+	}
+	return domain.EmailMessage{
+		Subject: rendered.Subject,
+		Summary: rendered.Summary,
+		Text:    rendered.Text,
+		HTML:    rendered.HTML,
+	}, nil
+}
+
+// Preview renders a template with sample values so it can be reviewed without sending it.
+func (et *EmailTemplates) Preview(name string, locale string) (domain.EmailPreview, error) {
+	now := time.Now().UTC()
+	return et.render(name, locale, domain.EmailData{
+		Name:        "Jane Doe",
+		Link:        et.baseURL + "/preview-link",
+		NewEmail:    "jane.new@example.com",
+		Until:       now.Add(15 * time.Minute).Format(time.RFC1123),
+		ChangedAt:   now.Format(time.RFC1123),
+		Reference:   "LN-2024-000123",
+		Amount:      "1,500.00",
+		Balance:     "1,000.00",
+		Reason:      "Insufficient income documentation",
+		DueDate:     now.AddDate(0, 0, 3).Format("2006-01-02"),
+		DaysOverdue: 5,
+	})
+}
+
+// Templates returns the names of the templates available in the default locale.
+func (et *EmailTemplates) Templates() []string {
+	names := []string{}
+	for name := range et.locales[et.defaultLocale] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (et *EmailTemplates) Locales() []string {
+	locales := []string{}
+	for locale := range et.locales {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+func (et *EmailTemplates) SupportsLocale(locale string) bool {
+	_, ok := et.locales[NormalizeLocale(locale)]
+	return ok
+}
@@ -3,7 +3,9 @@ package infrastructure
 import (
 	"log"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -11,15 +13,92 @@ import (
 type Config struct {
 	DatabaseUrl              string
 	Port                     int
+	TrustedProxies           []string
 	DbName                   string
 	UserCollection           string
 	LoanCollection		   	 string
 	ActiveUserCollection     string
+	SessionCollection        string
+	DataJobCollection        string
+	OutboxCollection         string
+	ReminderCollection       string
+	InboxCollection          string
+	WebhookCollection        string
+	PaymentCollection        string
+	StatementMappingCollection string
+	StatementImportCollection string
+	AuditCollection          string
+	MongoTransactions        bool
 	ContextTimeout           int
 	AccessTokenExpiryHour    int
 	RefreshTokenExpiryHour   int
-	AccessTokenSecret        string
-	RefreshTokenSecret       string
+	JWTKeysDir               string
+	JWTActiveKid             string
+	JWTEphemeralKey          bool
+	JWTIssuer                string
+	JWTAudience              string
+	TwoFactorIssuer          string
+	TwoFactorRequiredRoles   []string
+	TwoFactorEncryptionKey   string
+	LoginMaxFailures         int
+	LoginIPMaxFailures       int
+	LoginLockoutMinutes      int
+	LoginAttemptWindowMinutes int
+	VerificationLinkSecret   string
+	VerificationResendCooldownSeconds int
+	VerificationResendDailyLimit int
+	PasswordHistorySize      int
+	UserRetentionDays        int
+	DataExportDir            string
+	DataExportTTLHours       int
+	DataJobPollSeconds       int
+	LoanProducts             []string
+	ReminderCadences         map[string][]int
+	ReminderPollMinutes      int
+	InboxReadTTLDays         int
+	WebhookTimeoutSeconds    int
+	PaymentGatewaySecret     string
+	PaymentSignatureToleranceSeconds int
+	PaymentSimulator         bool
+	StatementMaxLines        int
+	StatementMatchThreshold  int
+	StatementReviewThreshold int
+	OutboxMaxAttempts        int
+	OutboxBackoffSeconds     int
+	OutboxMaxBackoffMinutes  int
+	OutboxPollSeconds        int
+	SMSDriver                string
+	SMSGatewayURL            string
+	SMSGatewayToken          string
+	SMSFrom                  string
+	PushDriver               string
+	VAPIDPublicKey           string
+	VAPIDPrivateKey          string
+	VAPIDSubject             string
+	MailDriver               string
+	MailFrom                 string
+	MailFromName             string
+	MailDir                  string
+	SMTPHost                 string
+	SMTPPort                 int
+	SMTPUsername             string
+	SMTPPassword             string
+	MailDefaultLocale        string
+	PublicBaseURL            string
+	BrandName                string
+	BrandLogoURL             string
+	BrandSupportEmail        string
+	BrandPrimaryColor        string
+	PasswordMinLength        int
+	PasswordMaxLength        int
+	PasswordRequireUpper     bool
+	PasswordRequireLower     bool
+	PasswordRequireNumber    bool
+	PasswordRequireSpecial   bool
+	PasswordRejectPersonalInfo bool
+	PasswordBannedWords      []string
+	BreachedPasswordList     string
+	BcryptCost               int
 }
 
 func LoadEnv() (*Config, error) {
@@ -31,15 +110,101 @@ func LoadEnv() (*Config, error) {
 
 	dbURL := os.Getenv("DATABASE_URL")
 	portStr := os.Getenv("PORT")
+	trustedProxies := getEnvList("TRUSTED_PROXIES")
 	dbName := os.Getenv("DB_NAME")
 	userColl := os.Getenv("user_collection")
 	loanColl := os.Getenv("loan_collection")
 	activeUserColl := os.Getenv("ACTIVE_USER_COLLECTION")
+	sessionColl := getEnv("SESSION_COLLECTION", "sessions")
+	dataJobColl := getEnv("DATA_JOB_COLLECTION", "data_jobs")
+	outboxColl := getEnv("OUTBOX_COLLECTION", "outbox")
+	reminderColl := getEnv("REMINDER_COLLECTION", "reminders")
+	inboxColl := getEnv("INBOX_COLLECTION", "notifications")
+	webhookColl := getEnv("WEBHOOK_COLLECTION", "webhooks")
+	paymentColl := getEnv("PAYMENT_COLLECTION", "payments")
+	statementMappingColl := getEnv("STATEMENT_MAPPING_COLLECTION", "statement_mappings")
+	statementImportColl := getEnv("STATEMENT_IMPORT_COLLECTION", "statement_imports")
+	auditColl := getEnv("AUDIT_COLLECTION", "audit_log")
+	mongoTransactions := getEnvBool("MONGO_TRANSACTIONS", false)
 	contextTimeoutStr := os.Getenv("CONTEXT_TIMEOUT")
 	accessTokenExpiryHourStr := os.Getenv("ACCESS_TOKEN_EXPIRY_HOUR")
 	refreshTokenExpiryHourStr := os.Getenv("REFRESH_TOKEN_EXPIRY_HOUR")
-	accessTokenSecret := os.Getenv("ACCESS_TOKEN_SECRET")
-	refreshTokenSecret := os.Getenv("REFRESH_TOKEN_SECRET")
+	jwtKeysDir := os.Getenv("JWT_KEYS_DIR")
+	jwtActiveKid := os.Getenv("JWT_ACTIVE_KID")
+	jwtEphemeralKey := getEnvBool("JWT_EPHEMERAL_KEY", false)
+	jwtIssuer := getEnv("JWT_ISSUER", "loan-tracker")
+	jwtAudience := getEnv("JWT_AUDIENCE", "loan-tracker-api")
+	twoFactorIssuer := getEnv("TWO_FACTOR_ISSUER", "Loan Tracker")
+	twoFactorRequiredRoles := getEnvList("TWO_FACTOR_REQUIRED_ROLES")
+	twoFactorEncryptionKey := os.Getenv("TWO_FACTOR_ENCRYPTION_KEY")
+	loginMaxFailures := getEnvInt("LOGIN_MAX_FAILURES", 5)
+	loginIPMaxFailures := getEnvInt("LOGIN_IP_MAX_FAILURES", 50)
+	loginLockoutMinutes := getEnvInt("LOGIN_LOCKOUT_MINUTES", 15)
+	loginAttemptWindowMinutes := getEnvInt("LOGIN_ATTEMPT_WINDOW_MINUTES", 60)
+	verificationLinkSecret := os.Getenv("VERIFICATION_LINK_SECRET")
+	verificationResendCooldownSeconds := getEnvInt("VERIFICATION_RESEND_COOLDOWN_SECONDS", 60)
+	verificationResendDailyLimit := getEnvInt("VERIFICATION_RESEND_DAILY_LIMIT", 5)
+	passwordHistorySize := getEnvInt("PASSWORD_HISTORY_SIZE", 5)
+	userRetentionDays := getEnvInt("USER_RETENTION_DAYS", 30)
+	dataExportDir := getEnv("DATA_EXPORT_DIR", "exports")
+	dataExportTTLHours := getEnvInt("DATA_EXPORT_TTL_HOURS", 72)
+	dataJobPollSeconds := getEnvInt("DATA_JOB_POLL_SECONDS", 5)
+	loanProducts := getEnvList("LOAN_PRODUCTS")
+	if len(loanProducts) == 0 {
+		loanProducts = []string{"standard"}
+	}
+	reminderCadences := map[string][]int{"": getEnvIntList("REMINDER_CADENCE", []int{-3, 0, 1, 7, 30})}
+	for _, product := range loanProducts {
+		key := "REMINDER_CADENCE_" + strings.ToUpper(product)
+		if os.Getenv(key) != "" {
+			reminderCadences[product] = getEnvIntList(key, reminderCadences[""])
+		}
+	}
+	reminderPollMinutes := getEnvInt("REMINDER_POLL_MINUTES", 15)
+	inboxReadTTLDays := getEnvInt("INBOX_READ_TTL_DAYS", 30)
+	webhookTimeoutSeconds := getEnvInt("WEBHOOK_TIMEOUT_SECONDS", 10)
+	paymentGatewaySecret := getEnv("PAYMENT_GATEWAY_SECRET", "")
+	paymentSignatureToleranceSeconds := getEnvInt("PAYMENT_SIGNATURE_TOLERANCE_SECONDS", 300)
+	paymentSimulator := getEnvBool("PAYMENT_SIMULATOR", false)
+	statementMaxLines := getEnvInt("STATEMENT_MAX_LINES", 10000)
+	statementMatchThreshold := getEnvInt("STATEMENT_MATCH_THRESHOLD", 80)
+	statementReviewThreshold := getEnvInt("STATEMENT_REVIEW_THRESHOLD", 40)
+	outboxMaxAttempts := getEnvInt("OUTBOX_MAX_ATTEMPTS", 8)
+	outboxBackoffSeconds := getEnvInt("OUTBOX_BACKOFF_SECONDS", 30)
+	outboxMaxBackoffMinutes := getEnvInt("OUTBOX_MAX_BACKOFF_MINUTES", 60)
+	outboxPollSeconds := getEnvInt("OUTBOX_POLL_SECONDS", 5)
+	smsDriver := os.Getenv("SMS_DRIVER")
+	smsGatewayURL := os.Getenv("SMS_GATEWAY_URL")
+	smsGatewayToken := os.Getenv("SMS_GATEWAY_TOKEN")
+	smsFrom := getEnv("SMS_FROM", "LoanTracker")
+	pushDriver := os.Getenv("PUSH_DRIVER")
+	vapidPublicKey := os.Getenv("VAPID_PUBLIC_KEY")
+	vapidPrivateKey := os.Getenv("VAPID_PRIVATE_KEY")
+	vapidSubject := os.Getenv("VAPID_SUBJECT")
+	mailDriver := os.Getenv("MAIL_DRIVER")
+	mailFrom := getEnv("MAIL_FROM", "no-reply@localhost")
+	mailFromName := getEnv("MAIL_FROM_NAME", "Loan Tracker")
+	mailDir := getEnv("MAIL_DIR", "mail")
+	smtpHost := os.Getenv("SMTP_HOST")
+	smtpPort := getEnvInt("SMTP_PORT", 587)
+	smtpUsername := os.Getenv("SMTP_USERNAME")
+	smtpPassword := os.Getenv("SMTP_PASSWORD")
+	mailDefaultLocale := getEnv("MAIL_DEFAULT_LOCALE", "en")
+	publicBaseURL := strings.TrimSuffix(getEnv("PUBLIC_BASE_URL", "http://localhost:8080"), "/")
+	brandName := getEnv("BRAND_NAME", "Loan Tracker")
+	brandLogoURL := os.Getenv("BRAND_LOGO_URL")
+	brandSupportEmail := os.Getenv("BRAND_SUPPORT_EMAIL")
+	brandPrimaryColor := getEnv("BRAND_PRIMARY_COLOR", "#1a56db")
+	passwordMinLength := getEnvInt("PASSWORD_MIN_LENGTH", 8)
+	passwordMaxLength := getEnvInt("PASSWORD_MAX_LENGTH", 30)
+	passwordRequireUpper := getEnvBool("PASSWORD_REQUIRE_UPPER", true)
+	passwordRequireLower := getEnvBool("PASSWORD_REQUIRE_LOWER", true)
+	passwordRequireNumber := getEnvBool("PASSWORD_REQUIRE_NUMBER", true)
+	passwordRequireSpecial := getEnvBool("PASSWORD_REQUIRE_SPECIAL", true)
+	passwordRejectPersonalInfo := getEnvBool("PASSWORD_REJECT_PERSONAL_INFO", true)
+	passwordBannedWords := getEnvList("PASSWORD_BANNED_WORDS")
+	breachedPasswordList := os.Getenv("BREACHED_PASSWORD_LIST")
+	bcryptCost := getEnvInt("BCRYPT_COST", 10)
 
 	port, err := strconv.Atoi(portStr)
 	if err != nil {
@@ -68,16 +233,179 @@ func LoadEnv() (*Config, error) {
 	config := &Config{
 		DatabaseUrl:            dbURL,
 		Port:                   port,
+		TrustedProxies:         trustedProxies,
 		DbName:                 dbName,
 		UserCollection:         userColl,
 		LoanCollection:         loanColl,
 		ActiveUserCollection:   activeUserColl,
+		SessionCollection:      sessionColl,
+		DataJobCollection:      dataJobColl,
+		OutboxCollection:       outboxColl,
+		ReminderCollection:     reminderColl,
+		InboxCollection:        inboxColl,
+		WebhookCollection:      webhookColl,
+		PaymentCollection:      paymentColl,
+		StatementMappingCollection: statementMappingColl,
+		StatementImportCollection: statementImportColl,
+		AuditCollection:        auditColl,
+		MongoTransactions:      mongoTransactions,
 		ContextTimeout:         contextTimeout,
 		AccessTokenExpiryHour:  accessTokenExpiryHour,
 		RefreshTokenExpiryHour: refreshTokenExpiryHour,
-		AccessTokenSecret:      accessTokenSecret,
-		RefreshTokenSecret:     refreshTokenSecret,
+		JWTKeysDir:             jwtKeysDir,
+		JWTActiveKid:           jwtActiveKid,
+		JWTEphemeralKey:        jwtEphemeralKey,
+		JWTIssuer:              jwtIssuer,
+		JWTAudience:            jwtAudience,
+		TwoFactorIssuer:        twoFactorIssuer,
+		TwoFactorRequiredRoles: twoFactorRequiredRoles,
+		TwoFactorEncryptionKey: twoFactorEncryptionKey,
+		LoginMaxFailures:       loginMaxFailures,
+		LoginIPMaxFailures:     loginIPMaxFailures,
+		LoginLockoutMinutes:    loginLockoutMinutes,
+		LoginAttemptWindowMinutes: loginAttemptWindowMinutes,
+		VerificationLinkSecret: verificationLinkSecret,
+		VerificationResendCooldownSeconds: verificationResendCooldownSeconds,
+		VerificationResendDailyLimit: verificationResendDailyLimit,
+		PasswordHistorySize:    passwordHistorySize,
+		UserRetentionDays:      userRetentionDays,
+		DataExportDir:          dataExportDir,
+		DataExportTTLHours:     dataExportTTLHours,
+		DataJobPollSeconds:     dataJobPollSeconds,
+		LoanProducts:           loanProducts,
+		ReminderCadences:       reminderCadences,
+		ReminderPollMinutes:    reminderPollMinutes,
+		InboxReadTTLDays:       inboxReadTTLDays,
+		WebhookTimeoutSeconds:  webhookTimeoutSeconds,
+		PaymentGatewaySecret:   paymentGatewaySecret,
+		PaymentSignatureToleranceSeconds: paymentSignatureToleranceSeconds,
+		PaymentSimulator:       paymentSimulator,
+		StatementMaxLines:      statementMaxLines,
+		StatementMatchThreshold: statementMatchThreshold,
+		StatementReviewThreshold: statementReviewThreshold,
+		OutboxMaxAttempts:      outboxMaxAttempts,
+		OutboxBackoffSeconds:   outboxBackoffSeconds,
+		OutboxMaxBackoffMinutes: outboxMaxBackoffMinutes,
+		OutboxPollSeconds:      outboxPollSeconds,
+		SMSDriver:              smsDriver,
+		SMSGatewayURL:          smsGatewayURL,
+		SMSGatewayToken:        smsGatewayToken,
+		SMSFrom:                smsFrom,
+		PushDriver:             pushDriver,
+		VAPIDPublicKey:         vapidPublicKey,
+		VAPIDPrivateKey:        vapidPrivateKey,
+		VAPIDSubject:           vapidSubject,
+		MailDriver:             mailDriver,
+		MailFrom:               mailFrom,
+		MailFromName:           mailFromName,
+		MailDir:                mailDir,
+		SMTPHost:               smtpHost,
+		SMTPPort:               smtpPort,
+		SMTPUsername:           smtpUsername,
+		SMTPPassword:           smtpPassword,
+		MailDefaultLocale:      mailDefaultLocale,
+		PublicBaseURL:          publicBaseURL,
+		BrandName:              brandName,
+		BrandLogoURL:           brandLogoURL,
+		BrandSupportEmail:      brandSupportEmail,
+		BrandPrimaryColor:      brandPrimaryColor,
+		PasswordMinLength:      passwordMinLength,
+		PasswordMaxLength:      passwordMaxLength,
+		PasswordRequireUpper:   passwordRequireUpper,
+		PasswordRequireLower:   passwordRequireLower,
+		PasswordRequireNumber:  passwordRequireNumber,
+		PasswordRequireSpecial: passwordRequireSpecial,
+		PasswordRejectPersonalInfo: passwordRejectPersonalInfo,
+		PasswordBannedWords:    passwordBannedWords,
+		BreachedPasswordList:   breachedPasswordList,
+		BcryptCost:             bcryptCost,
 	}
 
 	return config, nil
 }
+
+// getEnv returns the value of the environment variable or the fallback when it is unset.
+func getEnv(key string, fallback string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// getEnvList splits a comma separated environment variable, dropping empty entries.
+func getEnvList(key string) []string {
+	values := []string{}
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		value = strings.TrimSpace(value)
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvIntList parses a comma separated list of integers, sorted in ascending order. The fallback
+// is used when the variable is unset or holds anything that is not an integer.
+func getEnvIntList(key string, fallback []int) []int {
+	values := []int{}
+	for _, entry := range getEnvList(key) {
+		value, err := strconv.Atoi(entry)
+		if err != nil {
+			return fallback
+		}
+		values = append(values, value)
+	}
+	if len(values) == 0 {
+		return fallback
+	}
+	sort.Ints(values)
+	return values
+}
+
+// ReminderCadence returns the days, relative to an installment's due date, on which reminders are
+// sent for loans of the product. Negative days come before the due date.
+func (config *Config) ReminderCadence(product string) []int {
+	if cadence, ok := config.ReminderCadences[product]; ok {
+		return cadence
+	}
+	return config.ReminderCadences[""]
+}
+
+// IsLoanProduct reports whether loans can be applied for under the product.
+func (config *Config) IsLoanProduct(product string) bool {
+	for _, name := range config.LoanProducts {
+		if name == product {
+			return true
+		}
+	}
+	return false
+}
+
+// RequiresTwoFactor reports whether accounts with the given role must use two-factor authentication.
+func (config *Config) RequiresTwoFactor(role string) bool {
+	for _, required := range config.TwoFactorRequiredRoles {
+		if required == role {
+			return true
+		}
+	}
+	return false
+}
+
+// getEnvInt parses an integer environment variable, using the fallback when it is unset or invalid.
+func getEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEnvBool parses a boolean environment variable, using the fallback when it is unset or invalid.
+func getEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,44 @@
+package infrastructure
+
+import (
+	"crypto/rand"
+	"strings"
+)
+
+// loanReferenceAlphabet is Crockford's base32, which leaves out I, L, O and U so references read
+// out over the phone or typed into a banking app are hard to get wrong.
+const loanReferenceAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+const loanReferencePrefix = "LN-"
+
+// GenerateLoanReference returns a random reference number such as LN-7K3M9Q2D, which borrowers
+// quote when they pay.
+func GenerateLoanReference() (string, error) {
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	reference := []byte(loanReferencePrefix)
+	for _, b := range bytes {
+		reference = append(reference, loanReferenceAlphabet[b&31])
+	}
+	return string(reference), nil
+}
+
+// NormalizeLoanReference turns a reference as typed by a payer into its canonical form: upper case,
+// without spaces, with the dash after the prefix and with look-alike letters read as digits.
+// Anything that is not a reference number, such as a loan id, is only trimmed and upper-cased.
+func NormalizeLoanReference(reference string) string {
+	reference = strings.ToUpper(strings.Join(strings.Fields(reference), ""))
+	compact := strings.ReplaceAll(reference, "-", "")
+	if len(compact) != len(loanReferencePrefix)-1+8 || !strings.HasPrefix(compact, "LN") {
+		return reference
+	}
+	code := strings.NewReplacer("O", "0", "I", "1", "L", "1").Replace(compact[2:])
+	for _, c := range code {
+		if !strings.ContainsRune(loanReferenceAlphabet, c) {
+			return reference
+		}
+	}
+	return loanReferencePrefix + code
+}
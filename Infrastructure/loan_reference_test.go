@@ -0,0 +1,52 @@
+package infrastructure
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestNormalizeLoanReference(t *testing.T) {
+	tests := []struct {
+		name      string
+		reference string
+		want      string
+	}{
+		{name: "canonical", reference: "LN-7K3M9Q2D", want: "LN-7K3M9Q2D"},
+		{name: "lower case", reference: "ln-7k3m9q2d", want: "LN-7K3M9Q2D"},
+		{name: "without dash", reference: "LN7K3M9Q2D", want: "LN-7K3M9Q2D"},
+		{name: "spaces", reference: " LN 7K3M 9Q2D ", want: "LN-7K3M9Q2D"},
+		{name: "O read as zero", reference: "LN-7K3MOQ2D", want: "LN-7K3M0Q2D"},
+		{name: "I read as one", reference: "LN-7K3MIQ2D", want: "LN-7K3M1Q2D"},
+		{name: "L read as one", reference: "ln-7k3mlq2d", want: "LN-7K3M1Q2D"},
+		{name: "all look-alikes", reference: "LN-OIL0IL01", want: "LN-01101101"},
+		{name: "prefix letters kept", reference: "LNOOOOOOOO", want: "LN-00000000"},
+		{name: "letter outside the alphabet", reference: "LN-7K3M9Q2U", want: "LN-7K3M9Q2U"},
+		{name: "too short", reference: "ln-7k3m9q2", want: "LN-7K3M9Q2"},
+		{name: "too long", reference: "LN-7K3M9Q2DX", want: "LN-7K3M9Q2DX"},
+		{name: "loan id", reference: "65f1c0a2b3d4e5f60718293a", want: "65F1C0A2B3D4E5F60718293A"},
+		{name: "empty", reference: "", want: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := NormalizeLoanReference(test.reference); got != test.want {
+				t.Errorf("NormalizeLoanReference(%q) = %q, want %q", test.reference, got, test.want)
+			}
+		})
+	}
+}
+
+func TestGenerateLoanReferenceIsNormalized(t *testing.T) {
+	pattern := regexp.MustCompile(`^LN-[0-9A-HJKMNP-TV-Z]{8}$`)
+	for i := 0; i < 100; i++ {
+		reference, err := GenerateLoanReference()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pattern.MatchString(reference) {
+			t.Fatalf("GenerateLoanReference() = %q", reference)
+		}
+		if got := NormalizeLoanReference(reference); got != reference {
+			t.Fatalf("NormalizeLoanReference(%q) = %q", reference, got)
+		}
+	}
+}
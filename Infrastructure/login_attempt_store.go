@@ -0,0 +1,73 @@
+package infrastructure
+
+import (
+	domain "loan-tracker/Domain"
+	"sync"
+	"time"
+)
+
+// Number of tracked keys above which expired entries are pruned on write.
+const loginAttemptPruneThreshold = 10000
+
+// InMemoryLoginAttemptStore keeps failed login attempts in process memory. Failures older than
+// the window are forgotten, so counters reset on their own after a quiet period.
+type InMemoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	window   time.Duration
+	attempts map[string]domain.LoginAttempt
+}
+
+func NewInMemoryLoginAttemptStore(window time.Duration) *InMemoryLoginAttemptStore {
+	return &InMemoryLoginAttemptStore{
+		window:   window,
+		attempts: map[string]domain.LoginAttempt{},
+	}
+}
+
+func (store *InMemoryLoginAttemptStore) expired(attempt domain.LoginAttempt, now time.Time) bool {
+	return attempt.LockedUntil.Before(now) && now.Sub(attempt.LastFailureAt) > store.window
+}
+
+func (store *InMemoryLoginAttemptStore) Get(key string) domain.LoginAttempt {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	attempt, ok := store.attempts[key]
+	if !ok || store.expired(attempt, time.Now()) {
+		return domain.LoginAttempt{}
+	}
+	return attempt
+}
+
+func (store *InMemoryLoginAttemptStore) RecordFailure(key string, at time.Time) domain.LoginAttempt {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if len(store.attempts) > loginAttemptPruneThreshold {
+		for k, attempt := range store.attempts {
+			if store.expired(attempt, at) {
+				delete(store.attempts, k)
+			}
+		}
+	}
+	attempt := store.attempts[key]
+	if store.expired(attempt, at) {
+		attempt = domain.LoginAttempt{}
+	}
+	attempt.Failures++
+	attempt.LastFailureAt = at
+	store.attempts[key] = attempt
+	return attempt
+}
+
+func (store *InMemoryLoginAttemptStore) Lock(key string, until time.Time) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	attempt := store.attempts[key]
+	attempt.LockedUntil = until
+	store.attempts[key] = attempt
+}
+
+func (store *InMemoryLoginAttemptStore) Reset(key string) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	delete(store.attempts, key)
+}
@@ -0,0 +1,184 @@
+package infrastructure
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	domain "loan-tracker/Domain"
+
+	"gopkg.in/gomail.v2"
+)
+
+// Mail drivers selectable with MAIL_DRIVER.
+const (
+	MailDriverSMTP   = "smtp"
+	MailDriverFile   = "file"
+	MailDriverMemory = "memory"
+)
+
+// NewMailer builds the mail driver named by MailDriver. Without a driver, SMTP is used when a
+// host is configured and the file driver otherwise, so a fresh checkout can register users offline.
+func NewMailer(config *Config) (domain.Mailer, error) {
+	driver := config.MailDriver
+	if driver == "" {
+		driver = MailDriverFile
+		if config.SMTPHost != "" {
+			driver = MailDriverSMTP
+		}
+	}
+	switch driver {
+	case MailDriverSMTP:
+		if config.SMTPHost == "" {
+			return nil, errors.New("SMTP_HOST is required for the smtp mail driver")
+		}
+		return NewSMTPMailer(config), nil
+	case MailDriverFile:
+		return NewFileMailer(config.MailDir, config.MailFrom, config.MailFromName), nil
+	case MailDriverMemory:
+		return NewMemoryMailer(), nil
+	}
+	return nil, fmt.Errorf("unknown MAIL_DRIVER %q", driver)
+}
+
+func validateMessage(message domain.EmailMessage) error {
+	if len(message.To) == 0 {
+		return errors.New("email has no recipient")
+	}
+	if message.Text == "" && message.HTML == "" {
+		return errors.New("email has no body")
+	}
+	return nil
+}
+
+// buildMessage renders an EmailMessage as a MIME message.
+func buildMessage(from string, fromName string, message domain.EmailMessage) *gomail.Message {
+	m := gomail.NewMessage()
+	m.SetAddressHeader("From", from, fromName)
+	m.SetHeader("To", message.To...)
+	m.SetHeader("Subject", message.Subject)
+	m.SetDateHeader("Date", time.Now())
+	switch {
+	case message.Text != "" && message.HTML != "":
+		m.SetBody("text/plain", message.Text)
+		m.AddAlternative("text/html", message.HTML)
+	case message.HTML != "":
+		m.SetBody("text/html", message.HTML)
+	default:
+		m.SetBody("text/plain", message.Text)
+	}
+	for _, attachment := range message.Attachments {
+		content := attachment.Content
+		settings := []gomail.FileSetting{
+			gomail.SetCopyFunc(func(w io.Writer) error {
+				_, err := w.Write(content)
+				return err
+			}),
+		}
+		if attachment.ContentType != "" {
+			settings = append(settings, gomail.SetHeader(map[string][]string{"Content-Type": {attachment.ContentType}}))
+		}
+		m.Attach(attachment.Filename, settings...)
+	}
+	return m
+}
+
+// SMTPMailer sends email through an SMTP server.
+type SMTPMailer struct {
+	dialer   *gomail.Dialer
+	from     string
+	fromName string
+}
+
+func NewSMTPMailer(config *Config) *SMTPMailer {
+	dialer := gomail.NewDialer(config.SMTPHost, config.SMTPPort, config.SMTPUsername, config.SMTPPassword)
+	// Port 465 expects TLS from the first byte; other ports upgrade with STARTTLS when offered.
+	dialer.SSL = config.SMTPPort == 465
+	return &SMTPMailer{
+		dialer:   dialer,
+		from:     config.MailFrom,
+		fromName: config.MailFromName,
+	}
+}
+
+func (sm *SMTPMailer) Send(message domain.EmailMessage) error {
+	if err := validateMessage(message); err != nil {
+		return err
+	}
+	return sm.dialer.DialAndSend(buildMessage(sm.from, sm.fromName, message))
+}
+
+// FileMailer writes every email as an .eml file to a directory instead of sending it.
+type FileMailer struct {
+	dir      string
+	from     string
+	fromName string
+}
+
+func NewFileMailer(dir string, from string, fromName string) *FileMailer {
+	return &FileMailer{
+		dir:      dir,
+		from:     from,
+		fromName: fromName,
+	}
+}
+
+func (fm *FileMailer) Send(message domain.EmailMessage) error {
+	if err := validateMessage(message); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(fm.dir, 0o755); err != nil {
+		return err
+	}
+	token, err := GenerateVerificationToken()
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405"), token[:8])
+	file, err := os.Create(filepath.Join(fm.dir, name))
+	if err != nil {
+		return err
+	}
+	if _, err := buildMessage(fm.from, fm.fromName, message).WriteTo(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// MemoryMailer keeps sent email in memory so tests can inspect it.
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []domain.EmailMessage
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (mm *MemoryMailer) Send(message domain.EmailMessage) error {
+	if err := validateMessage(message); err != nil {
+		return err
+	}
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	mm.messages = append(mm.messages, message)
+	return nil
+}
+
+// Messages returns the email sent so far, oldest first.
+func (mm *MemoryMailer) Messages() []domain.EmailMessage {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	return append([]domain.EmailMessage(nil), mm.messages...)
+}
+
+func (mm *MemoryMailer) Reset() {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	mm.messages = nil
+}
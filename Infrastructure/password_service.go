@@ -1,18 +1,62 @@
 package infrastructure
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
-type PasswordService struct{}
+type PasswordService struct{
+	Cost int
+	Policy PasswordPolicy
+	dummyHash string
+}
 
-func NewPasswordService() *PasswordService {
-	return &PasswordService{}
+func NewPasswordService(config *Config) (*PasswordService, error) {
+	// bcrypt quietly uses its default cost below MinCost, so an out of range cost is refused at startup.
+	if config.BcryptCost < bcrypt.MinCost || config.BcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("invalid BCRYPT_COST %d: must be between %d and %d", config.BcryptCost, bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	policy := PasswordPolicy{
+		MinLength: config.PasswordMinLength,
+		MaxLength: config.PasswordMaxLength,
+		RequireUpper: config.PasswordRequireUpper,
+		RequireLower: config.PasswordRequireLower,
+		RequireNumber: config.PasswordRequireNumber,
+		RequireSpecial: config.PasswordRequireSpecial,
+		RejectPersonalInfo: config.PasswordRejectPersonalInfo,
+		BannedWords: config.PasswordBannedWords,
+	}
+	if config.BreachedPasswordList != "" {
+		checker, err := NewHashListBreachChecker(config.BreachedPasswordList)
+		if err != nil {
+			return nil, err
+		}
+		policy.Breached = checker
+	}
+
+	service := &PasswordService{
+		Cost: config.BcryptCost,
+		Policy: policy,
+	}
+	// Compared against when a login names an unknown account, so that path costs as much as a real check.
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	dummyHash, err := service.HashPassword(hex.EncodeToString(random))
+	if err != nil {
+		return nil, err
+	}
+	service.dummyHash = dummyHash
+	return service, nil
 }
 
 
 func (d *PasswordService) HashPassword(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), d.Cost)
 	if err != nil {
 		return "", err
 	}
@@ -22,4 +66,19 @@ func (d *PasswordService) HashPassword(password string) (string, error) {
 func (d *PasswordService) ComparePassword(password, hashedPassword string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 	return err == nil
-}
\ No newline at end of file
+}
+
+// CompareDummyPassword spends the time of a password check without a real hash to check against.
+func (d *PasswordService) CompareDummyPassword(password string) {
+	d.ComparePassword(password, d.dummyHash)
+}
+
+// NeedsRehash reports whether the hash was made with a different cost than the configured one.
+func (d *PasswordService) NeedsRehash(hashedPassword string) bool {
+	cost, err := bcrypt.Cost([]byte(hashedPassword))
+	return err == nil && cost != d.Cost
+}
+
+func (d *PasswordService) ValidatePassword(password string, userName string, email string) error {
+	return d.Policy.Validate(password, userName, email)
+}
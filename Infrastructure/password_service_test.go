@@ -0,0 +1,115 @@
+package infrastructure
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	domain "loan-tracker/Domain"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	list := filepath.Join(t.TempDir(), "breached.txt")
+	breached := fmt.Sprintf("%X:42\n", sha1.Sum([]byte("Tr0ub4dor&3")))
+	if err := os.WriteFile(list, []byte(breached), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	checker, err := NewHashListBreachChecker(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := PasswordPolicy{
+		MinLength:          8,
+		MaxLength:          30,
+		RequireUpper:       true,
+		RequireLower:       true,
+		RequireNumber:      true,
+		RequireSpecial:     true,
+		RejectPersonalInfo: true,
+		BannedWords:        []string{"loan"},
+		Breached:           checker,
+	}
+	length := "password must be between 8 and 30 characters long"
+
+	tests := []struct {
+		name       string
+		password   string
+		userName   string
+		violations []string
+	}{
+		{name: "shortest allowed", password: "Aa1!" + strings.Repeat("x", 4)},
+		{name: "one character short", password: "Aa1!" + strings.Repeat("x", 3), violations: []string{length}},
+		{name: "longest allowed", password: "Aa1!" + strings.Repeat("x", 26)},
+		{name: "one character long", password: "Aa1!" + strings.Repeat("x", 27), violations: []string{length}},
+		{name: "no uppercase letter", password: "aa1!xxxx", violations: []string{"password must contain at least one uppercase letter"}},
+		{name: "no lowercase letter", password: "AA1!XXXX", violations: []string{"password must contain at least one lowercase letter"}},
+		{name: "no number", password: "Aax!xxxx", violations: []string{"password must contain at least one number"}},
+		{name: "no special character", password: "Aa1xxxxx", violations: []string{"password must contain at least one special character"}},
+		{name: "user name in any case", password: "Aa1!ABEBEx", userName: "abebe", violations: []string{"password must not contain your user name or email"}},
+		{name: "user name too short to matter", password: "Aa1!abxxxx", userName: "ab"},
+		{name: "email local part", password: "Aa1!kebedex", violations: []string{"password must not contain your user name or email"}},
+		{name: "banned word in any case", password: "Aa1!LOANxx", violations: []string{`password must not contain the word "loan"`}},
+		{name: "breached password", password: "Tr0ub4dor&3", violations: []string{"password appears in a list of breached passwords"}},
+		{name: "every rule broken", password: "a", violations: []string{length, "password must contain at least one uppercase letter", "password must contain at least one number", "password must contain at least one special character"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := policy.Validate(test.password, test.userName, "kebede@example.com")
+			var policyErr *domain.PasswordPolicyError
+			violations := []string(nil)
+			if errors.As(err, &policyErr) {
+				violations = policyErr.Violations
+			} else if err != nil {
+				t.Fatalf("Validate() = %v, want a *domain.PasswordPolicyError", err)
+			}
+			if !slices.Equal(violations, test.violations) {
+				t.Errorf("Validate() violations = %q, want %q", violations, test.violations)
+			}
+		})
+	}
+}
+
+func TestNewPasswordServiceCost(t *testing.T) {
+	// The upper bound, bcrypt.MaxCost, is left out: hashing the dummy password at that cost takes hours.
+	for _, cost := range []int{0, bcrypt.MinCost - 1, bcrypt.MaxCost + 1} {
+		if _, err := NewPasswordService(&Config{BcryptCost: cost}); err == nil {
+			t.Errorf("NewPasswordService() with cost %d succeeded, want an error", cost)
+		}
+	}
+
+	service, err := NewPasswordService(&Config{BcryptCost: bcrypt.MinCost})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := service.HashPassword("Aa1!xxxx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost, err := bcrypt.Cost([]byte(hash)); err != nil || cost != bcrypt.MinCost {
+		t.Errorf("hash cost = %d, %v, want %d", cost, err, bcrypt.MinCost)
+	}
+	if !service.ComparePassword("Aa1!xxxx", hash) || service.ComparePassword("Aa1!xxxy", hash) {
+		t.Error("ComparePassword() does not tell the password from another one")
+	}
+	if service.NeedsRehash(hash) {
+		t.Error("NeedsRehash() = true for a hash of the configured cost")
+	}
+
+	stronger, err := NewPasswordService(&Config{BcryptCost: bcrypt.MinCost + 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stronger.NeedsRehash(hash) {
+		t.Error("NeedsRehash() = false for a hash of another cost")
+	}
+	if stronger.NeedsRehash("not a bcrypt hash") {
+		t.Error("NeedsRehash() = true for a value that is not a bcrypt hash")
+	}
+}
@@ -0,0 +1,122 @@
+package infrastructure
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	domain "loan-tracker/Domain"
+)
+
+// Headers of the generic JSON gateway. The signature is "sha256=" followed by the hex HMAC-SHA256,
+// keyed with the shared secret, of the timestamp header, a dot and the raw body, the same scheme
+// our own webhooks use.
+const (
+	PaymentTimestampHeader = "X-Gateway-Timestamp"
+	PaymentSignatureHeader = "X-Gateway-Signature"
+)
+
+// Names of the payment providers, as used in the inbound webhook URL.
+const (
+	PaymentProviderGateway   = "gateway"
+	PaymentProviderSimulator = "simulator"
+)
+
+// jsonPayment is the body the generic JSON gateway posts.
+type jsonPayment struct {
+	TransactionID string    `json:"transaction_id"`
+	Reference     string    `json:"reference"`
+	Amount        float64   `json:"amount"`
+	Currency      string    `json:"currency"`
+	PaidAt        time.Time `json:"paid_at"`
+}
+
+// JSONPaymentGateway is the reference payment provider: the gateway posts a signed JSON body
+// {"transaction_id", "reference", "amount", "currency", "paid_at"}. Notifications whose timestamp
+// is further than the tolerance from now are refused, so captured requests cannot be replayed later.
+type JSONPaymentGateway struct {
+	name      string
+	secret    string
+	tolerance time.Duration
+}
+
+func NewJSONPaymentGateway(name string, secret string, tolerance time.Duration) *JSONPaymentGateway {
+	return &JSONPaymentGateway{
+		name:      name,
+		secret:    secret,
+		tolerance: tolerance,
+	}
+}
+
+func (jg *JSONPaymentGateway) Name() string {
+	return jg.name
+}
+
+func (jg *JSONPaymentGateway) Parse(header http.Header, body []byte) (domain.PaymentNotification, error) {
+	timestamp, err := strconv.ParseInt(header.Get(PaymentTimestampHeader), 10, 64)
+	if err != nil {
+		return domain.PaymentNotification{}, domain.ErrInvalidPaymentSignature
+	}
+	if math.Abs(time.Since(time.Unix(timestamp, 0)).Seconds()) > jg.tolerance.Seconds() {
+		return domain.PaymentNotification{}, domain.ErrInvalidPaymentSignature
+	}
+	expected := SignWebhook(jg.secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(header.Get(PaymentSignatureHeader))) {
+		return domain.PaymentNotification{}, domain.ErrInvalidPaymentSignature
+	}
+
+	var payment jsonPayment
+	if err := json.Unmarshal(body, &payment); err != nil {
+		return domain.PaymentNotification{}, fmt.Errorf("%w: %v", domain.ErrInvalidPaymentNotification, err)
+	}
+	if payment.PaidAt.IsZero() {
+		payment.PaidAt = time.Unix(timestamp, 0)
+	}
+	return domain.PaymentNotification{
+		TransactionID: payment.TransactionID,
+		Reference:     payment.Reference,
+		Amount:        payment.Amount,
+		Currency:      payment.Currency,
+		PaidAt:        payment.PaidAt.UTC(),
+	}, nil
+}
+
+// PaymentSimulator is a JSON gateway with a secret of its own that can produce notifications, so
+// payments can be tried locally without a real gateway.
+type PaymentSimulator struct {
+	*JSONPaymentGateway
+}
+
+func NewPaymentSimulator(tolerance time.Duration) (*PaymentSimulator, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return &PaymentSimulator{NewJSONPaymentGateway(PaymentProviderSimulator, hex.EncodeToString(secret), tolerance)}, nil
+}
+
+// Simulate returns the signed headers and body a gateway would post for the payment.
+func (ps *PaymentSimulator) Simulate(notification domain.PaymentNotification) (http.Header, []byte, error) {
+	body, err := json.Marshal(jsonPayment{
+		TransactionID: notification.TransactionID,
+		Reference:     notification.Reference,
+		Amount:        notification.Amount,
+		Currency:      notification.Currency,
+		PaidAt:        notification.PaidAt,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	timestamp := time.Now().Unix()
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set(PaymentTimestampHeader, strconv.FormatInt(timestamp, 10))
+	header.Set(PaymentSignatureHeader, SignWebhook(ps.secret, timestamp, body))
+	return header, body, nil
+}
@@ -0,0 +1,85 @@
+package infrastructure
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	domain "loan-tracker/Domain"
+)
+
+func TestJSONPaymentGatewayParse(t *testing.T) {
+	const secret = "gateway-secret"
+	body := []byte(`{"transaction_id":"tx-1","reference":"LN-7K3M9Q2D","amount":250.5,"currency":"ETB"}`)
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name      string
+		timestamp string
+		signature string
+		body      []byte
+		err       error
+	}{
+		{name: "valid signature", timestamp: strconv.FormatInt(now, 10), signature: SignWebhook(secret, now, body), body: body},
+		{name: "within tolerance", timestamp: strconv.FormatInt(now-240, 10), signature: SignWebhook(secret, now-240, body), body: body},
+		{name: "wrong secret", timestamp: strconv.FormatInt(now, 10), signature: SignWebhook("other-secret", now, body), body: body, err: domain.ErrInvalidPaymentSignature},
+		{name: "tampered body", timestamp: strconv.FormatInt(now, 10), signature: SignWebhook(secret, now, body), body: []byte(`{"transaction_id":"tx-1","reference":"LN-7K3M9Q2D","amount":2505,"currency":"ETB"}`), err: domain.ErrInvalidPaymentSignature},
+		{name: "signature of another timestamp", timestamp: strconv.FormatInt(now, 10), signature: SignWebhook(secret, now-1, body), body: body, err: domain.ErrInvalidPaymentSignature},
+		{name: "missing signature", timestamp: strconv.FormatInt(now, 10), body: body, err: domain.ErrInvalidPaymentSignature},
+		{name: "missing timestamp", signature: SignWebhook(secret, now, body), body: body, err: domain.ErrInvalidPaymentSignature},
+		{name: "stale timestamp", timestamp: strconv.FormatInt(now-600, 10), signature: SignWebhook(secret, now-600, body), body: body, err: domain.ErrInvalidPaymentSignature},
+		{name: "future timestamp", timestamp: strconv.FormatInt(now+600, 10), signature: SignWebhook(secret, now+600, body), body: body, err: domain.ErrInvalidPaymentSignature},
+		{name: "signed invalid JSON", timestamp: strconv.FormatInt(now, 10), signature: SignWebhook(secret, now, []byte("{")), body: []byte("{"), err: domain.ErrInvalidPaymentNotification},
+	}
+
+	gateway := NewJSONPaymentGateway(PaymentProviderGateway, secret, 5*time.Minute)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			header := http.Header{}
+			if test.timestamp != "" {
+				header.Set(PaymentTimestampHeader, test.timestamp)
+			}
+			if test.signature != "" {
+				header.Set(PaymentSignatureHeader, test.signature)
+			}
+			notification, err := gateway.Parse(header, test.body)
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Fatalf("Parse() error = %v, want %v", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if notification.TransactionID != "tx-1" || notification.Reference != "LN-7K3M9Q2D" || notification.Amount != 250.5 || notification.Currency != "ETB" {
+				t.Errorf("Parse() = %+v", notification)
+			}
+			timestamp, _ := strconv.ParseInt(test.timestamp, 10, 64)
+			if !notification.PaidAt.Equal(time.Unix(timestamp, 0)) {
+				t.Errorf("PaidAt = %v, want the timestamp %v", notification.PaidAt, time.Unix(timestamp, 0))
+			}
+		})
+	}
+}
+
+func TestPaymentSimulatorIsAcceptedByItsGateway(t *testing.T) {
+	simulator, err := NewPaymentSimulator(5 * time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paidAt := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	header, body, err := simulator.Simulate(domain.PaymentNotification{TransactionID: "sim-1", Reference: "LN-7K3M9Q2D", Amount: 100, Currency: "ETB", PaidAt: paidAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	notification, err := simulator.Parse(header, body)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if notification.TransactionID != "sim-1" || !notification.PaidAt.Equal(paidAt) {
+		t.Errorf("Parse() = %+v", notification)
+	}
+}
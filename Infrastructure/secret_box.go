@@ -0,0 +1,84 @@
+package infrastructure
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// sealedPrefix marks values sealed by a SecretBox. Values without it were stored before secrets were
+// encrypted and are read as they are.
+const sealedPrefix = "v1:"
+
+var errNoEncryptionKey = errors.New("TWO_FACTOR_ENCRYPTION_KEY is not set")
+
+// SecretBox encrypts secrets stored with a user, such as TOTP secrets, with AES-256-GCM. Each value
+// is bound to the id of its owner, so a sealed secret copied to another account does not open.
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+// NewSecretBox reads the base64 encoded 32-byte TwoFactorEncryptionKey. Without a key nothing can
+// be sealed, so two-factor enrollment fails until one is set, but the server still starts.
+func NewSecretBox(config *Config) (*SecretBox, error) {
+	if config.TwoFactorEncryptionKey == "" {
+		log.Println("TWO_FACTOR_ENCRYPTION_KEY not set: two-factor enrollment is disabled")
+		return &SecretBox{}, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(config.TwoFactorEncryptionKey)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("invalid TWO_FACTOR_ENCRYPTION_KEY: expected 32 bytes in base64")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretBox{aead: aead}, nil
+}
+
+// Seal encrypts the secret of the owner.
+func (b *SecretBox) Seal(secret string, owner string) (string, error) {
+	if b.aead == nil {
+		return "", errNoEncryptionKey
+	}
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(secret), []byte(owner))
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a secret sealed for the owner. Unsealed values are returned as they are.
+func (b *SecretBox) Open(value string, owner string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	if b.aead == nil {
+		return "", errNoEncryptionKey
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(value, sealedPrefix))
+	if err != nil || len(sealed) < b.aead.NonceSize() {
+		return "", errors.New("malformed sealed secret")
+	}
+	nonce, ciphertext := sealed[:b.aead.NonceSize()], sealed[b.aead.NonceSize():]
+	secret, err := b.aead.Open(nil, nonce, ciphertext, []byte(owner))
+	if err != nil {
+		return "", fmt.Errorf("error opening sealed secret: %w", err)
+	}
+	return string(secret), nil
+}
+
+// IsSealed reports whether the value was sealed by a SecretBox.
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
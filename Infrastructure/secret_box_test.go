@@ -0,0 +1,87 @@
+package infrastructure
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func newTestSecretBox(t *testing.T) *SecretBox {
+	t.Helper()
+	box, err := NewSecretBox(&Config{TwoFactorEncryptionKey: base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return box
+}
+
+func TestSecretBoxSealOpen(t *testing.T) {
+	box := newTestSecretBox(t)
+	sealed, err := box.Seal(rfc6238Secret, "owner-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSealed(sealed) || strings.Contains(sealed, rfc6238Secret) {
+		t.Fatalf("Seal() = %q, want a sealed value without the secret", sealed)
+	}
+	again, err := box.Seal(rfc6238Secret, "owner-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == sealed {
+		t.Error("Seal() gave the same value twice, want a fresh nonce each time")
+	}
+
+	opened, err := box.Open(sealed, "owner-1")
+	if err != nil || opened != rfc6238Secret {
+		t.Errorf("Open() = %q, %v, want the secret", opened, err)
+	}
+	if _, err := box.Open(sealed, "owner-2"); err == nil {
+		t.Error("Open() with another owner succeeded, want an error")
+	}
+	other, err := NewSecretBox(&Config{TwoFactorEncryptionKey: base64.StdEncoding.EncodeToString([]byte(strings.Repeat("x", 32)))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Open(sealed, "owner-1"); err == nil {
+		t.Error("Open() with another key succeeded, want an error")
+	}
+	tampered := sealed[:len(sealed)-2] + "AA"
+	if tampered == sealed {
+		tampered = sealed[:len(sealed)-2] + "BB"
+	}
+	if _, err := box.Open(tampered, "owner-1"); err == nil {
+		t.Error("Open() of a tampered value succeeded, want an error")
+	}
+	if _, err := box.Open(sealedPrefix+"!!", "owner-1"); err == nil {
+		t.Error("Open() of a malformed value succeeded, want an error")
+	}
+}
+
+func TestSecretBoxUnsealedAndMissingKey(t *testing.T) {
+	box := newTestSecretBox(t)
+	if opened, err := box.Open(rfc6238Secret, "owner-1"); err != nil || opened != rfc6238Secret {
+		t.Errorf("Open() of an unsealed value = %q, %v, want it as it is", opened, err)
+	}
+
+	disabled, err := NewSecretBox(&Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := disabled.Seal(rfc6238Secret, "owner-1"); err == nil {
+		t.Error("Seal() without a key succeeded, want an error")
+	}
+	sealed, err := box.Seal(rfc6238Secret, "owner-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := disabled.Open(sealed, "owner-1"); err == nil {
+		t.Error("Open() without a key succeeded, want an error")
+	}
+
+	for _, key := range []string{"not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if _, err := NewSecretBox(&Config{TwoFactorEncryptionKey: key}); err == nil {
+			t.Errorf("NewSecretBox(%q) succeeded, want an error", key)
+		}
+	}
+}
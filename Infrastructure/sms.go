@@ -0,0 +1,135 @@
+package infrastructure
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	domain "loan-tracker/Domain"
+)
+
+// SMS drivers selectable with SMS_DRIVER.
+const (
+	SMSDriverHTTP = "http"
+	SMSDriverFake = "fake"
+)
+
+// NewSMSSender builds the SMS driver named by SMSDriver. Without a driver, the HTTP gateway is used
+// when its URL is configured and the fake otherwise.
+func NewSMSSender(config *Config) (domain.SMSSender, error) {
+	driver := config.SMSDriver
+	if driver == "" {
+		driver = SMSDriverFake
+		if config.SMSGatewayURL != "" {
+			driver = SMSDriverHTTP
+		}
+	}
+	switch driver {
+	case SMSDriverHTTP:
+		if config.SMSGatewayURL == "" {
+			return nil, errors.New("SMS_GATEWAY_URL is required for the http sms driver")
+		}
+		return NewHTTPSMSSender(config.SMSGatewayURL, config.SMSGatewayToken, config.SMSFrom), nil
+	case SMSDriverFake:
+		return NewFakeSMSSender(), nil
+	}
+	return nil, fmt.Errorf("unknown SMS_DRIVER %q", driver)
+}
+
+func validateSMS(message domain.SMSMessage) error {
+	if message.To == "" {
+		return errors.New("sms has no recipient")
+	}
+	if message.Body == "" {
+		return errors.New("sms has no body")
+	}
+	return nil
+}
+
+// HTTPSMSSender posts text messages as JSON to an SMS gateway:
+// {"from": "...", "to": "...", "message": "..."}, authenticated with a bearer token.
+type HTTPSMSSender struct {
+	url    string
+	token  string
+	from   string
+	client *http.Client
+}
+
+func NewHTTPSMSSender(url string, token string, from string) *HTTPSMSSender {
+	return &HTTPSMSSender{
+		url:    url,
+		token:  token,
+		from:   from,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (hs *HTTPSMSSender) Send(message domain.SMSMessage) error {
+	if err := validateSMS(message); err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]string{
+		"from":    hs.from,
+		"to":      message.To,
+		"message": message.Body,
+	})
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPost, hs.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if hs.token != "" {
+		request.Header.Set("Authorization", "Bearer "+hs.token)
+	}
+	response, err := hs.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		err := fmt.Errorf("sms gateway responded %d: %s", response.StatusCode, bytes.TrimSpace(detail))
+		if response.StatusCode >= 400 && response.StatusCode < 500 && response.StatusCode != http.StatusTooManyRequests {
+			return fmt.Errorf("%w: %v", domain.ErrDeliveryRejected, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// FakeSMSSender logs text messages and keeps them in memory, for local development and tests.
+type FakeSMSSender struct {
+	mu       sync.Mutex
+	messages []domain.SMSMessage
+}
+
+func NewFakeSMSSender() *FakeSMSSender {
+	return &FakeSMSSender{}
+}
+
+func (fs *FakeSMSSender) Send(message domain.SMSMessage) error {
+	if err := validateSMS(message); err != nil {
+		return err
+	}
+	log.Printf("sms to %s: %s", message.To, message.Body)
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.messages = append(fs.messages, message)
+	return nil
+}
+
+// Messages returns the text messages sent so far, oldest first.
+func (fs *FakeSMSSender) Messages() []domain.SMSMessage {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return append([]domain.SMSMessage(nil), fs.messages...)
+}
@@ -0,0 +1,176 @@
+package infrastructure
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	domain "loan-tracker/Domain"
+)
+
+// DefaultStatementDateFormat is the date layout of mappings that do not set one.
+const DefaultStatementDateFormat = "2006-01-02"
+
+// ParseStatement reads the credits of a bank statement CSV with the bank's mapping. A file that
+// cannot be read at all is an error; a line that cannot be read is returned as invalid, and debits
+// and zero amounts as ignored. Line numbers are those of the file, counting the header.
+func ParseStatement(mapping domain.StatementMapping, content []byte, max_lines int) ([]domain.StatementLine, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(content, []byte("\ufeff"))))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+	if mapping.Delimiter != "" {
+		delimiter, size := utf8.DecodeRuneInString(mapping.Delimiter)
+		if size != len(mapping.Delimiter) {
+			return nil, errors.New("delimiter must be a single character")
+		}
+		reader.Comma = delimiter
+	}
+
+	for i := 0; i < mapping.SkipRows; i++ {
+		if _, err := reader.Read(); err != nil {
+			return nil, errors.New("statement ends before its header")
+		}
+	}
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("statement has no header")
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	column := func(name string) (int, error) {
+		if name == "" {
+			return -1, nil
+		}
+		index, ok := columns[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return -1, fmt.Errorf("statement has no column %q", name)
+		}
+		return index, nil
+	}
+	indexes := map[string]int{}
+	amountColumn := mapping.AmountColumn
+	if mapping.CreditColumn != "" {
+		amountColumn = mapping.CreditColumn
+	}
+	for field, name := range map[string]string{
+		"date":           mapping.DateColumn,
+		"amount":         amountColumn,
+		"reference":      mapping.ReferenceColumn,
+		"name":           mapping.NameColumn,
+		"description":    mapping.DescriptionColumn,
+		"transaction_id": mapping.TransactionIDColumn,
+	} {
+		if indexes[field], err = column(name); err != nil {
+			return nil, err
+		}
+	}
+	layout := mapping.DateFormat
+	if layout == "" {
+		layout = DefaultStatementDateFormat
+	}
+
+	lines := []domain.StatementLine{}
+	seen := map[string]int{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+			lines = append(lines, domain.StatementLine{Number: parseErr.Line, Status: domain.LineInvalid, Error: parseErr.Err.Error()})
+			continue
+		}
+		number, _ := reader.FieldPos(0)
+		if len(lines) >= max_lines {
+			return nil, fmt.Errorf("statement has more than %d lines", max_lines)
+		}
+		value := func(field string) string {
+			index := indexes[field]
+			if index < 0 || index >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[index])
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		line := domain.StatementLine{
+			Number:      number,
+			Reference:   value("reference"),
+			Name:        value("name"),
+			Description: value("description"),
+		}
+		line.Date, err = time.Parse(layout, value("date"))
+		if err != nil {
+			line.Status, line.Error = domain.LineInvalid, "invalid date "+strconv.Quote(value("date"))
+			lines = append(lines, line)
+			continue
+		}
+		line.Amount, err = parseStatementAmount(value("amount"), mapping.DecimalComma)
+		if err != nil {
+			line.Status, line.Error = domain.LineInvalid, "invalid amount "+strconv.Quote(value("amount"))
+			lines = append(lines, line)
+			continue
+		}
+		if line.Amount <= 0 {
+			line.Status = domain.LineIgnored
+			lines = append(lines, line)
+			continue
+		}
+
+		// Without a bank transaction id, a line is known by its content. Identical lines in one
+		// file are told apart by their order, which stays the same in a later, overlapping file.
+		line.TransactionKey = value("transaction_id")
+		if line.TransactionKey == "" {
+			content := strings.Join([]string{line.Date.Format(DefaultStatementDateFormat), strconv.FormatFloat(line.Amount, 'f', 2, 64), line.Reference, line.Name, line.Description}, "\x1f")
+			seen[content]++
+			sum := sha256.Sum256([]byte(content + "\x1f" + strconv.Itoa(seen[content])))
+			line.TransactionKey = "line:" + hex.EncodeToString(sum[:16])
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// parseStatementAmount reads amounts such as "1,250.00", "1.250,00" (with decimal_comma), "-40",
+// "(40.00)" or "ETB 300". Parentheses mark a negative amount.
+func parseStatementAmount(value string, decimal_comma bool) (float64, error) {
+	negative := strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")")
+	cleaned := strings.Map(func(r rune) rune {
+		if (r >= '0' && r <= '9') || r == '.' || r == ',' || r == '-' {
+			return r
+		}
+		return -1
+	}, value)
+	if decimal_comma {
+		cleaned = strings.ReplaceAll(cleaned, ".", "")
+		cleaned = strings.ReplaceAll(cleaned, ",", ".")
+	} else {
+		cleaned = strings.ReplaceAll(cleaned, ",", "")
+	}
+	amount, err := strconv.ParseFloat(cleaned, 64)
+	if err != nil || math.IsInf(amount, 0) || math.IsNaN(amount) {
+		return 0, errors.New("invalid amount")
+	}
+	if negative {
+		amount = -amount
+	}
+	return math.Round(amount*100) / 100, nil
+}
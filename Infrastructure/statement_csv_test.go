@@ -0,0 +1,186 @@
+package infrastructure
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	domain "loan-tracker/Domain"
+)
+
+func TestParseStatement(t *testing.T) {
+	basic := domain.StatementMapping{DateColumn: "Date", AmountColumn: "Amount", ReferenceColumn: "Reference", NameColumn: "Name"}
+
+	tests := []struct {
+		name     string
+		mapping  domain.StatementMapping
+		content  string
+		maxLines int
+		want     []domain.StatementLine
+		err      string
+	}{
+		{
+			name:    "credits",
+			mapping: basic,
+			content: "Date,Amount,Reference,Name\n2026-03-01,250.00,LN-7K3M9Q2D,Abebe Kebede\n2026-03-02,\"1,250.50\",,Sara Tesfaye\n",
+			want: []domain.StatementLine{
+				{Number: 2, Date: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), Amount: 250, Reference: "LN-7K3M9Q2D", Name: "Abebe Kebede"},
+				{Number: 3, Date: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), Amount: 1250.5, Name: "Sara Tesfaye"},
+			},
+		},
+		{
+			name:    "column names ignore case and spaces",
+			mapping: domain.StatementMapping{DateColumn: "date", AmountColumn: " AMOUNT "},
+			content: " Date , Amount \n2026-03-01,10\n",
+			want:    []domain.StatementLine{{Number: 2, Date: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), Amount: 10}},
+		},
+		{
+			name:    "debits and zero amounts are ignored",
+			mapping: basic,
+			content: "Date,Amount,Reference,Name\n2026-03-01,-40,,\n2026-03-01,(40.00),,\n2026-03-01,0.00,,\n",
+			want: []domain.StatementLine{
+				{Number: 2, Date: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), Amount: -40, Status: domain.LineIgnored},
+				{Number: 3, Date: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), Amount: -40, Status: domain.LineIgnored},
+				{Number: 4, Date: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), Amount: 0, Status: domain.LineIgnored},
+			},
+		},
+		{
+			name:    "unreadable lines are invalid",
+			mapping: basic,
+			content: "Date,Amount,Reference,Name\n01/03/2026,250,,\n2026-03-01,abc,,\n2026-03-01,,,\n",
+			want: []domain.StatementLine{
+				{Number: 2, Status: domain.LineInvalid, Error: `invalid date "01/03/2026"`},
+				{Number: 3, Date: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), Status: domain.LineInvalid, Error: `invalid amount "abc"`},
+				{Number: 4, Date: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), Status: domain.LineInvalid, Error: `invalid amount ""`},
+			},
+		},
+		{
+			name:    "blank lines are skipped",
+			mapping: basic,
+			content: "Date,Amount,Reference,Name\n,,,\n\n2026-03-01,5,,\n",
+			want:    []domain.StatementLine{{Number: 4, Date: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), Amount: 5}},
+		},
+		{
+			name:    "decimal comma, delimiter, date format and skipped rows",
+			mapping: domain.StatementMapping{Delimiter: ";", SkipRows: 2, DateColumn: "Valuta", DateFormat: "02.01.2006", AmountColumn: "Betrag", DecimalComma: true, DescriptionColumn: "Text"},
+			content: "Account statement\nMarch 2026\nValuta;Betrag;Text\n01.03.2026;1.250,00;ETB LN-7K3M9Q2D\n",
+			want:    []domain.StatementLine{{Number: 4, Date: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), Amount: 1250, Description: "ETB LN-7K3M9Q2D"}},
+		},
+		{
+			name:    "credit column",
+			mapping: domain.StatementMapping{DateColumn: "Date", AmountColumn: "Debit", CreditColumn: "Credit"},
+			content: "Date,Debit,Credit\n2026-03-01,,ETB 300\n2026-03-02,75.00,\n",
+			want: []domain.StatementLine{
+				{Number: 2, Date: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), Amount: 300},
+				{Number: 3, Date: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), Status: domain.LineInvalid, Error: `invalid amount ""`},
+			},
+		},
+		{
+			name:    "byte order mark",
+			mapping: basic,
+			content: "\ufeffDate,Amount,Reference,Name\n2026-03-01,250,,\n",
+			want:    []domain.StatementLine{{Number: 2, Date: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), Amount: 250}},
+		},
+		{name: "missing column", mapping: basic, content: "Date,Amount,Name\n2026-03-01,250,Abebe\n", err: `statement has no column "Reference"`},
+		{name: "no header", mapping: basic, content: "", err: "statement has no header"},
+		{name: "ends before its header", mapping: domain.StatementMapping{SkipRows: 3, DateColumn: "Date", AmountColumn: "Amount"}, content: "a\nb\n", err: "statement ends before its header"},
+		{name: "delimiter of several characters", mapping: domain.StatementMapping{Delimiter: ";;", DateColumn: "Date", AmountColumn: "Amount"}, content: "Date;;Amount\n", err: "delimiter must be a single character"},
+		{name: "too many lines", mapping: basic, content: "Date,Amount,Reference,Name\n2026-03-01,1,,\n2026-03-01,2,,\n2026-03-01,3,,\n", maxLines: 2, err: "statement has more than 2 lines"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			maxLines := test.maxLines
+			if maxLines == 0 {
+				maxLines = 100
+			}
+			lines, err := ParseStatement(test.mapping, []byte(test.content), maxLines)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("ParseStatement() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseStatement() error = %v", err)
+			}
+			if len(lines) != len(test.want) {
+				t.Fatalf("ParseStatement() = %d lines, want %d: %+v", len(lines), len(test.want), lines)
+			}
+			for i, want := range test.want {
+				got := lines[i]
+				if got.Number != want.Number || !got.Date.Equal(want.Date) || got.Amount != want.Amount || got.Reference != want.Reference ||
+					got.Name != want.Name || got.Description != want.Description || got.Status != want.Status || got.Error != want.Error {
+					t.Errorf("line %d = %+v, want %+v", i, got, want)
+				}
+				if want.Status == "" && got.TransactionKey == "" {
+					t.Errorf("line %d has no transaction key", i)
+				}
+			}
+		})
+	}
+}
+
+func TestParseStatementTransactionKeys(t *testing.T) {
+	mapping := domain.StatementMapping{DateColumn: "Date", AmountColumn: "Amount", ReferenceColumn: "Reference"}
+	parse := func(content string, mapping domain.StatementMapping) []string {
+		lines, err := ParseStatement(mapping, []byte(content), 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys := []string{}
+		for _, line := range lines {
+			keys = append(keys, line.TransactionKey)
+		}
+		return keys
+	}
+
+	march := parse("Date,Amount,Reference\n2026-03-01,100,LN-7K3M9Q2D\n2026-03-01,100,LN-7K3M9Q2D\n2026-03-02,100,LN-7K3M9Q2D\n", mapping)
+	if march[0] == march[1] || march[0] == march[2] || march[1] == march[2] {
+		t.Errorf("identical lines share a key: %v", march)
+	}
+	for _, key := range march {
+		if !strings.HasPrefix(key, "line:") {
+			t.Errorf("key %q is not derived from the line", key)
+		}
+	}
+	// A later statement repeating the same lines, in the same order, gives them the same keys.
+	overlap := parse("Date,Amount,Reference\n2026-03-01,100,LN-7K3M9Q2D\n2026-03-01,100,LN-7K3M9Q2D\n2026-03-02,100,LN-7K3M9Q2D\n2026-03-03,100,LN-7K3M9Q2D\n", mapping)
+	for i, key := range march {
+		if overlap[i] != key {
+			t.Errorf("line %d key = %q in the later statement, want %q", i, overlap[i], key)
+		}
+	}
+
+	mapping.TransactionIDColumn = "Transaction"
+	keys := parse("Date,Amount,Reference,Transaction\n2026-03-01,100,LN-7K3M9Q2D,FT2606012345\n2026-03-01,100,LN-7K3M9Q2D,\n", mapping)
+	if keys[0] != "FT2606012345" || !strings.HasPrefix(keys[1], "line:") {
+		t.Errorf("keys = %v, want the bank transaction id, then a line key", keys)
+	}
+}
+
+func TestParseStatementAmount(t *testing.T) {
+	tests := []struct {
+		value        string
+		decimalComma bool
+		want         float64
+		err          bool
+	}{
+		{value: "250", want: 250},
+		{value: "1,250.00", want: 1250},
+		{value: "1.250,00", decimalComma: true, want: 1250},
+		{value: "0,5", decimalComma: true, want: 0.5},
+		{value: "-40", want: -40},
+		{value: "(40.00)", want: -40},
+		{value: "ETB 300", want: 300},
+		{value: "10.005", want: 10.01},
+		{value: "", err: true},
+		{value: "abc", err: true},
+		{value: "1.2.3", err: true},
+	}
+	for _, test := range tests {
+		got, err := parseStatementAmount(test.value, test.decimalComma)
+		if (err != nil) != test.err || got != test.want {
+			t.Errorf("parseStatementAmount(%q, %t) = %v, %v, want %v", test.value, test.decimalComma, got, err, test.want)
+		}
+	}
+}
@@ -1,50 +1,246 @@
 package infrastructure
 
 import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	domain "loan-tracker/Domain"
 
 	jwt "github.com/golang-jwt/jwt/v4"
 )
 
-func CreateToken(claims jwt.Claims, secret string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	t, err := token.SignedString([]byte(secret))
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// TokenService signs tokens with the active asymmetric key and verifies them against every
+// configured key, so keys can be rotated without invalidating tokens signed by the previous one.
+type TokenService struct {
+	activeKid string
+	keys      map[string]signingKey
+	issuer    string
+	audience  string
+}
+
+// NewTokenService loads the PEM keys in JWTKeysDir. Each file is named <kid>.pem and holds an RSA
+// or Ed25519 private key, or only a public key for a retired key that still has to verify tokens.
+// Without a key directory it fails, unless JWTEphemeralKey asks for an ephemeral key, which only
+// suits local development: tokens stop verifying on restart and on other instances.
+func NewTokenService(config *Config) (*TokenService, error) {
+	service := &TokenService{
+		keys:     map[string]signingKey{},
+		issuer:   config.JWTIssuer,
+		audience: config.JWTAudience,
+	}
+	if config.JWTKeysDir == "" {
+		if !config.JWTEphemeralKey {
+			return nil, errors.New("JWT_KEYS_DIR is not set: configure the signing keys, or set JWT_EPHEMERAL_KEY=true for local development")
+		}
+		log.Println("JWT_EPHEMERAL_KEY set: signing tokens with an ephemeral key, for local development only")
+		key, err := generateSigningKey()
+		if err != nil {
+			return nil, err
+		}
+		service.keys[key.kid] = key
+		service.activeKid = key.kid
+		return service, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(config.JWTKeysDir, "*.pem"))
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	for _, file := range files {
+		key, err := loadSigningKey(file)
+		if err != nil {
+			return nil, fmt.Errorf("loading %s: %w", file, err)
+		}
+		service.keys[key.kid] = key
 	}
-	return t, err
-}
 
-func IsAuthorized(requestToken string, secret string) (bool, error) {
-	_, err := jwt.Parse(requestToken, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	service.activeKid = config.JWTActiveKid
+	if service.activeKid == "" {
+		// Default to the last private key by name, so date-prefixed kids rotate by adding a file.
+		kids := []string{}
+		for kid, key := range service.keys {
+			if key.private != nil {
+				kids = append(kids, kid)
+			}
 		}
-		return []byte(secret), nil
-	})
+		sort.Strings(kids)
+		if len(kids) > 0 {
+			service.activeKid = kids[len(kids)-1]
+		}
+	}
+	active, ok := service.keys[service.activeKid]
+	if !ok || active.private == nil {
+		return nil, errors.New("no private signing key found for the active key id")
+	}
+	return service, nil
+}
+
+func generateSigningKey() (signingKey, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
 	if err != nil {
-		return false, err
+		return signingKey{}, err
+	}
+	kid := make([]byte, 8)
+	if _, err := rand.Read(kid); err != nil {
+		return signingKey{}, err
 	}
-	return true, nil
+	return signingKey{
+		kid:     "ephemeral-" + hex.EncodeToString(kid),
+		method:  jwt.SigningMethodEdDSA,
+		private: private,
+		public:  public,
+	}, nil
 }
 
-func ExtractIDFromToken(requestToken string, secret string) (string, error) {
-	token, err := jwt.Parse(requestToken, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+func loadSigningKey(file string) (signingKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return signingKey{}, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return signingKey{}, errors.New("no PEM block found")
+	}
+	key := signingKey{kid: strings.TrimSuffix(filepath.Base(file), ".pem")}
+
+	var parsed interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return signingKey{}, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return signingKey{}, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.method, key.public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.method, key.public = jwt.SigningMethodEdDSA, k
+	default:
+		return signingKey{}, errors.New("only RSA and Ed25519 keys are supported")
+	}
+	return key, nil
+}
+
+// CreateToken stamps the issuer, audience and issue time on the claims and signs them with the active key.
+func (ts *TokenService) CreateToken(claims *domain.JwtCustomClaims) (string, error) {
+	key := ts.keys[ts.activeKid]
+	now := time.Now()
+	claims.Issuer = ts.issuer
+	claims.Audience = jwt.ClaimStrings{ts.audience}
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.NotBefore = jwt.NewNumericDate(now)
+
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.private)
+}
+
+func (ts *TokenService) ExtractClaimsFromToken(requestToken string) (*domain.JwtCustomClaims, error) {
+	claims := &domain.JwtCustomClaims{}
+	parser := jwt.NewParser(jwt.WithoutClaimsValidation())
+	token, err := parser.ParseWithClaims(requestToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := ts.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key: %q", kid)
+		}
+		// The algorithm is pinned by the key, never taken from the token header.
+		if token.Method.Alg() != key.method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return []byte(secret), nil
+		return key.public, nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	now := time.Now()
+	if !claims.VerifyExpiresAt(now, true) {
+		return nil, errors.New("token expired")
+	}
+	if !claims.VerifyNotBefore(now, false) {
+		return nil, errors.New("token not valid yet")
+	}
+	if !claims.VerifyIssuer(ts.issuer, true) {
+		return nil, errors.New("invalid token issuer")
+	}
+	if !claims.VerifyAudience(ts.audience, true) {
+		return nil, errors.New("invalid token audience")
+	}
+	return claims, nil
+}
 
+func (ts *TokenService) ExtractIDFromToken(requestToken string) (string, error) {
+	claims, err := ts.ExtractClaimsFromToken(requestToken)
 	if err != nil {
 		return "", err
 	}
+	if claims.ID == "" {
+		return "", errors.New("invalid token")
+	}
+	return claims.ID, nil
+}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-
-	if !ok && !token.Valid {
-		return "", fmt.Errorf("invalid token")
+// JWKS returns the public keys that verify tokens, in the order of their key IDs.
+func (ts *TokenService) JWKS() domain.JSONWebKeySet {
+	kids := make([]string, 0, len(ts.keys))
+	for kid := range ts.keys {
+		kids = append(kids, kid)
 	}
+	sort.Strings(kids)
 
-	return claims["id"].(string), nil
+	set := domain.JSONWebKeySet{Keys: []domain.JSONWebKey{}}
+	for _, kid := range kids {
+		key := ts.keys[kid]
+		jwk := domain.JSONWebKey{Kid: kid, Alg: key.method.Alg(), Use: "sig"}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
 }
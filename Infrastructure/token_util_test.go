@@ -0,0 +1,186 @@
+package infrastructure
+
+import (
+	"crypto/ed25519"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	domain "loan-tracker/Domain"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newTestTokenService(t *testing.T) *TokenService {
+	t.Helper()
+	service, err := NewTokenService(&Config{JWTEphemeralKey: true, JWTIssuer: "loan-tracker", JWTAudience: "loan-tracker-api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service
+}
+
+func TestNewTokenServiceRequiresKeys(t *testing.T) {
+	if _, err := NewTokenService(&Config{}); err == nil {
+		t.Error("NewTokenService() without JWT_KEYS_DIR succeeded, want an error")
+	}
+	if _, err := NewTokenService(&Config{JWTKeysDir: t.TempDir()}); err == nil {
+		t.Error("NewTokenService() with an empty key directory succeeded, want an error")
+	}
+}
+
+func TestExtractClaimsFromToken(t *testing.T) {
+	service := newTestTokenService(t)
+	active := service.keys[service.activeKid]
+	now := time.Now()
+	claims := func(change func(claims *domain.JwtCustomClaims)) *domain.JwtCustomClaims {
+		claims := &domain.JwtCustomClaims{
+			ID:        "user-1",
+			SessionID: "session-1",
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    "loan-tracker",
+				Audience:  jwt.ClaimStrings{"loan-tracker-api"},
+				IssuedAt:  jwt.NewNumericDate(now),
+				NotBefore: jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			},
+		}
+		if change != nil {
+			change(claims)
+		}
+		return claims
+	}
+	sign := func(method jwt.SigningMethod, key interface{}, kid string, claims *domain.JwtCustomClaims) string {
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{name: "valid", token: sign(active.method, active.private, active.kid, claims(nil)), valid: true},
+		{name: "HMAC keyed with the public key", token: sign(jwt.SigningMethodHS256, []byte(active.public.(ed25519.PublicKey)), active.kid, claims(nil))},
+		{name: "unsigned", token: sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, active.kid, claims(nil))},
+		{name: "unknown kid", token: sign(active.method, active.private, "retired", claims(nil))},
+		{name: "no kid", token: sign(active.method, active.private, "", claims(nil))},
+		{name: "signed by another key", token: sign(active.method, otherKey, active.kid, claims(nil))},
+		{name: "expired", token: sign(active.method, active.private, active.kid, claims(func(c *domain.JwtCustomClaims) {
+			c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute))
+		}))},
+		{name: "no expiry", token: sign(active.method, active.private, active.kid, claims(func(c *domain.JwtCustomClaims) {
+			c.ExpiresAt = nil
+		}))},
+		{name: "not valid yet", token: sign(active.method, active.private, active.kid, claims(func(c *domain.JwtCustomClaims) {
+			c.NotBefore = jwt.NewNumericDate(now.Add(time.Hour))
+		}))},
+		{name: "wrong issuer", token: sign(active.method, active.private, active.kid, claims(func(c *domain.JwtCustomClaims) {
+			c.Issuer = "someone-else"
+		}))},
+		{name: "no issuer", token: sign(active.method, active.private, active.kid, claims(func(c *domain.JwtCustomClaims) {
+			c.Issuer = ""
+		}))},
+		{name: "wrong audience", token: sign(active.method, active.private, active.kid, claims(func(c *domain.JwtCustomClaims) {
+			c.Audience = jwt.ClaimStrings{"another-api"}
+		}))},
+		{name: "no audience", token: sign(active.method, active.private, active.kid, claims(func(c *domain.JwtCustomClaims) {
+			c.Audience = nil
+		}))},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := service.ExtractClaimsFromToken(test.token)
+			if test.valid && err != nil {
+				t.Errorf("ExtractClaimsFromToken() = %v, want the claims", err)
+			}
+			if !test.valid && err == nil {
+				t.Error("ExtractClaimsFromToken() succeeded, want an error")
+			}
+		})
+	}
+}
+
+// fakeSessionRepo finds the one session the tests sign tokens for.
+type fakeSessionRepo struct {
+	domain.SessionRepositoryInterface
+	session domain.Session
+}
+
+func (fr *fakeSessionRepo) FindSessionByID(id string) (domain.Session, error) {
+	return fr.session, nil
+}
+
+func (fr *fakeSessionRepo) TouchSession(id string, at time.Time) error {
+	return nil
+}
+
+// fakeUserRepo finds the signed-in user.
+type fakeUserRepo struct {
+	domain.UserRepositoryInterface
+	user domain.User
+}
+
+func (fr *fakeUserRepo) FindUserByID(id string) (domain.User, error) {
+	return fr.user, nil
+}
+
+func TestAuthenticationMiddlewareTokenPurpose(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := newTestTokenService(t)
+	user := domain.User{ID: primitive.NewObjectID(), Role: domain.RoleUser}
+	session := domain.Session{ID: primitive.NewObjectID(), UserID: user.ID, LastUsed_At: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}
+	auth := NewAuthMiddleware(Config{}, service, &fakeSessionRepo{session: session}, &fakeUserRepo{user: user})
+	router := gin.New()
+	router.GET("/", auth.AuthenticationMiddleware(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	token := func(session_id string, purpose string) string {
+		signed, err := service.CreateToken(&domain.JwtCustomClaims{
+			ID:        user.ID.Hex(),
+			SessionID: session_id,
+			Purpose:   purpose,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{name: "access token", token: token(session.ID.Hex(), ""), status: http.StatusOK},
+		{name: "refresh token", token: token(session.ID.Hex(), domain.RefreshTokenPurpose), status: http.StatusUnauthorized},
+		{name: "two-factor challenge", token: token("", domain.TwoFactorChallengePurpose), status: http.StatusUnauthorized},
+		{name: "no session", token: token("", ""), status: http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.Header.Set("Authorization", "Bearer "+test.token)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+			if recorder.Code != test.status {
+				t.Errorf("status = %d, want %d", recorder.Code, test.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package infrastructure
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of the RFC 6238 test vectors, "12345678901234567890", in base32.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestGenerateTOTPCode(t *testing.T) {
+	// The RFC gives eight-digit codes; six-digit codes are their last six digits.
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{unix: 59, code: "287082"},
+		{unix: 1111111109, code: "081804"},
+		{unix: 1111111111, code: "050471"},
+		{unix: 1234567890, code: "005924"},
+		{unix: 2000000000, code: "279037"},
+		{unix: 20000000000, code: "353130"},
+	}
+	for _, test := range tests {
+		code, err := GenerateTOTPCode(rfc6238Secret, time.Unix(test.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != test.code {
+			t.Errorf("GenerateTOTPCode(%d) = %s, want %s", test.unix, code, test.code)
+		}
+	}
+}
+
+func TestValidateTOTPCode(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := TOTPStep(now)
+	codeAt := func(step int64) string {
+		code, err := totpCodeForStep(rfc6238Secret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		ok     bool
+		step   int64
+	}{
+		{name: "current step", secret: rfc6238Secret, code: codeAt(step), ok: true, step: step},
+		{name: "lower-case secret and spaces around the code", secret: "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", code: " " + codeAt(step) + " ", ok: true, step: step},
+		{name: "previous step", secret: rfc6238Secret, code: codeAt(step - 1), ok: true, step: step - 1},
+		{name: "next step", secret: rfc6238Secret, code: codeAt(step + 1), ok: true, step: step + 1},
+		{name: "two steps ago", secret: rfc6238Secret, code: codeAt(step - 2)},
+		{name: "two steps ahead", secret: rfc6238Secret, code: codeAt(step + 2)},
+		{name: "eight digits", secret: rfc6238Secret, code: "14050471"},
+		{name: "other secret", secret: "JBSWY3DPEHPK3PXP", code: codeAt(step)},
+		{name: "invalid secret", secret: "not base32!", code: codeAt(step)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			matched, ok := ValidateTOTPCode(test.secret, test.code, now)
+			if ok != test.ok || matched != test.step {
+				t.Errorf("ValidateTOTPCode() = %d, %v, want %d, %v", matched, ok, test.step, test.ok)
+			}
+		})
+	}
+}
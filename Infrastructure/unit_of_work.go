@@ -0,0 +1,42 @@
+package infrastructure
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MongoUnitOfWork runs functions in a MongoDB transaction. Transactions need a replica set or a
+// sharded cluster, so they are only used when MONGO_TRANSACTIONS is enabled; otherwise the writes
+// run one after the other and callers order them so the important one comes first.
+type MongoUnitOfWork struct {
+	client       *mongo.Client
+	transactions bool
+	timeout      time.Duration
+}
+
+func NewMongoUnitOfWork(client *mongo.Client, config *Config) *MongoUnitOfWork {
+	return &MongoUnitOfWork{
+		client:       client,
+		transactions: config.MongoTransactions,
+		timeout:      time.Duration(config.ContextTimeout) * time.Second,
+	}
+}
+
+func (uow *MongoUnitOfWork) Do(fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), uow.timeout)
+	defer cancel()
+	if !uow.transactions {
+		return fn(ctx)
+	}
+	session, err := uow.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessionCtx)
+	})
+	return err
+}
@@ -0,0 +1,266 @@
+package infrastructure
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	domain "loan-tracker/Domain"
+
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Push drivers selectable with PUSH_DRIVER.
+const (
+	PushDriverWebPush = "webpush"
+	PushDriverFake    = "fake"
+)
+
+// pushRecordSize is the record size announced in the aes128gcm header. Payloads are kept well
+// below it, so every message is a single record.
+const pushRecordSize = 4096
+
+// NewPushSender builds the push driver named by PushDriver. Without a driver, Web Push is used
+// when VAPID keys are configured and the fake otherwise.
+func NewPushSender(config *Config) (domain.PushSender, error) {
+	driver := config.PushDriver
+	if driver == "" {
+		driver = PushDriverFake
+		if config.VAPIDPrivateKey != "" {
+			driver = PushDriverWebPush
+		}
+	}
+	switch driver {
+	case PushDriverWebPush:
+		return NewWebPushSender(config.VAPIDPublicKey, config.VAPIDPrivateKey, config.VAPIDSubject)
+	case PushDriverFake:
+		return NewFakePushSender(), nil
+	}
+	return nil, fmt.Errorf("unknown PUSH_DRIVER %q", driver)
+}
+
+// WebPushSender sends notifications to browsers' push services, encrypted as RFC 8291 requires
+// and authenticated with VAPID (RFC 8292).
+type WebPushSender struct {
+	publicKey  []byte
+	privateKey *ecdsa.PrivateKey
+	subject    string
+	client     *http.Client
+}
+
+// NewWebPushSender takes the VAPID key pair as unpadded base64url strings: the uncompressed P-256
+// public key and the private scalar, as generated by the usual web-push tools.
+func NewWebPushSender(publicKey string, privateKey string, subject string) (*WebPushSender, error) {
+	public, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(publicKey, "="))
+	if err != nil || len(public) != 65 || public[0] != 4 {
+		return nil, errors.New("VAPID_PUBLIC_KEY must be an uncompressed P-256 public key in base64url")
+	}
+	private, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(privateKey, "="))
+	if err != nil || len(private) != 32 {
+		return nil, errors.New("VAPID_PRIVATE_KEY must be a P-256 private key in base64url")
+	}
+	key, err := ecdh.P256().NewPrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(key.PublicKey().Bytes(), public) {
+		return nil, errors.New("VAPID_PUBLIC_KEY does not match VAPID_PRIVATE_KEY")
+	}
+	if subject == "" {
+		return nil, errors.New("VAPID_SUBJECT is required for web push")
+	}
+	return &WebPushSender{
+		publicKey: public,
+		privateKey: &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(public[1:33]),
+				Y:     new(big.Int).SetBytes(public[33:]),
+			},
+			D: new(big.Int).SetBytes(private),
+		},
+		subject: subject,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (wp *WebPushSender) Send(message domain.PushMessage) error {
+	payload, err := json.Marshal(map[string]string{
+		"title": message.Title,
+		"body":  message.Body,
+		"url":   message.URL,
+	})
+	if err != nil {
+		return err
+	}
+	body, err := encryptPushPayload(message.Subscription, payload)
+	if err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrDeliveryRejected, err)
+	}
+	authorization, err := wp.vapidAuthorization(message.Subscription.Endpoint)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPost, message.Subscription.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/octet-stream")
+	request.Header.Set("Content-Encoding", "aes128gcm")
+	request.Header.Set("TTL", "86400")
+	request.Header.Set("Authorization", authorization)
+	response, err := wp.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	switch {
+	case response.StatusCode == http.StatusNotFound || response.StatusCode == http.StatusGone:
+		return fmt.Errorf("%w: push subscription expired", domain.ErrDeliveryRejected)
+	case response.StatusCode >= 300:
+		detail, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("push service responded %d: %s", response.StatusCode, bytes.TrimSpace(detail))
+	}
+	return nil
+}
+
+// vapidAuthorization signs the VAPID token for the push service that hosts the endpoint.
+func (wp *WebPushSender) vapidAuthorization(endpoint string) (string, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"aud": parsed.Scheme + "://" + parsed.Host,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": wp.subject,
+	}).SignedString(wp.privateKey)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("vapid t=%s, k=%s", token, base64.RawURLEncoding.EncodeToString(wp.publicKey)), nil
+}
+
+// encryptPushPayload encrypts the payload for the subscription with the aes128gcm content coding
+// (RFC 8188), keyed as described in RFC 8291.
+func encryptPushPayload(subscription domain.PushSubscription, payload []byte) ([]byte, error) {
+	userPublic, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(subscription.P256dh, "="))
+	if err != nil {
+		return nil, errors.New("invalid p256dh key")
+	}
+	userKey, err := ecdh.P256().NewPublicKey(userPublic)
+	if err != nil {
+		return nil, errors.New("invalid p256dh key")
+	}
+	authSecret, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(subscription.Auth, "="))
+	if err != nil || len(authSecret) == 0 {
+		return nil, errors.New("invalid auth secret")
+	}
+
+	serverKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return encryptPushRecord(userKey, authSecret, serverKey, salt, payload)
+}
+
+func encryptPushRecord(userKey *ecdh.PublicKey, authSecret []byte, serverKey *ecdh.PrivateKey, salt []byte, payload []byte) ([]byte, error) {
+	userPublic := userKey.Bytes()
+	sharedSecret, err := serverKey.ECDH(userKey)
+	if err != nil {
+		return nil, err
+	}
+	serverPublic := serverKey.PublicKey().Bytes()
+
+	keyInfo := append([]byte("WebPush: info\x00"), userPublic...)
+	keyInfo = append(keyInfo, serverPublic...)
+	ikm, err := hkdfExpand(authSecret, sharedSecret, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	contentKey, err := hkdfExpand(salt, ikm, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdfExpand(salt, ikm, []byte("Content-Encoding: nonce\x00"), 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// A single, final record: the payload followed by the 0x02 delimiter and no padding.
+	record := gcm.Seal(nil, nonce, append(payload, 2), nil)
+
+	header := make([]byte, 0, 16+4+1+len(serverPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, pushRecordSize)
+	header = append(header, byte(len(serverPublic)))
+	header = append(header, serverPublic...)
+	return append(header, record...), nil
+}
+
+func hkdfExpand(salt []byte, secret []byte, info []byte, length int) ([]byte, error) {
+	out := make([]byte, length)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, info), out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// FakePushSender logs push notifications and keeps them in memory, for local development and tests.
+type FakePushSender struct {
+	mu       sync.Mutex
+	messages []domain.PushMessage
+}
+
+func NewFakePushSender() *FakePushSender {
+	return &FakePushSender{}
+}
+
+func (fp *FakePushSender) Send(message domain.PushMessage) error {
+	if message.Subscription.Endpoint == "" {
+		return errors.New("push message has no subscription")
+	}
+	log.Printf("push to %s: %s - %s", message.Subscription.Endpoint, message.Title, message.Body)
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	fp.messages = append(fp.messages, message)
+	return nil
+}
+
+// Messages returns the push notifications sent so far, oldest first.
+func (fp *FakePushSender) Messages() []domain.PushMessage {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	return append([]domain.PushMessage(nil), fp.messages...)
+}
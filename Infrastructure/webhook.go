@@ -0,0 +1,141 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"time"
+
+	domain "loan-tracker/Domain"
+)
+
+// Headers of a webhook delivery. The signature is "sha256=" followed by the hex HMAC-SHA256, keyed
+// with the endpoint's secret, of the timestamp header, a dot and the raw body.
+const (
+	WebhookEventIDHeader   = "X-Webhook-Id"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// SignWebhook returns the signature header value of a webhook body sent at the unix timestamp.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ErrPrivateWebhookHost refuses webhook hosts on loopback, private, link-local and other addresses
+// that are not on the public internet, so an endpoint cannot reach the server's own network.
+var ErrPrivateWebhookHost = errors.New("webhook host must be a public address")
+
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+}
+
+// isPublicAddress reports whether the address is a unicast address of the public internet.
+func isPublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateWebhookHost resolves the host of a webhook URL and returns ErrPrivateWebhookHost unless
+// every address it resolves to is public.
+func ValidateWebhookHost(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("webhook host does not resolve: %w", err)
+	}
+	for _, addr := range addrs {
+		if !isPublicAddress(addr) {
+			return ErrPrivateWebhookHost
+		}
+	}
+	return nil
+}
+
+// HTTPWebhookSender posts webhook deliveries to their endpoint. Each attempt is signed with a fresh
+// timestamp so receivers can reject old or replayed requests. Any 2xx response is a success; a 410
+// Gone stops the retries, as does an endpoint whose host now points at a private address.
+type HTTPWebhookSender struct {
+	client    *http.Client
+	userAgent string
+}
+
+func NewWebhookSender(config *Config) *HTTPWebhookSender {
+	timeout := time.Duration(config.WebhookTimeoutSeconds) * time.Second
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		// The address is checked as it is dialled, after resolution, so a host that was public when
+		// the endpoint was saved cannot be pointed at a private address later, nor redirected to one.
+		Control: func(network string, address string, conn syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !isPublicAddress(addrPort.Addr()) {
+				return ErrPrivateWebhookHost
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &HTTPWebhookSender{
+		client:    &http.Client{Timeout: timeout, Transport: transport},
+		userAgent: config.BrandName + " Webhooks",
+	}
+}
+
+func (ws *HTTPWebhookSender) Send(endpoint domain.WebhookEndpoint, delivery domain.WebhookDelivery) error {
+	body := []byte(delivery.Body)
+	request, err := http.NewRequest(http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrDeliveryRejected, err)
+	}
+	timestamp := time.Now().Unix()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", ws.userAgent)
+	request.Header.Set(WebhookEventIDHeader, delivery.EventID)
+	request.Header.Set(WebhookEventHeader, delivery.Event)
+	request.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(WebhookSignatureHeader, SignWebhook(endpoint.Secret, timestamp, body))
+	response, err := ws.client.Do(request)
+	if errors.Is(err, ErrPrivateWebhookHost) {
+		return fmt.Errorf("%w: %v", domain.ErrDeliveryRejected, err)
+	}
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		err := fmt.Errorf("webhook endpoint responded %d: %s", response.StatusCode, bytes.TrimSpace(detail))
+		if response.StatusCode == http.StatusGone {
+			return fmt.Errorf("%w: %v", domain.ErrDeliveryRejected, err)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	domain "loan-tracker/Domain"
+)
+
+func TestIsPublicAddress(t *testing.T) {
+	tests := []struct {
+		addr   string
+		public bool
+	}{
+		{addr: "93.184.216.34", public: true},
+		{addr: "2606:2800:220:1:248:1893:25c8:1946", public: true},
+		{addr: "127.0.0.1"},
+		{addr: "::1"},
+		{addr: "10.1.2.3"},
+		{addr: "172.16.0.1"},
+		{addr: "192.168.1.1"},
+		{addr: "169.254.169.254"},
+		{addr: "fe80::1"},
+		{addr: "fd00::1"},
+		{addr: "100.64.0.1"},
+		{addr: "0.0.0.0"},
+		{addr: "::"},
+		{addr: "224.0.0.1"},
+		{addr: "255.255.255.255"},
+		{addr: "::ffff:127.0.0.1"},
+		{addr: "::ffff:10.0.0.1"},
+	}
+	for _, test := range tests {
+		if got := isPublicAddress(netip.MustParseAddr(test.addr)); got != test.public {
+			t.Errorf("isPublicAddress(%s) = %v, want %v", test.addr, got, test.public)
+		}
+	}
+}
+
+func TestValidateWebhookHost(t *testing.T) {
+	for _, host := range []string{"127.0.0.1", "10.0.0.8", "169.254.169.254", "::1"} {
+		if err := ValidateWebhookHost(context.Background(), host); !errors.Is(err, ErrPrivateWebhookHost) {
+			t.Errorf("ValidateWebhookHost(%s) = %v, want ErrPrivateWebhookHost", host, err)
+		}
+	}
+	if err := ValidateWebhookHost(context.Background(), "93.184.216.34"); err != nil {
+		t.Errorf("ValidateWebhookHost() of a public address = %v, want nil", err)
+	}
+}
+
+func TestWebhookSenderRefusesPrivateAddresses(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	sender := NewWebhookSender(&Config{WebhookTimeoutSeconds: 5})
+	err := sender.Send(domain.WebhookEndpoint{URL: server.URL, Secret: "0123456789abcdef"}, domain.WebhookDelivery{EventID: "evt-1", Event: "loan.created", Body: "{}"})
+	if !errors.Is(err, domain.ErrDeliveryRejected) {
+		t.Errorf("Send() to a loopback endpoint = %v, want ErrDeliveryRejected", err)
+	}
+	if called {
+		t.Error("the loopback endpoint was reached")
+	}
+}
@@ -5,9 +5,11 @@ import (
 	domain "loan-tracker/Domain"
 	infrastructure "loan-tracker/Infrastructure"
 	utils "loan-tracker/Utils"
+	"regexp"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -30,7 +32,7 @@ func (ar *AdminRepository) GetAllUsers(pageNo, pageSize int64) ([]domain.User, e
 	defer cancel()
 	
 	options := utils.PaginationByPage(pageNo, pageSize)
-	cursor, err := ar.collection.Find(ctx, bson.M{}, options)
+	cursor, err := ar.collection.Find(ctx, notDeleted(bson.M{}), options)
 	if err != nil {
 		return nil, err
 	}
@@ -44,12 +46,91 @@ func (ar *AdminRepository) GetAllUsers(pageNo, pageSize int64) ([]domain.User, e
 }
 
 
-func (ar *AdminRepository) DeleteUser(id string) error{
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// SearchUsers pages through the users matching every non-empty field of the filter, newest first.
+func (ar *AdminRepository) SearchUsers(filter domain.UserSearchFilter, pageNo, pageSize int64) ([]domain.User, error){
+	users := []domain.User{}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(ar.config.ContextTimeout)*time.Second)
 	defer cancel()
-	_, err := ar.collection.DeleteOne(ctx, bson.M{"_id": id})
+
+	query := notDeleted(bson.M{})
+	if filter.Email != ""{
+		query["email"] = bson.M{"$regex": regexp.QuoteMeta(filter.Email), "$options": "i"}
+	}
+	if filter.Name != ""{
+		query["user_name"] = bson.M{"$regex": regexp.QuoteMeta(filter.Name), "$options": "i"}
+	}
+	switch filter.Status{
+	case domain.UserStatusActive:
+		query["is_verified"] = true
+		query["is_suspended"] = bson.M{"$ne": true}
+	case domain.UserStatusSuspended:
+		query["is_suspended"] = true
+	case domain.UserStatusUnverified:
+		query["is_verified"] = false
+	case domain.UserStatusDeleted:
+		query["deleted_at"] = bson.M{"$ne": nil}
+	}
+	created := bson.M{}
+	if !filter.RegisteredAfter.IsZero(){
+		created["$gte"] = filter.RegisteredAfter
+	}
+	if !filter.RegisteredBefore.IsZero(){
+		created["$lt"] = filter.RegisteredBefore
+	}
+	if len(created) > 0{
+		query["created_at"] = created
+	}
+
+	options := utils.PaginationByPage(pageNo, pageSize)
+	options.SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := ar.collection.Find(ctx, query, options)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	err = cursor.All(ctx, &users)
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+
+func (ar *AdminRepository) FindDeletedUserByID(id string) (domain.User, error){
+	var user domain.User
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(ar.config.ContextTimeout)*time.Second)
+	defer cancel()
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return user, err
+	}
+	filter := bson.M{"_id": objectId, "deleted_at": bson.M{"$ne": nil}}
+	err = ar.collection.FindOne(ctx, filter).Decode(&user)
+	if err != nil {
+		return user, err
+	}
+	return user, nil
+}
+
+
+// RestoreUserWithContext clears the soft deletion of a user as part of the unit of work carried by
+// ctx. It returns domain.ErrEmailTaken or domain.ErrUserNameTaken when an active account has taken
+// the user's email or user name.
+func (ar *AdminRepository) RestoreUserWithContext(ctx context.Context, id string) error{
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(ar.config.ContextTimeout)*time.Second)
+	defer cancel()
+	objectId, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return err
 	}
+	update := bson.M{"$set": bson.M{
+		"deleted_at": nil,
+		"deleted_by": "",
+		"deletion_reason": "",
+	}}
+	_, err = ar.collection.UpdateOne(ctx, bson.M{"_id": objectId}, update)
+	if err != nil {
+		return duplicateUserError(err)
+	}
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"context"
+	domain "loan-tracker/Domain"
+	infrastructure "loan-tracker/Infrastructure"
+	utils "loan-tracker/Utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// auditAppendAttempts bounds how often Append retries when other writers take the next sequence.
+const auditAppendAttempts = 10
+
+// AuditRepository stores the audit log. It has no delete, and the only update erases client details
+// outside of the hashed content: records are otherwise only appended.
+type AuditRepository struct {
+	collection *mongo.Collection
+	config     *infrastructure.Config
+}
+
+func NewAuditRepository(collection *mongo.Collection, config *infrastructure.Config) *AuditRepository {
+	return &AuditRepository{
+		collection: collection,
+		config:     config,
+	}
+}
+
+// EnsureIndexes creates the unique sequence index that keeps the chain linear, and the indexes the
+// log is filtered by.
+func (ar *AuditRepository) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(ar.config.ContextTimeout)*time.Second)
+	defer cancel()
+	_, err := ar.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "sequence", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "actor_id", Value: 1}, {Key: "sequence", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "target_id", Value: 1}, {Key: "sequence", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "action", Value: 1}, {Key: "sequence", Value: -1}},
+		},
+	})
+	return err
+}
+
+// Append links the record to the last one and stores it. When another writer stored the next
+// record first, the unique sequence index refuses the insert and the record is linked again. A
+// record whose id was already appended is returned as stored, so a retried delivery is not
+// recorded twice.
+func (ar *AuditRepository) Append(record domain.AuditRecord) (domain.AuditRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(ar.config.ContextTimeout)*time.Second)
+	defer cancel()
+	if record.ID.IsZero() {
+		record.ID = primitive.NewObjectID()
+	}
+	var stored domain.AuditRecord
+	err := ar.collection.FindOne(ctx, bson.M{"_id": record.ID}).Decode(&stored)
+	if err == nil {
+		return stored, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return domain.AuditRecord{}, err
+	}
+	record.Created_At = infrastructure.AuditTimestamp(record.Created_At)
+	salt, err := infrastructure.NewAuditSalt()
+	if err != nil {
+		return domain.AuditRecord{}, err
+	}
+	record.ClientSalt = salt
+	record.ClientDigest = infrastructure.AuditClientDigest(salt, record.IP, record.UserAgent)
+	for attempt := 0; attempt < auditAppendAttempts; attempt++ {
+		var last domain.AuditRecord
+		err = ar.collection.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.D{{Key: "sequence", Value: -1}})).Decode(&last)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return domain.AuditRecord{}, err
+		}
+		record.Sequence = last.Sequence + 1
+		record.PrevHash = last.Hash
+		record.Hash = infrastructure.HashAuditRecord(record)
+		_, err = ar.collection.InsertOne(ctx, record)
+		if !mongo.IsDuplicateKeyError(err) {
+			break
+		}
+	}
+	if err != nil {
+		return domain.AuditRecord{}, err
+	}
+	return record, nil
+}
+
+func auditQuery(filter domain.AuditFilter) bson.M {
+	query := bson.M{}
+	for field, value := range map[string]string{
+		"category":    filter.Category,
+		"action":      filter.Action,
+		"actor_id":    filter.ActorID,
+		"target_type": filter.TargetType,
+		"target_id":   filter.TargetID,
+		"request_id":  filter.RequestID,
+	} {
+		if value != "" {
+			query[field] = value
+		}
+	}
+	created := bson.M{}
+	if !filter.From.IsZero() {
+		created["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		created["$lt"] = filter.To
+	}
+	if len(created) > 0 {
+		query["created_at"] = created
+	}
+	return query
+}
+
+// Find lists matching records, newest first.
+func (ar *AuditRepository) Find(filter domain.AuditFilter, pageNo, pageSize int64) ([]domain.AuditRecord, error) {
+	records := []domain.AuditRecord{}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(ar.config.ContextTimeout)*time.Second)
+	defer cancel()
+	options := utils.PaginationByPage(pageNo, pageSize)
+	options.SetSort(bson.D{{Key: "sequence", Value: -1}})
+	cursor, err := ar.collection.Find(ctx, auditQuery(filter), options)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Each calls fn with every matching record in sequence order, stopping at the first error. Exports
+// and chain checks walk the whole log, so they are not bound by the request timeout.
+func (ar *AuditRepository) Each(filter domain.AuditFilter, fn func(record domain.AuditRecord) error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cursor, err := ar.collection.Find(ctx, auditQuery(filter), options.Find().SetSort(bson.D{{Key: "sequence", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var record domain.AuditRecord
+		if err := cursor.Decode(&record); err != nil {
+			return err
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// EraseClientDetails removes the IP, user agent and digest salt of the records of the user's own
+// actions, including failed logins and lockouts of their account. Records of admins acting on the
+// user keep the admin's client details.
+func (ar *AuditRepository) EraseClientDetails(user_id string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(ar.config.ContextTimeout)*time.Second)
+	defer cancel()
+	filter := bson.M{"$or": []bson.M{
+		{"actor_id": user_id},
+		{"target_type": "user", "target_id": user_id, "actor_id": bson.M{"$exists": false}},
+	}}
+	result, err := ar.collection.UpdateMany(ctx, filter, bson.M{"$unset": bson.M{"ip": "", "user_agent": "", "client_salt": ""}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
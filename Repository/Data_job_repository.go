@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	domain "loan-tracker/Domain"
+	infrastructure "loan-tracker/Infrastructure"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type DataJobRepository struct {
+	collection *mongo.Collection
+	config     *infrastructure.Config
+}
+
+func NewDataJobRepository(collection *mongo.Collection, config *infrastructure.Config) *DataJobRepository {
+	return &DataJobRepository{
+		collection: collection,
+		config:     config,
+	}
+}
+
+func (jr *DataJobRepository) CreateJob(job domain.DataJob) (domain.DataJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(jr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	job.ID = primitive.NewObjectID()
+	_, err := jr.collection.InsertOne(ctx, job)
+	if err != nil {
+		return domain.DataJob{}, err
+	}
+	return job, nil
+}
+
+func (jr *DataJobRepository) FindJobByID(id string) (domain.DataJob, error) {
+	var job domain.DataJob
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(jr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return job, err
+	}
+	err = jr.collection.FindOne(ctx, bson.M{"_id": objectId}).Decode(&job)
+	if err != nil {
+		return job, err
+	}
+	return job, nil
+}
+
+func (jr *DataJobRepository) FindJobsByUserID(user_id string, job_type string) ([]domain.DataJob, error) {
+	jobs := []domain.DataJob{}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(jr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	objectId, err := primitive.ObjectIDFromHex(user_id)
+	if err != nil {
+		return nil, err
+	}
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := jr.collection.Find(ctx, bson.M{"user_id": objectId, "type": job_type}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// FindActiveJob returns the user's pending or running job of the given type, if any.
+func (jr *DataJobRepository) FindActiveJob(user_id string, job_type string) (domain.DataJob, error) {
+	var job domain.DataJob
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(jr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	objectId, err := primitive.ObjectIDFromHex(user_id)
+	if err != nil {
+		return job, err
+	}
+	filter := bson.M{
+		"user_id": objectId,
+		"type":    job_type,
+		"status":  bson.M{"$in": []string{domain.DataJobPending, domain.DataJobRunning}},
+	}
+	err = jr.collection.FindOne(ctx, filter).Decode(&job)
+	if err != nil {
+		return job, err
+	}
+	return job, nil
+}
+
+// ClaimNextJob atomically marks the oldest pending job as running and returns it, so several
+// workers can poll the same collection. Running jobs started before stale_before are assumed to
+// belong to a worker that died and are claimed again.
+func (jr *DataJobRepository) ClaimNextJob(stale_before time.Time) (domain.DataJob, error) {
+	var job domain.DataJob
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(jr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	filter := bson.M{"$or": []bson.M{
+		{"status": domain.DataJobPending},
+		{"status": domain.DataJobRunning, "started_at": bson.M{"$lt": stale_before}},
+	}}
+	update := bson.M{"$set": bson.M{"status": domain.DataJobRunning, "started_at": time.Now()}}
+	findOptions := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "created_at", Value: 1}}).
+		SetReturnDocument(options.After)
+	err := jr.collection.FindOneAndUpdate(ctx, filter, update, findOptions).Decode(&job)
+	if err != nil {
+		return job, err
+	}
+	return job, nil
+}
+
+func (jr *DataJobRepository) UpdateJob(job domain.DataJob) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(jr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	_, err := jr.collection.UpdateOne(ctx, bson.M{"_id": job.ID}, bson.M{"$set": job})
+	return err
+}
+
+// FindExpiredExports returns completed exports whose download window has closed.
+func (jr *DataJobRepository) FindExpiredExports(now time.Time) ([]domain.DataJob, error) {
+	jobs := []domain.DataJob{}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(jr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	filter := bson.M{
+		"type":       domain.DataJobExport,
+		"status":     domain.DataJobCompleted,
+		"expires_at": bson.M{"$lt": now},
+	}
+	cursor, err := jr.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
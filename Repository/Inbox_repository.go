@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	domain "loan-tracker/Domain"
+	infrastructure "loan-tracker/Infrastructure"
+	utils "loan-tracker/Utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const inboxTTLIndex = "read_at_ttl"
+
+type InboxRepository struct {
+	collection *mongo.Collection
+	config     *infrastructure.Config
+}
+
+func NewInboxRepository(collection *mongo.Collection, config *infrastructure.Config) *InboxRepository {
+	return &InboxRepository{
+		collection: collection,
+		config:     config,
+	}
+}
+
+// EnsureIndexes creates the index the inbox is listed by and the TTL index that removes
+// notifications INBOX_READ_TTL_DAYS after they were read. Unread notifications have no read_at
+// and never expire. A changed TTL is applied to the existing index.
+func (ir *InboxRepository) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(ir.config.ContextTimeout)*time.Second)
+	defer cancel()
+	_, err := ir.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+	ttl := int32(ir.config.InboxReadTTLDays * 24 * 60 * 60)
+	_, err = ir.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "read_at", Value: 1}},
+		Options: options.Index().SetName(inboxTTLIndex).SetExpireAfterSeconds(ttl),
+	})
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) && commandErr.Code == 85 {
+		return ir.collection.Database().RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: ir.collection.Name()},
+			{Key: "index", Value: bson.M{"name": inboxTTLIndex, "expireAfterSeconds": ttl}},
+		}).Err()
+	}
+	return err
+}
+
+// Create stores a notification as part of the unit of work carried by ctx.
+func (ir *InboxRepository) Create(ctx context.Context, notification domain.InboxNotification) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(ir.config.ContextTimeout)*time.Second)
+	defer cancel()
+	if notification.ID.IsZero() {
+		notification.ID = primitive.NewObjectID()
+	}
+	_, err := ir.collection.InsertOne(ctx, notification)
+	return err
+}
+
+func (ir *InboxRepository) FindByUser(user_id string, unread_only bool, pageNo, pageSize int64) ([]domain.InboxNotification, error) {
+	notifications := []domain.InboxNotification{}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(ir.config.ContextTimeout)*time.Second)
+	defer cancel()
+	objectId, err := primitive.ObjectIDFromHex(user_id)
+	if err != nil {
+		return nil, err
+	}
+	filter := bson.M{"user_id": objectId}
+	if unread_only {
+		filter["read_at"] = nil
+	}
+	findOptions := utils.PaginationByPage(pageNo, pageSize)
+	findOptions.SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := ir.collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, &notifications); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+func (ir *InboxRepository) CountUnread(user_id string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(ir.config.ContextTimeout)*time.Second)
+	defer cancel()
+	objectId, err := primitive.ObjectIDFromHex(user_id)
+	if err != nil {
+		return 0, err
+	}
+	return ir.collection.CountDocuments(ctx, bson.M{"user_id": objectId, "read_at": nil})
+}
+
+// MarkRead marks one of the user's notifications as read. Marking it again keeps the first read time.
+func (ir *InboxRepository) MarkRead(id string, user_id string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(ir.config.ContextTimeout)*time.Second)
+	defer cancel()
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	userId, err := primitive.ObjectIDFromHex(user_id)
+	if err != nil {
+		return err
+	}
+	filter := bson.M{"_id": objectId, "user_id": userId}
+	result, err := ir.collection.UpdateOne(ctx, bson.M{"_id": objectId, "user_id": userId, "read_at": nil}, bson.M{"$set": bson.M{"read_at": at}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		count, err := ir.collection.CountDocuments(ctx, filter)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return mongo.ErrNoDocuments
+		}
+	}
+	return nil
+}
+
+func (ir *InboxRepository) MarkAllRead(user_id string, at time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(ir.config.ContextTimeout)*time.Second)
+	defer cancel()
+	objectId, err := primitive.ObjectIDFromHex(user_id)
+	if err != nil {
+		return 0, err
+	}
+	result, err := ir.collection.UpdateMany(ctx, bson.M{"user_id": objectId, "read_at": nil}, bson.M{"$set": bson.M{"read_at": at}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (ir *InboxRepository) DeleteByUser(user_id string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(ir.config.ContextTimeout)*time.Second)
+	defer cancel()
+	objectId, err := primitive.ObjectIDFromHex(user_id)
+	if err != nil {
+		return 0, err
+	}
+	result, err := ir.collection.DeleteMany(ctx, bson.M{"user_id": objectId})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
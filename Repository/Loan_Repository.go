@@ -26,9 +26,30 @@ func NewLoanRepository(collection *mongo.Collection, config *infrastructure.Conf
 }
 
 
+// EnsureIndexes creates the unique index on the reference numbers borrowers pay with. Loans created
+// before reference numbers existed have none and are left out of it.
+func (lr *LoanRepository) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(lr.config.ContextTimeout) * time.Second)
+	defer cancel()
+	_, err := lr.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "reference_number", Value: 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"reference_number": bson.M{"$type": "string"}}),
+	})
+	return err
+}
+
+
 func (lr *LoanRepository) CreateLoan(loan domain.Loan) error {
-	context, _ := context.WithTimeout(context.Background(), time.Duration(lr.config.ContextTimeout) * time.Second)
-	loan.ID = primitive.NewObjectID()
+	return lr.CreateLoanWithContext(context.Background(), loan)
+}
+
+// CreateLoanWithContext inserts the loan as part of the unit of work carried by ctx.
+func (lr *LoanRepository) CreateLoanWithContext(ctx context.Context, loan domain.Loan) error {
+	context, cancel := context.WithTimeout(ctx, time.Duration(lr.config.ContextTimeout) * time.Second)
+	defer cancel()
+	if loan.ID.IsZero() {
+		loan.ID = primitive.NewObjectID()
+	}
 	_, err := lr.collection.InsertOne(context, loan)
 	if err != nil {
 		return err
@@ -39,7 +60,8 @@ func (lr *LoanRepository) CreateLoan(loan domain.Loan) error {
 
 func (lr *LoanRepository) FindLoanByID(id string)(domain.Loan , error){
 	var Loan domain.Loan
-	context, _ := context.WithTimeout(context.Background(), time.Duration(lr.config.ContextTimeout) * time.Second)
+	context, cancel := context.WithTimeout(context.Background(), time.Duration(lr.config.ContextTimeout) * time.Second)
+	defer cancel()
 	objectId, _ := primitive.ObjectIDFromHex(id)
 	filter := bson.M{"_id": objectId}
 	err := lr.collection.FindOne(context, filter).Decode(&Loan)
@@ -49,6 +71,28 @@ func (lr *LoanRepository) FindLoanByID(id string)(domain.Loan , error){
 	return Loan, nil
 }
 
+// FindLoansByReference returns the loans with the reference number, or with the reference as their
+// id for loans that have no reference number.
+func (lr *LoanRepository) FindLoansByReference(reference string) ([]domain.Loan, error){
+	loans := []domain.Loan{}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(lr.config.ContextTimeout) * time.Second)
+	defer cancel()
+	filter := bson.M{"reference_number": reference}
+	if objectId, err := primitive.ObjectIDFromHex(strings.ToLower(reference)); err == nil {
+		filter = bson.M{"$or": []bson.M{{"reference_number": reference}, {"_id": objectId}}}
+	}
+	cursor, err := lr.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	err = cursor.All(ctx, &loans)
+	if err != nil {
+		return nil, err
+	}
+	return loans, nil
+}
+
 func (lr *LoanRepository) GetAllLoans(status string, order string) ([]domain.Loan, error){
 	var loans []domain.Loan
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -77,4 +121,115 @@ func (lr *LoanRepository) GetAllLoans(status string, order string) ([]domain.Loa
 		loans = append(loans, loan)
 	}
 	return loans, nil
-}
\ No newline at end of file
+}
+
+
+func (lr *LoanRepository) FindLoansByUserID(user_id string) ([]domain.Loan, error){
+	loans := []domain.Loan{}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(lr.config.ContextTimeout) * time.Second)
+	defer cancel()
+	objectId, err := primitive.ObjectIDFromHex(user_id)
+	if err != nil {
+		return nil, err
+	}
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := lr.collection.Find(ctx, bson.M{"user_id": objectId}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	err = cursor.All(ctx, &loans)
+	if err != nil {
+		return nil, err
+	}
+	return loans, nil
+}
+
+
+// CountOpenLoans counts the user's loans that are neither rejected nor repaid: pending and approved
+// applications as well as disbursed loans, whatever their balance.
+func (lr *LoanRepository) CountOpenLoans(user_id string) (int64, error){
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(lr.config.ContextTimeout) * time.Second)
+	defer cancel()
+	objectId, err := primitive.ObjectIDFromHex(user_id)
+	if err != nil {
+		return 0, err
+	}
+	return lr.collection.CountDocuments(ctx, bson.M{
+		"user_id": objectId,
+		"loan_status": bson.M{"$nin": []string{domain.LoanRejected, domain.LoanRepaid}},
+	})
+}
+
+
+// UpdateLoanWithContext saves the loan as part of the unit of work carried by ctx. It returns
+// domain.ErrLoanChanged when the loan's status is no longer previous_status, so that two admins
+// cannot both decide or disburse the same loan.
+func (lr *LoanRepository) UpdateLoanWithContext(ctx context.Context, loan domain.Loan, previous_status string) error{
+	context, cancel := context.WithTimeout(ctx, time.Duration(lr.config.ContextTimeout) * time.Second)
+	defer cancel()
+	result, err := lr.collection.UpdateOne(context, bson.M{"_id": loan.ID, "loan_status": previous_status}, bson.M{"$set": loan})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrLoanChanged
+	}
+	return nil
+}
+
+
+// ApplyPaymentWithContext saves the installments, balance and status of a loan a payment was applied
+// to, as part of the unit of work carried by ctx. It returns domain.ErrLoanChanged when the balance
+// is no longer previous_balance or the payment was already applied, so that concurrent payments
+// are never lost or applied twice.
+func (lr *LoanRepository) ApplyPaymentWithContext(ctx context.Context, loan domain.Loan, payment_id primitive.ObjectID, previous_balance float64) error{
+	context, cancel := context.WithTimeout(ctx, time.Duration(lr.config.ContextTimeout) * time.Second)
+	defer cancel()
+	filter := bson.M{
+		"_id": loan.ID,
+		"outstanding_balance": previous_balance,
+		"payment_ids": bson.M{"$ne": payment_id},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"installments": loan.Installments,
+			"outstanding_balance": loan.OutstandingBalance,
+			"loan_status": loan.LoanStatus,
+		},
+		"$push": bson.M{"payment_ids": payment_id},
+	}
+	result, err := lr.collection.UpdateOne(context, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrLoanChanged
+	}
+	return nil
+}
+
+
+// FindLoansWithUnpaidInstallments returns the disbursed loans with an unpaid installment due between due_from and due_to.
+func (lr *LoanRepository) FindLoansWithUnpaidInstallments(due_from time.Time, due_to time.Time) ([]domain.Loan, error){
+	loans := []domain.Loan{}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(lr.config.ContextTimeout) * time.Second)
+	defer cancel()
+	filter := bson.M{
+		"loan_status": domain.LoanDisbursed,
+		"installments": bson.M{"$elemMatch": bson.M{
+			"paid": false,
+			"due_date": bson.M{"$gte": due_from, "$lte": due_to},
+		}},
+	}
+	cursor, err := lr.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	err = cursor.All(ctx, &loans)
+	if err != nil {
+		return nil, err
+	}
+	return loans, nil
+}
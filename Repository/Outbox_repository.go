@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	domain "loan-tracker/Domain"
+	infrastructure "loan-tracker/Infrastructure"
+	utils "loan-tracker/Utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type OutboxRepository struct {
+	collection *mongo.Collection
+	config     *infrastructure.Config
+}
+
+func NewOutboxRepository(collection *mongo.Collection, config *infrastructure.Config) *OutboxRepository {
+	return &OutboxRepository{
+		collection: collection,
+		config:     config,
+	}
+}
+
+// Enqueue stores an email for delivery. Pass the context of a unit of work to write it in the
+// same transaction as the change it reports.
+func (or *OutboxRepository) Enqueue(ctx context.Context, message domain.EmailMessage) error {
+	return or.EnqueueMessage(ctx, domain.OutboxMessage{Channel: domain.ChannelEmail, Message: message})
+}
+
+// EnqueueMessage stores a message for delivery on its channel, as a new pending message.
+func (or *OutboxRepository) EnqueueMessage(ctx context.Context, message domain.OutboxMessage) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(or.config.ContextTimeout)*time.Second)
+	defer cancel()
+	now := time.Now()
+	message.ID = primitive.NewObjectID()
+	message.Status = domain.OutboxPending
+	message.Attempts = 0
+	message.NextAttemptAt = now
+	message.Created_At = now
+	message.Log = []domain.DeliveryAttempt{}
+	_, err := or.collection.InsertOne(ctx, message)
+	return err
+}
+
+func (or *OutboxRepository) FindByID(id string) (domain.OutboxMessage, error) {
+	var message domain.OutboxMessage
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(or.config.ContextTimeout)*time.Second)
+	defer cancel()
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return message, err
+	}
+	err = or.collection.FindOne(ctx, bson.M{"_id": objectId}).Decode(&message)
+	if err != nil {
+		return message, err
+	}
+	return message, nil
+}
+
+// ClaimDue leases the oldest message that is due, so concurrent workers never send it twice. A
+// message whose lease ran out while sending is assumed abandoned and claimed again.
+func (or *OutboxRepository) ClaimDue(now time.Time, lease time.Duration) (domain.OutboxMessage, error) {
+	var message domain.OutboxMessage
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(or.config.ContextTimeout)*time.Second)
+	defer cancel()
+	filter := bson.M{"$or": []bson.M{
+		{"status": domain.OutboxPending, "next_attempt_at": bson.M{"$lte": now}},
+		{"status": domain.OutboxSending, "locked_until": bson.M{"$lt": now}},
+	}}
+	update := bson.M{"$set": bson.M{"status": domain.OutboxSending, "locked_until": now.Add(lease)}}
+	findOptions := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+		SetReturnDocument(options.After)
+	err := or.collection.FindOneAndUpdate(ctx, filter, update, findOptions).Decode(&message)
+	if err != nil {
+		return message, err
+	}
+	return message, nil
+}
+
+// leased matches the message only while the lease it was claimed with holds. Once the lease ran out
+// and another worker claimed the message again, locked_until differs and nothing matches.
+func leased(message domain.OutboxMessage) bson.M {
+	return bson.M{"_id": message.ID, "status": domain.OutboxSending, "locked_until": message.LockedUntil}
+}
+
+// MarkSent stores the success of the claimed message. It returns domain.ErrOutboxLeaseLost when the
+// lease was lost.
+func (or *OutboxRepository) MarkSent(message domain.OutboxMessage, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(or.config.ContextTimeout)*time.Second)
+	defer cancel()
+	update := bson.M{
+		"$set":  bson.M{"status": domain.OutboxSent, "sent_at": at, "last_error": ""},
+		"$push": bson.M{"log": domain.DeliveryAttempt{At: at}},
+	}
+	result, err := or.collection.UpdateOne(ctx, leased(message), update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrOutboxLeaseLost
+	}
+	return nil
+}
+
+// MarkFailed stores the outcome of a failed attempt of the claimed message: its status, attempt
+// count, error and next attempt time. The attempt is added to the message's delivery log. It
+// returns domain.ErrOutboxLeaseLost when the lease was lost.
+func (or *OutboxRepository) MarkFailed(message domain.OutboxMessage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(or.config.ContextTimeout)*time.Second)
+	defer cancel()
+	update := bson.M{
+		"$set": bson.M{
+			"status":          message.Status,
+			"attempts":        message.Attempts,
+			"last_error":      message.LastError,
+			"next_attempt_at": message.NextAttemptAt,
+		},
+		"$push": bson.M{"log": domain.DeliveryAttempt{At: time.Now(), Error: message.LastError}},
+	}
+	result, err := or.collection.UpdateOne(ctx, leased(message), update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrOutboxLeaseLost
+	}
+	return nil
+}
+
+func (or *OutboxRepository) FindByStatus(status string, pageNo, pageSize int64) ([]domain.OutboxMessage, error) {
+	messages := []domain.OutboxMessage{}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(or.config.ContextTimeout)*time.Second)
+	defer cancel()
+	findOptions := utils.PaginationByPage(pageNo, pageSize)
+	findOptions.SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := or.collection.Find(ctx, bson.M{"status": status}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// FindByWebhookEndpoint lists the deliveries to a webhook endpoint, newest first. An empty status
+// lists them all.
+func (or *OutboxRepository) FindByWebhookEndpoint(endpoint_id string, status string, pageNo, pageSize int64) ([]domain.OutboxMessage, error) {
+	messages := []domain.OutboxMessage{}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(or.config.ContextTimeout)*time.Second)
+	defer cancel()
+	objectId, err := primitive.ObjectIDFromHex(endpoint_id)
+	if err != nil {
+		return nil, err
+	}
+	filter := bson.M{"channel": domain.ChannelWebhook, "webhook.endpoint_id": objectId}
+	if status != "" {
+		filter["status"] = status
+	}
+	findOptions := utils.PaginationByPage(pageNo, pageSize)
+	findOptions.SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := or.collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// Retry puts a dead message back in the queue with a fresh attempt count.
+func (or *OutboxRepository) Retry(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(or.config.ContextTimeout)*time.Second)
+	defer cancel()
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	update := bson.M{"$set": bson.M{"status": domain.OutboxPending, "attempts": 0, "next_attempt_at": time.Now()}}
+	result, err := or.collection.UpdateOne(ctx, bson.M{"_id": objectId, "status": domain.OutboxDead}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("no dead message with this id")
+	}
+	return nil
+}
+
+// DeleteForRecipient deletes the emails, text messages and push notifications of the user, or sent
+// to any of the addresses, whatever their status. Webhook deliveries only refer to ids, and audit
+// records belong to the audit log; both are kept.
+func (or *OutboxRepository) DeleteForRecipient(user_id string, addresses []string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(or.config.ContextTimeout)*time.Second)
+	defer cancel()
+	objectId, err := primitive.ObjectIDFromHex(user_id)
+	if err != nil {
+		return 0, err
+	}
+	filter := bson.M{
+		"channel": bson.M{"$nin": []string{domain.ChannelWebhook, domain.ChannelAudit}},
+		"$or": []bson.M{
+			{"user_id": objectId},
+			{"message.to": bson.M{"$in": addresses}},
+			{"sms.to": bson.M{"$in": addresses}},
+		},
+	}
+	result, err := or.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	domain "loan-tracker/Domain"
+	infrastructure "loan-tracker/Infrastructure"
+	utils "loan-tracker/Utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type PaymentRepository struct {
+	collection *mongo.Collection
+	config     *infrastructure.Config
+}
+
+func NewPaymentRepository(collection *mongo.Collection, config *infrastructure.Config) *PaymentRepository {
+	return &PaymentRepository{
+		collection: collection,
+		config:     config,
+	}
+}
+
+// EnsureIndexes creates the unique index that deduplicates payments by provider transaction id and
+// the index the suspense queue is listed by.
+func (pr *PaymentRepository) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(pr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	_, err := pr.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "provider", Value: 1}, {Key: "transaction_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "received_at", Value: -1}},
+		},
+	})
+	return err
+}
+
+// Create stores a received payment. It returns domain.ErrDuplicatePayment when the provider's
+// transaction was already stored.
+func (pr *PaymentRepository) Create(payment domain.Payment) (domain.Payment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(pr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	payment.ID = primitive.NewObjectID()
+	_, err := pr.collection.InsertOne(ctx, payment)
+	if mongo.IsDuplicateKeyError(err) {
+		return domain.Payment{}, domain.ErrDuplicatePayment
+	}
+	if err != nil {
+		return domain.Payment{}, err
+	}
+	return payment, nil
+}
+
+func (pr *PaymentRepository) FindByID(id string) (domain.Payment, error) {
+	var payment domain.Payment
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(pr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return payment, err
+	}
+	err = pr.collection.FindOne(ctx, bson.M{"_id": objectId}).Decode(&payment)
+	return payment, err
+}
+
+func (pr *PaymentRepository) FindByTransaction(provider string, transaction_id string) (domain.Payment, error) {
+	var payment domain.Payment
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(pr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	err := pr.collection.FindOne(ctx, bson.M{"provider": provider, "transaction_id": transaction_id}).Decode(&payment)
+	return payment, err
+}
+
+func (pr *PaymentRepository) FindByStatus(status string, pageNo, pageSize int64) ([]domain.Payment, error) {
+	payments := []domain.Payment{}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(pr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	findOptions := utils.PaginationByPage(pageNo, pageSize)
+	findOptions.SetSort(bson.D{{Key: "received_at", Value: -1}})
+	cursor, err := pr.collection.Find(ctx, bson.M{"status": status}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, &payments); err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+// FindByLoanIDs returns the payments posted or assigned to any of the loans, oldest first.
+func (pr *PaymentRepository) FindByLoanIDs(loan_ids []primitive.ObjectID) ([]domain.Payment, error) {
+	payments := []domain.Payment{}
+	if len(loan_ids) == 0 {
+		return payments, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(pr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	findOptions := options.Find().SetSort(bson.D{{Key: "paid_at", Value: 1}})
+	cursor, err := pr.collection.Find(ctx, bson.M{"loan_id": bson.M{"$in": loan_ids}}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, &payments); err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+// UpdateWithContext saves the payment as part of the unit of work carried by ctx.
+func (pr *PaymentRepository) UpdateWithContext(ctx context.Context, payment domain.Payment) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(pr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	_, err := pr.collection.ReplaceOne(ctx, bson.M{"_id": payment.ID}, payment)
+	return err
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	domain "loan-tracker/Domain"
+	infrastructure "loan-tracker/Infrastructure"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ReminderRepository struct {
+	collection *mongo.Collection
+	config     *infrastructure.Config
+}
+
+func NewReminderRepository(collection *mongo.Collection, config *infrastructure.Config) *ReminderRepository {
+	return &ReminderRepository{
+		collection: collection,
+		config:     config,
+	}
+}
+
+// EnsureIndexes creates the unique index that lets only one replica record each reminder.
+func (rr *ReminderRepository) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(rr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	_, err := rr.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "loan_id", Value: 1}, {Key: "installment", Value: 1}, {Key: "stage", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// Record stores a sent reminder. It returns domain.ErrReminderAlreadySent when the stage of that
+// installment was already recorded.
+func (rr *ReminderRepository) Record(ctx context.Context, reminder domain.ReminderLog) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(rr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	if reminder.ID.IsZero() {
+		reminder.ID = primitive.NewObjectID()
+	}
+	_, err := rr.collection.InsertOne(ctx, reminder)
+	if mongo.IsDuplicateKeyError(err) {
+		return domain.ErrReminderAlreadySent
+	}
+	return err
+}
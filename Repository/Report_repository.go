@@ -0,0 +1,274 @@
+package repository
+
+import (
+	"context"
+	domain "loan-tracker/Domain"
+	infrastructure "loan-tracker/Infrastructure"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// arrearsDays are the days past due the portfolio report measures arrears at.
+var arrearsDays = []int{1, 30, 90}
+
+// ReportRepository runs the reporting aggregations over the loans and payments collections. It
+// only reads them.
+type ReportRepository struct {
+	loans    *mongo.Collection
+	payments *mongo.Collection
+	config   *infrastructure.Config
+}
+
+func NewReportRepository(loans *mongo.Collection, payments *mongo.Collection, config *infrastructure.Config) *ReportRepository {
+	return &ReportRepository{
+		loans:    loans,
+		payments: payments,
+		config:   config,
+	}
+}
+
+// EnsureIndexes creates the indexes on the dates the reports filter by.
+func (rr *ReportRepository) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(rr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	_, err := rr.loans.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "created_at", Value: 1}}},
+		{Keys: bson.D{{Key: "decided_at", Value: 1}}},
+		{Keys: bson.D{{Key: "disbursed_at", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = rr.payments.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "posted_at", Value: 1}},
+	})
+	return err
+}
+
+// dateRange matches the dates of the filter's range. Dates that were never set are stored as the
+// zero time and never match.
+func dateRange(filter domain.ReportFilter) bson.M {
+	match := bson.M{"$gt": time.Time{}}
+	if !filter.From.IsZero() {
+		match["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		match["$lt"] = filter.To
+	}
+	return match
+}
+
+// groupKey is the _id a report groups by. prefix is the path of the loan fields and date the field
+// that places a row in a month.
+func groupKey(group_by string, prefix string, date string) interface{} {
+	switch group_by {
+	case domain.ReportGroupProduct:
+		return "$" + prefix + "product"
+	case domain.ReportGroupStatus:
+		return "$" + prefix + "loan_status"
+	case domain.ReportGroupMonth:
+		return bson.M{"$dateToString": bson.M{"format": "%Y-%m", "date": "$" + date, "timezone": "UTC"}}
+	}
+	return domain.ReportTotal
+}
+
+func productMatch(filter domain.ReportFilter, prefix string) bson.M {
+	if filter.Product == "" {
+		return bson.M{}
+	}
+	return bson.M{prefix + "product": filter.Product}
+}
+
+func (rr *ReportRepository) aggregate(collection *mongo.Collection, pipeline mongo.Pipeline, result interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(rr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	return cursor.All(ctx, result)
+}
+
+// LoanActivity counts applications, decisions and disbursements in one pass over the loans, each
+// dated and grouped by its own date.
+func (rr *ReportRepository) LoanActivity(filter domain.ReportFilter) ([]domain.LoanActivityRow, error) {
+	rejected := bson.M{"$eq": bson.A{"$loan_status", domain.LoanRejected}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: productMatch(filter, "")}},
+		{{Key: "$facet", Value: bson.M{
+			"applied": bson.A{
+				bson.M{"$match": bson.M{"created_at": dateRange(filter)}},
+				bson.M{"$group": bson.M{
+					"_id":     groupKey(filter.GroupBy, "", "created_at"),
+					"applied": bson.M{"$sum": 1},
+				}},
+			},
+			"decided": bson.A{
+				bson.M{"$match": bson.M{"decided_at": dateRange(filter)}},
+				bson.M{"$group": bson.M{
+					"_id":      groupKey(filter.GroupBy, "", "decided_at"),
+					"approved": bson.M{"$sum": bson.M{"$cond": bson.A{rejected, 0, 1}}},
+					"rejected": bson.M{"$sum": bson.M{"$cond": bson.A{rejected, 1, 0}}},
+					"avg_decision_hours": bson.M{"$avg": bson.M{"$divide": bson.A{
+						bson.M{"$subtract": bson.A{"$decided_at", "$created_at"}},
+						float64(time.Hour / time.Millisecond),
+					}}},
+				}},
+			},
+			"disbursed": bson.A{
+				bson.M{"$match": bson.M{"disbursed_at": dateRange(filter)}},
+				bson.M{"$group": bson.M{
+					"_id":              groupKey(filter.GroupBy, "", "disbursed_at"),
+					"disbursed":        bson.M{"$sum": 1},
+					"disbursed_volume": bson.M{"$sum": "$amount"},
+				}},
+			},
+		}}},
+	}
+	var facets []struct {
+		Applied   []domain.LoanActivityRow `bson:"applied"`
+		Decided   []domain.LoanActivityRow `bson:"decided"`
+		Disbursed []domain.LoanActivityRow `bson:"disbursed"`
+	}
+	if err := rr.aggregate(rr.loans, pipeline, &facets); err != nil {
+		return nil, err
+	}
+	rows := map[string]*domain.LoanActivityRow{}
+	row := func(group string) *domain.LoanActivityRow {
+		if rows[group] == nil {
+			rows[group] = &domain.LoanActivityRow{Group: group}
+		}
+		return rows[group]
+	}
+	for _, facet := range facets {
+		for _, applied := range facet.Applied {
+			row(applied.Group).Applied = applied.Applied
+		}
+		for _, decided := range facet.Decided {
+			r := row(decided.Group)
+			r.Approved = decided.Approved
+			r.Rejected = decided.Rejected
+			r.AvgDecisionHours = decided.AvgDecisionHours
+		}
+		for _, disbursed := range facet.Disbursed {
+			r := row(disbursed.Group)
+			r.Disbursed = disbursed.Disbursed
+			r.DisbursedVolume = disbursed.DisbursedVolume
+		}
+	}
+	result := []domain.LoanActivityRow{}
+	for _, r := range rows {
+		result = append(result, *r)
+	}
+	slices.SortFunc(result, func(a, b domain.LoanActivityRow) int {
+		return strings.Compare(a.Group, b.Group)
+	})
+	return result, nil
+}
+
+// Portfolio sums the outstanding principal of the loans disbursed in the range, and the part of it
+// held by loans whose oldest unpaid installment is more than 1, 30 and 90 days past due at as_of.
+func (rr *ReportRepository) Portfolio(filter domain.ReportFilter, as_of time.Time) ([]domain.PortfolioRow, error) {
+	match := productMatch(filter, "")
+	match["disbursed_at"] = dateRange(filter)
+	group := bson.M{
+		"_id":                   groupKey(filter.GroupBy, "", "disbursed_at"),
+		"loans":                 bson.M{"$sum": 1},
+		"active_loans":          bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{"$outstanding_balance", 0}}, 1, 0}}},
+		"outstanding_principal": bson.M{"$sum": "$outstanding_balance"},
+	}
+	for _, days := range arrearsDays {
+		late := bson.M{"$and": bson.A{
+			bson.M{"$ne": bson.A{"$oldest_unpaid", nil}},
+			bson.M{"$lt": bson.A{"$oldest_unpaid", as_of.AddDate(0, 0, -days)}},
+		}}
+		group["arrears_"+strconv.Itoa(days)] = bson.M{"$sum": bson.M{"$cond": bson.A{late, "$outstanding_balance", 0}}}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$addFields", Value: bson.M{
+			"oldest_unpaid": bson.M{"$min": bson.M{"$map": bson.M{
+				"input": bson.M{"$filter": bson.M{
+					"input": bson.M{"$ifNull": bson.A{"$installments", bson.A{}}},
+					"cond":  bson.M{"$eq": bson.A{"$$this.paid", false}},
+				}},
+				"in": "$$this.due_date",
+			}}},
+		}}},
+		{{Key: "$group", Value: group}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+	}
+	rows := []domain.PortfolioRow{}
+	if err := rr.aggregate(rr.loans, pipeline, &rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// Collections sums the payments posted in the range, grouped by the loan they were posted to or by
+// the month they were posted in.
+func (rr *ReportRepository) Collections(filter domain.ReportFilter) ([]domain.CollectionsRow, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"status": domain.PaymentStatusPosted, "posted_at": dateRange(filter)}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         rr.loans.Name(),
+			"localField":   "loan_id",
+			"foreignField": "_id",
+			"as":           "loan",
+		}}},
+		{{Key: "$unwind", Value: "$loan"}},
+		{{Key: "$match", Value: productMatch(filter, "loan.")}},
+		{{Key: "$group", Value: bson.M{
+			"_id":      groupKey(filter.GroupBy, "loan.", "posted_at"),
+			"payments": bson.M{"$sum": 1},
+			"amount":   bson.M{"$sum": "$amount"},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+	}
+	rows := []domain.CollectionsRow{}
+	if err := rr.aggregate(rr.payments, pipeline, &rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// VintageLoans returns the loans disbursed in the range, oldest first, with the payments posted to
+// them in the order they were posted.
+func (rr *ReportRepository) VintageLoans(filter domain.ReportFilter) ([]domain.VintageLoan, error) {
+	match := productMatch(filter, "")
+	match["disbursed_at"] = dateRange(filter)
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$sort", Value: bson.D{{Key: "disbursed_at", Value: 1}}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from": rr.payments.Name(),
+			"let":  bson.M{"loan_id": "$_id"},
+			"pipeline": bson.A{
+				bson.M{"$match": bson.M{"$expr": bson.M{"$and": bson.A{
+					bson.M{"$eq": bson.A{"$loan_id", "$$loan_id"}},
+					bson.M{"$eq": bson.A{"$status", domain.PaymentStatusPosted}},
+				}}}},
+				bson.M{"$sort": bson.D{{Key: "posted_at", Value: 1}}},
+				bson.M{"$project": bson.M{"_id": 0, "amount": 1, "posted_at": 1}},
+			},
+			"as": "payments",
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"amount":       1,
+			"disbursed_at": 1,
+			"installments": 1,
+			"payments":     1,
+		}}},
+	}
+	loans := []domain.VintageLoan{}
+	if err := rr.aggregate(rr.loans, pipeline, &loans); err != nil {
+		return nil, err
+	}
+	return loans, nil
+}
@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	domain "loan-tracker/Domain"
+	infrastructure "loan-tracker/Infrastructure"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type SessionRepository struct {
+	collection *mongo.Collection
+	config     *infrastructure.Config
+}
+
+func NewSessionRepository(collection *mongo.Collection, config *infrastructure.Config) *SessionRepository {
+	return &SessionRepository{
+		collection: collection,
+		config:     config,
+	}
+}
+
+func (sr *SessionRepository) CreateSessionWithContext(ctx context.Context, session domain.Session) (domain.Session, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(sr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	if session.ID.IsZero() {
+		session.ID = primitive.NewObjectID()
+	}
+	_, err := sr.collection.InsertOne(ctx, session)
+	if err != nil {
+		return domain.Session{}, err
+	}
+	return session, nil
+}
+
+func (sr *SessionRepository) FindSessionByID(id string) (domain.Session, error) {
+	var session domain.Session
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(sr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return session, err
+	}
+	err = sr.collection.FindOne(ctx, bson.M{"_id": objectId}).Decode(&session)
+	if err != nil {
+		return session, err
+	}
+	return session, nil
+}
+
+func (sr *SessionRepository) FindSessionsByUserID(user_id string) ([]domain.Session, error) {
+	sessions := []domain.Session{}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(sr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	objectId, err := primitive.ObjectIDFromHex(user_id)
+	if err != nil {
+		return nil, err
+	}
+	filter := bson.M{
+		"user_id":    objectId,
+		"revoked":    false,
+		"expires_at": bson.M{"$gt": time.Now()},
+	}
+	findOptions := options.Find().SetSort(bson.D{{Key: "last_used_at", Value: -1}})
+	cursor, err := sr.collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (sr *SessionRepository) TouchSession(id string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(sr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = sr.collection.UpdateOne(ctx, bson.M{"_id": objectId}, bson.M{"$set": bson.M{"last_used_at": at}})
+	return err
+}
+
+func (sr *SessionRepository) RevokeSession(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(sr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	update := bson.M{"$set": bson.M{"revoked": true, "revoked_at": time.Now()}}
+	_, err = sr.collection.UpdateOne(ctx, bson.M{"_id": objectId, "revoked": false}, update)
+	return err
+}
+
+// RevokeUserSessions revokes every active session of the user except the one with the given ID.
+// Pass an empty except to revoke all of them.
+func (sr *SessionRepository) RevokeUserSessions(user_id string, except string) error {
+	return sr.RevokeUserSessionsWithContext(context.Background(), user_id, except)
+}
+
+func (sr *SessionRepository) RevokeUserSessionsWithContext(ctx context.Context, user_id string, except string) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(sr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	userId, err := primitive.ObjectIDFromHex(user_id)
+	if err != nil {
+		return err
+	}
+	filter := bson.M{"user_id": userId, "revoked": false}
+	if except != "" {
+		exceptId, err := primitive.ObjectIDFromHex(except)
+		if err != nil {
+			return err
+		}
+		filter["_id"] = bson.M{"$ne": exceptId}
+	}
+	update := bson.M{"$set": bson.M{"revoked": true, "revoked_at": time.Now()}}
+	_, err = sr.collection.UpdateMany(ctx, filter, update)
+	return err
+}
+
+func (sr *SessionRepository) MarkSessionTwoFactorVerified(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(sr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = sr.collection.UpdateOne(ctx, bson.M{"_id": objectId}, bson.M{"$set": bson.M{"two_factor_verified": true}})
+	return err
+}
+
+// FindSessionHistory returns every session of the user, including revoked and expired ones, newest first.
+func (sr *SessionRepository) FindSessionHistory(user_id string) ([]domain.Session, error) {
+	sessions := []domain.Session{}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(sr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	objectId, err := primitive.ObjectIDFromHex(user_id)
+	if err != nil {
+		return nil, err
+	}
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := sr.collection.Find(ctx, bson.M{"user_id": objectId}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// AnonymizeUserSessions revokes the user's sessions and removes the client details recorded on them.
+func (sr *SessionRepository) AnonymizeUserSessions(user_id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(sr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	objectId, err := primitive.ObjectIDFromHex(user_id)
+	if err != nil {
+		return err
+	}
+	update := bson.M{"$set": bson.M{"device": "", "ip": "", "user_agent": "", "revoked": true}}
+	_, err = sr.collection.UpdateMany(ctx, bson.M{"user_id": objectId}, update)
+	return err
+}
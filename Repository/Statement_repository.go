@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	domain "loan-tracker/Domain"
+	infrastructure "loan-tracker/Infrastructure"
+	utils "loan-tracker/Utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// StatementRepository stores the column mappings of the banks and the statement imports.
+type StatementRepository struct {
+	mappings *mongo.Collection
+	imports  *mongo.Collection
+	config   *infrastructure.Config
+}
+
+func NewStatementRepository(mappings *mongo.Collection, imports *mongo.Collection, config *infrastructure.Config) *StatementRepository {
+	return &StatementRepository{
+		mappings: mappings,
+		imports:  imports,
+		config:   config,
+	}
+}
+
+// EnsureIndexes creates the unique index that keeps one import per bank and file content.
+func (sr *StatementRepository) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(sr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	_, err := sr.imports.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "bank", Value: 1}, {Key: "file_hash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func (sr *StatementRepository) FindMappings() ([]domain.StatementMapping, error) {
+	mappings := []domain.StatementMapping{}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(sr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	cursor, err := sr.mappings.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, &mappings); err != nil {
+		return nil, err
+	}
+	return mappings, nil
+}
+
+func (sr *StatementRepository) FindMapping(bank string) (domain.StatementMapping, error) {
+	var mapping domain.StatementMapping
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(sr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	err := sr.mappings.FindOne(ctx, bson.M{"_id": bank}).Decode(&mapping)
+	return mapping, err
+}
+
+// SaveMapping creates or replaces the mapping of a bank.
+func (sr *StatementRepository) SaveMapping(mapping domain.StatementMapping) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(sr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	_, err := sr.mappings.ReplaceOne(ctx, bson.M{"_id": mapping.Bank}, mapping, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (sr *StatementRepository) DeleteMapping(bank string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(sr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	result, err := sr.mappings.DeleteOne(ctx, bson.M{"_id": bank})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// CreateImport stores a new import. Another upload of the same file for the same bank fails with a
+// duplicate key error.
+func (sr *StatementRepository) CreateImport(statement domain.StatementImport) (domain.StatementImport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(sr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	statement.ID = primitive.NewObjectID()
+	_, err := sr.imports.InsertOne(ctx, statement)
+	if err != nil {
+		return domain.StatementImport{}, err
+	}
+	return statement, nil
+}
+
+func (sr *StatementRepository) FindImportByHash(bank string, file_hash string) (domain.StatementImport, error) {
+	var statement domain.StatementImport
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(sr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	err := sr.imports.FindOne(ctx, bson.M{"bank": bank, "file_hash": file_hash}).Decode(&statement)
+	return statement, err
+}
+
+func (sr *StatementRepository) FindImportByID(id string) (domain.StatementImport, error) {
+	var statement domain.StatementImport
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(sr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return statement, err
+	}
+	err = sr.imports.FindOne(ctx, bson.M{"_id": objectId}).Decode(&statement)
+	return statement, err
+}
+
+// FindImports lists imports newest first, without their lines.
+func (sr *StatementRepository) FindImports(pageNo, pageSize int64) ([]domain.StatementImport, error) {
+	statements := []domain.StatementImport{}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(sr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	findOptions := utils.PaginationByPage(pageNo, pageSize)
+	findOptions.SetSort(bson.D{{Key: "created_at", Value: -1}}).SetProjection(bson.M{"lines": 0})
+	cursor, err := sr.imports.Find(ctx, bson.M{}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, &statements); err != nil {
+		return nil, err
+	}
+	return statements, nil
+}
+
+func (sr *StatementRepository) UpdateImport(statement domain.StatementImport) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(sr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	_, err := sr.imports.ReplaceOne(ctx, bson.M{"_id": statement.ID}, statement)
+	return err
+}
+
+// EraseLineNames clears the payer name of the statement lines matched to the loans.
+func (sr *StatementRepository) EraseLineNames(loan_ids []primitive.ObjectID) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(sr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	matched := bson.M{"$in": loan_ids}
+	updateOptions := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{bson.M{"line.loan_id": matched}},
+	})
+	result, err := sr.imports.UpdateMany(ctx, bson.M{"lines.loan_id": matched}, bson.M{"$set": bson.M{"lines.$[line].name": ""}}, updateOptions)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
@@ -4,11 +4,18 @@ import (
 	"context"
 	domain "loan-tracker/Domain"
 	infrastructure "loan-tracker/Infrastructure"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	emailIndex    = "email_unique"
+	userNameIndex = "user_name_unique"
 )
 
 type UserRepository struct {
@@ -23,10 +30,50 @@ func NewUserRepository(collection *mongo.Collection, config *infrastructure.Conf
 	}
 }
 
+// notDeleted restricts a user filter to accounts that were not soft-deleted. A missing
+// deleted_at field matches as well, so documents written before soft deletion still count.
+func notDeleted(filter bson.M) bson.M {
+	filter["deleted_at"] = nil
+	return filter
+}
+
+// EnsureIndexes creates the unique indexes on the email and user name of active accounts. Soft-deleted
+// accounts are left out of them, so their email and user name can be taken by a new account. Accounts
+// stored before soft deletion have no deleted_at field and join the indexes when next updated.
+func (ur *UserRepository) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(ur.config.ContextTimeout) * time.Second)
+	defer cancel()
+	active := bson.M{"deleted_at": bson.M{"$type": "null"}}
+	_, err := ur.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetName(emailIndex).SetUnique(true).SetPartialFilterExpression(active),
+		},
+		{
+			Keys:    bson.D{{Key: "user_name", Value: 1}},
+			Options: options.Index().SetName(userNameIndex).SetUnique(true).SetPartialFilterExpression(active),
+		},
+	})
+	return err
+}
+
+// duplicateUserError returns domain.ErrEmailTaken or domain.ErrUserNameTaken when err is a write that
+// collided with another account on one of the unique indexes, and err otherwise.
+func duplicateUserError(err error) error {
+	if !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+	if strings.Contains(err.Error(), userNameIndex) {
+		return domain.ErrUserNameTaken
+	}
+	return domain.ErrEmailTaken
+}
+
 func (ur *UserRepository) FindUserByEmail(email string) (domain.User, error) {
 	var user domain.User
-	context, _ := context.WithTimeout(context.Background(), time.Duration(ur.config.ContextTimeout) * time.Second)
-	filter := bson.M{"email": email}
+	context, cancel := context.WithTimeout(context.Background(), time.Duration(ur.config.ContextTimeout) * time.Second)
+	defer cancel()
+	filter := notDeleted(bson.M{"email": infrastructure.NormalizeEmail(email)})
 	err := ur.collection.FindOne(context, filter).Decode(&user)
 	if err != nil {
 		return user, err
@@ -36,8 +83,9 @@ func (ur *UserRepository) FindUserByEmail(email string) (domain.User, error) {
 
 func (ur *UserRepository) FindUserByUserName(username string) (domain.User, error) {
 	var user domain.User
-	context, _ := context.WithTimeout(context.Background(), time.Duration(ur.config.ContextTimeout) * time.Second)
-	filter := bson.M{"user_name": username}
+	context, cancel := context.WithTimeout(context.Background(), time.Duration(ur.config.ContextTimeout) * time.Second)
+	defer cancel()
+	filter := notDeleted(bson.M{"user_name": username})
 	err := ur.collection.FindOne(context, filter).Decode(&user)
 	if err != nil {
 		return user, err
@@ -47,33 +95,73 @@ func (ur *UserRepository) FindUserByUserName(username string) (domain.User, erro
 
 
 func (ur *UserRepository) RegisterUser(user domain.User) error {
-	context, _ := context.WithTimeout(context.Background(), time.Duration(ur.config.ContextTimeout) * time.Second)
-	user.ID = primitive.NewObjectID()
+	return ur.RegisterUserWithContext(context.Background(), user)
+}
+
+// RegisterUserWithContext inserts the user as part of the unit of work carried by ctx.
+func (ur *UserRepository) RegisterUserWithContext(ctx context.Context, user domain.User) error {
+	context, cancel := context.WithTimeout(ctx, time.Duration(ur.config.ContextTimeout) * time.Second)
+	defer cancel()
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
 	_, err := ur.collection.InsertOne(context, user)
 	if err != nil {
-		return err
+		return duplicateUserError(err)
 	}
 	return nil
 }
 func (ur *UserRepository) UpdateUser(user domain.User) error {
-	context, _ := context.WithTimeout(context.Background(), time.Duration(ur.config.ContextTimeout) * time.Second)
-	filter := bson.M{"email": user.Email}
+	return ur.UpdateUserWithContext(context.Background(), user)
+}
+
+// UpdateUserWithContext saves the user as part of the unit of work carried by ctx.
+func (ur *UserRepository) UpdateUserWithContext(ctx context.Context, user domain.User) error {
+	context, cancel := context.WithTimeout(ctx, time.Duration(ur.config.ContextTimeout) * time.Second)
+	defer cancel()
+	filter := notDeleted(bson.M{"_id": user.ID})
 	update := bson.M{"$set": user}
 	_, err := ur.collection.UpdateOne(context, filter, update)
 	if err != nil {
-		return err
+		return duplicateUserError(err)
 	}
 	return nil
 }
 
 func (ur *UserRepository) FindUserByID(id string)(domain.User, error){
 	var user domain.User
-	context, _ := context.WithTimeout(context.Background(), time.Duration(ur.config.ContextTimeout) * time.Second)
+	context, cancel := context.WithTimeout(context.Background(), time.Duration(ur.config.ContextTimeout) * time.Second)
+	defer cancel()
 	objectId, _ := primitive.ObjectIDFromHex(id)
-	filter := bson.M{"_id": objectId}
+	filter := notDeleted(bson.M{"_id": objectId})
 	err := ur.collection.FindOne(context, filter).Decode(&user)
 	if err != nil {
 		return user, err
 	}
 	return user, nil
-}
\ No newline at end of file
+}
+
+
+// DeleteUser soft-deletes a user, recording when, by whom and why. The document is kept so the
+// account can be restored and its loans keep a valid owner.
+func (ur *UserRepository) DeleteUserWithContext(ctx context.Context, id string, deleted_by string, reason string, deleted_at time.Time) error{
+	context, cancel := context.WithTimeout(ctx, time.Duration(ur.config.ContextTimeout) * time.Second)
+	defer cancel()
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	update := bson.M{"$set": bson.M{
+		"deleted_at": deleted_at,
+		"deleted_by": deleted_by,
+		"deletion_reason": reason,
+	}}
+	result, err := ur.collection.UpdateOne(context, notDeleted(bson.M{"_id": objectId}), update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
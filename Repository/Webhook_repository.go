@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	domain "loan-tracker/Domain"
+	infrastructure "loan-tracker/Infrastructure"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type WebhookRepository struct {
+	collection *mongo.Collection
+	config     *infrastructure.Config
+}
+
+func NewWebhookRepository(collection *mongo.Collection, config *infrastructure.Config) *WebhookRepository {
+	return &WebhookRepository{
+		collection: collection,
+		config:     config,
+	}
+}
+
+func (wr *WebhookRepository) Create(endpoint domain.WebhookEndpoint) (domain.WebhookEndpoint, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(wr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	endpoint.ID = primitive.NewObjectID()
+	_, err := wr.collection.InsertOne(ctx, endpoint)
+	if err != nil {
+		return domain.WebhookEndpoint{}, err
+	}
+	return endpoint, nil
+}
+
+func (wr *WebhookRepository) FindByID(id string) (domain.WebhookEndpoint, error) {
+	var endpoint domain.WebhookEndpoint
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(wr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return endpoint, err
+	}
+	err = wr.collection.FindOne(ctx, bson.M{"_id": objectId}).Decode(&endpoint)
+	return endpoint, err
+}
+
+func (wr *WebhookRepository) FindAll() ([]domain.WebhookEndpoint, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(wr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	return wr.find(ctx, bson.M{})
+}
+
+// FindSubscribed returns the active endpoints subscribed to the event type. It reads within the
+// unit of work carried by ctx.
+func (wr *WebhookRepository) FindSubscribed(ctx context.Context, event string) ([]domain.WebhookEndpoint, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(wr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	return wr.find(ctx, bson.M{"active": true, "events": event})
+}
+
+func (wr *WebhookRepository) find(ctx context.Context, filter bson.M) ([]domain.WebhookEndpoint, error) {
+	endpoints := []domain.WebhookEndpoint{}
+	cursor, err := wr.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, &endpoints); err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+func (wr *WebhookRepository) Update(endpoint domain.WebhookEndpoint) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(wr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	result, err := wr.collection.ReplaceOne(ctx, bson.M{"_id": endpoint.ID}, endpoint)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (wr *WebhookRepository) Delete(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(wr.config.ContextTimeout)*time.Second)
+	defer cancel()
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	result, err := wr.collection.DeleteOne(ctx, bson.M{"_id": objectId})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	domain "loan-tracker/Domain"
+	"strings"
+	"time"
+)
+
+// closeAccount soft-deletes a user once every loan of theirs is rejected or repaid and ends
+// all of their sessions, in the unit of work of ctx. It returns the user as deleted. Admin
+// deletion and self-service closure share it.
+func closeAccount(ctx context.Context, userRepo domain.UserRepositoryInterface, loanRepo domain.LoanRepositoryInterface, sessionRepo domain.SessionRepositoryInterface, user domain.User, deleted_by string, reason string) (domain.User, error) {
+	open, err := loanRepo.CountOpenLoans(user.ID.Hex())
+	if err != nil {
+		return domain.User{}, errors.New("error checking loans")
+	}
+	if open > 0 {
+		return domain.User{}, errors.New("account cannot be closed while a loan is pending, approved or not fully repaid")
+	}
+	now := time.Now()
+	user.DeletedAt = &now
+	user.DeletedBy = deleted_by
+	user.DeletionReason = strings.TrimSpace(reason)
+	err = userRepo.DeleteUserWithContext(ctx, user.ID.Hex(), user.DeletedBy, user.DeletionReason, now)
+	if err != nil {
+		return domain.User{}, errors.New("error deleting user")
+	}
+	err = sessionRepo.RevokeUserSessionsWithContext(ctx, user.ID.Hex(), "")
+	if err != nil {
+		return domain.User{}, errors.New("error revoking sessions")
+	}
+	return user, nil
+}
+
+// CloseAccount lets a signed-in user close their own account after confirming their password.
+func (uc *UserUseCase) CloseAccount(id string, password string, reason string) error {
+	user, err := uc.UserRepo.FindUserByID(id)
+	if err != nil {
+		return errors.New("user not found")
+	}
+	if !uc.PassService.ComparePassword(password, user.Password) {
+		return errors.New("password is incorrect")
+	}
+	return uc.UnitOfWork.Do(func(ctx context.Context) error {
+		_, err := closeAccount(ctx, uc.UserRepo, uc.LoanRepo, uc.SessionRepo, user, id, reason)
+		return err
+	})
+}
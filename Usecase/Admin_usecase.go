@@ -1,42 +1,73 @@
 package usecases
 
 import (
+	"context"
 	"errors"
 	domain "loan-tracker/Domain"
 	infrastructure "loan-tracker/Infrastructure"
 	utils "loan-tracker/Utils"
+	"strings"
+	"time"
 )
 
 type AdminUseCase struct {
 	AdminRepo domain.AdminRepositoryInterface
 	UserRepo domain.UserRepositoryInterface
+	SessionRepo domain.SessionRepositoryInterface
+	LoginAttempts domain.LoginAttemptStoreInterface
 	PassService infrastructure.PasswordService
 	Config *infrastructure.Config
+	LoanRepo domain.LoanRepositoryInterface
+	Outbox domain.OutboxRepositoryInterface
+	UnitOfWork domain.UnitOfWorkInterface
+	Emails domain.EmailTemplatesInterface
+	Events domain.EventBusInterface
 }
 
 
-func NewAdminUseCase(adminRepo domain.AdminRepositoryInterface, passwordService infrastructure.PasswordService, config *infrastructure.Config, userRepo domain.UserRepositoryInterface) *AdminUseCase {
+func NewAdminUseCase(adminRepo domain.AdminRepositoryInterface, passwordService infrastructure.PasswordService, config *infrastructure.Config, userRepo domain.UserRepositoryInterface, sessionRepo domain.SessionRepositoryInterface, loginAttempts domain.LoginAttemptStoreInterface, loanRepo domain.LoanRepositoryInterface, outbox domain.OutboxRepositoryInterface, unitOfWork domain.UnitOfWorkInterface, emails domain.EmailTemplatesInterface, events domain.EventBusInterface) *AdminUseCase {
 	return &AdminUseCase{
 		AdminRepo: adminRepo,
 		UserRepo: userRepo,
+		SessionRepo: sessionRepo,
+		LoginAttempts: loginAttempts,
 		PassService: passwordService,
 		Config: config,
+		LoanRepo: loanRepo,
+		Outbox: outbox,
+		UnitOfWork: unitOfWork,
+		Emails: emails,
+		Events: events,
 	}
 }
 
 
+// recordUserAction records an admin action on a user with the fields it changed.
+func (ac *AdminUseCase) recordUserAction(ctx context.Context, action string, before domain.User, after domain.User, user_id string, client domain.ClientInfo) error{
+	changes := auditDiff(userSnapshot(before), userSnapshot(after))
+	return recordAction(ctx, ac.Events, newAuditRecord(domain.AuditAdmin, action, user_id, "user", before.ID.Hex(), client, changes))
+}
+
+
+// saveUser stores an admin's change to a user and its audit record in one unit of work.
+func (ac *AdminUseCase) saveUser(user domain.User, before domain.User, action string, user_id string, client domain.ClientInfo) error{
+	return ac.UnitOfWork.Do(func(ctx context.Context) error {
+		if err := ac.UserRepo.UpdateUserWithContext(ctx, user); err != nil{
+			return errors.New("error updating user")
+		}
+		if err := ac.recordUserAction(ctx, action, before, user, user_id, client); err != nil{
+			return errors.New("error recording audit action")
+		}
+		return nil
+	})
+}
+
+
 func (ac *AdminUseCase) GetAllUsers(pageNo, pageSize string, user_id string) ([]domain.User, error){
 	pageS, pageN, err := utils.PagePaginationValidator(pageSize, pageNo)
 	if err != nil{
 		return nil, err
 	}
-	user, err := ac.UserRepo.FindUserByID(user_id)
-	if err != nil{
-		return nil, err
-	}
-	if user.Role != "admin"{
-		return nil, errors.New("unauthorized: Only admin can access this resource")
-	}
 	users, err := ac.AdminRepo.GetAllUsers(pageN, pageS)
 	if err != nil{
 		return nil, errors.New("error getting users")
@@ -45,19 +76,324 @@ func (ac *AdminUseCase) GetAllUsers(pageNo, pageSize string, user_id string) ([]
 }
 
 
-func (ac *AdminUseCase) DeleteUser(id string, user_id string) (bool, error){
-	_, err := ac.UserRepo.FindUserByID(id)
-	if err != nil{
-		return false, err
+// DeleteUser soft-deletes a user. It is refused while the user still owes on a loan.
+func (ac *AdminUseCase) DeleteUser(id string, reason string, user_id string, client domain.ClientInfo) (bool, error){
+	if id == user_id{
+		return false, errors.New("admins cannot delete their own account")
 	}
-	admin, err := ac.UserRepo.FindUserByID(user_id)
-	if err != nil || admin.Role != "admin"{
-		return false, errors.New("unauthorized: Only admin can access this resource")
+	user, err := ac.UserRepo.FindUserByID(id)
+	if err != nil{
+		return false, errors.New("user not found")
 	}
 	
-	err = ac.AdminRepo.DeleteUser(user_id)
+	err = ac.UnitOfWork.Do(func(ctx context.Context) error {
+		after, err := closeAccount(ctx, ac.UserRepo, ac.LoanRepo, ac.SessionRepo, user, user_id, reason)
+		if err != nil{
+			return err
+		}
+		if err := ac.recordUserAction(ctx, domain.AuditUserDeleted, user, after, user_id, client); err != nil{
+			return errors.New("error recording audit action")
+		}
+		return nil
+	})
 	if err != nil{
 		return false, err
 	}
 	return true, nil
-}
\ No newline at end of file
+}
+
+
+// RestoreUser undoes a soft deletion within the USER_RETENTION_DAYS window, as long as no
+// active account has taken the user's email or user name in the meantime.
+func (ac *AdminUseCase) RestoreUser(id string, user_id string, client domain.ClientInfo) error{
+	user, err := ac.AdminRepo.FindDeletedUserByID(id)
+	if err != nil{
+		return errors.New("deleted user not found")
+	}
+	if user.ErasedAt != nil{
+		return errors.New("the personal data of this user was erased")
+	}
+	retention := time.Duration(ac.Config.UserRetentionDays) * 24 * time.Hour
+	if time.Since(*user.DeletedAt) > retention{
+		return errors.New("the retention window for restoring this user has passed")
+	}
+	if _, err := ac.UserRepo.FindUserByEmail(user.Email); err == nil{
+		return errors.New("email is already used by another account")
+	}
+	if _, err := ac.UserRepo.FindUserByUserName(user.User_Name); err == nil{
+		return errors.New("user name is already used by another account")
+	}
+	after := user
+	after.DeletedAt = nil
+	after.DeletedBy = ""
+	after.DeletionReason = ""
+	return ac.UnitOfWork.Do(func(ctx context.Context) error {
+		err := ac.AdminRepo.RestoreUserWithContext(ctx, id)
+		switch {
+		case errors.Is(err, domain.ErrEmailTaken):
+			return errors.New("email is already used by another account")
+		case errors.Is(err, domain.ErrUserNameTaken):
+			return errors.New("user name is already used by another account")
+		case err != nil:
+			return errors.New("error restoring user")
+		}
+		if err := ac.recordUserAction(ctx, domain.AuditUserRestored, user, after, user_id, client); err != nil{
+			return errors.New("error recording audit action")
+		}
+		return nil
+	})
+}
+
+
+func (ac *AdminUseCase) RevokeUserSessions(id string, user_id string, client domain.ClientInfo) error{
+	user, err := ac.UserRepo.FindUserByID(id)
+	if err != nil{
+		return errors.New("user not found")
+	}
+	return ac.UnitOfWork.Do(func(ctx context.Context) error {
+		if err := ac.SessionRepo.RevokeUserSessionsWithContext(ctx, id, ""); err != nil{
+			return errors.New("error revoking sessions")
+		}
+		if err := ac.recordUserAction(ctx, domain.AuditUserSessionsRevoked, user, user, user_id, client); err != nil{
+			return errors.New("error recording audit action")
+		}
+		return nil
+	})
+}
+
+
+// UnlockUser clears the failed login attempts and lockout of a user's account. The attempts are
+// not stored in the database, so they are only cleared once the audit record is queued.
+func (ac *AdminUseCase) UnlockUser(id string, user_id string, client domain.ClientInfo) error{
+	user, err := ac.UserRepo.FindUserByID(id)
+	if err != nil{
+		return errors.New("user not found")
+	}
+	err = ac.UnitOfWork.Do(func(ctx context.Context) error {
+		return ac.recordUserAction(ctx, domain.AuditUserUnlocked, user, user, user_id, client)
+	})
+	if err != nil{
+		return errors.New("error recording audit action")
+	}
+	ac.LoginAttempts.Reset(accountAttemptKey(user.Email))
+	return nil
+}
+
+
+// GetUser returns a user's full record together with their loans.
+func (ac *AdminUseCase) GetUser(id string, user_id string) (domain.AdminUserDetail, error){
+	user, err := ac.UserRepo.FindUserByID(id)
+	if err != nil{
+		return domain.AdminUserDetail{}, errors.New("user not found")
+	}
+	loans, err := ac.LoanRepo.FindLoansByUserID(id)
+	if err != nil{
+		return domain.AdminUserDetail{}, errors.New("error getting loans")
+	}
+	return domain.AdminUserDetail{User: user, Loans: loans}, nil
+}
+
+
+func (ac *AdminUseCase) SearchUsers(filter domain.UserSearchFilter, pageNo, pageSize string, user_id string) ([]domain.User, error){
+	pageS, pageN, err := utils.PagePaginationValidator(pageSize, pageNo)
+	if err != nil{
+		return nil, err
+	}
+	switch filter.Status{
+	case "", domain.UserStatusActive, domain.UserStatusSuspended, domain.UserStatusUnverified, domain.UserStatusDeleted:
+	default:
+		return nil, errors.New("invalid status: expected active, suspended, unverified or deleted")
+	}
+	users, err := ac.AdminRepo.SearchUsers(filter, pageN, pageS)
+	if err != nil{
+		return nil, errors.New("error searching users")
+	}
+	return users, nil
+}
+
+
+// SuspendUser blocks a user from signing in and ends all of their sessions.
+func (ac *AdminUseCase) SuspendUser(id string, reason string, user_id string, client domain.ClientInfo) error{
+	if id == user_id{
+		return errors.New("admins cannot suspend their own account")
+	}
+	user, err := ac.UserRepo.FindUserByID(id)
+	if err != nil{
+		return errors.New("user not found")
+	}
+	if user.IsSuspended{
+		return errors.New("user is already suspended")
+	}
+	before := user
+	user.IsSuspended = true
+	user.SuspendedAt = time.Now()
+	user.SuspendedBy = user_id
+	user.SuspensionReason = strings.TrimSpace(reason)
+	err = ac.saveUser(user, before, domain.AuditUserSuspended, user_id, client)
+	if err != nil{
+		return err
+	}
+	err = ac.SessionRepo.RevokeUserSessions(id, "")
+	if err != nil{
+		return errors.New("error revoking sessions")
+	}
+	return nil
+}
+
+
+func (ac *AdminUseCase) ReactivateUser(id string, user_id string, client domain.ClientInfo) error{
+	user, err := ac.UserRepo.FindUserByID(id)
+	if err != nil{
+		return errors.New("user not found")
+	}
+	if !user.IsSuspended{
+		return errors.New("user is not suspended")
+	}
+	before := user
+	user.IsSuspended = false
+	user.SuspendedAt = time.Time{}
+	user.SuspendedBy = ""
+	user.SuspensionReason = ""
+	err = ac.saveUser(user, before, domain.AuditUserReactivated, user_id, client)
+	if err != nil{
+		return err
+	}
+	return nil
+}
+
+
+// ForceVerifyUser marks a user's email as verified without the verification link.
+func (ac *AdminUseCase) ForceVerifyUser(id string, user_id string, client domain.ClientInfo) error{
+	user, err := ac.UserRepo.FindUserByID(id)
+	if err != nil{
+		return errors.New("user not found")
+	}
+	if user.IsVerified{
+		return errors.New("user is already verified")
+	}
+	after := user
+	after.IsVerified = true
+	return ac.UnitOfWork.Do(func(ctx context.Context) error {
+		if err := markVerified(ctx, ac.UserRepo, ac.Events, user, user_id); err != nil{
+			return errors.New("error updating user")
+		}
+		if err := ac.recordUserAction(ctx, domain.AuditUserVerified, user, after, user_id, client); err != nil{
+			return errors.New("error recording audit action")
+		}
+		return nil
+	})
+}
+
+
+// TriggerPasswordReset emails the user a password reset link, as if they had asked for one.
+func (ac *AdminUseCase) TriggerPasswordReset(id string, user_id string, client domain.ClientInfo) error{
+	user, err := ac.UserRepo.FindUserByID(id)
+	if err != nil{
+		return errors.New("user not found")
+	}
+	return ac.UnitOfWork.Do(func(ctx context.Context) error {
+		if err := issuePasswordReset(ctx, ac.UserRepo, ac.Events, ac.Config, user, user_id); err != nil{
+			return err
+		}
+		if err := ac.recordUserAction(ctx, domain.AuditUserPasswordResetIssued, user, user, user_id, client); err != nil{
+			return errors.New("error recording audit action")
+		}
+		return nil
+	})
+}
+
+
+func (ac *AdminUseCase) ChangeUserRole(id string, role string, user_id string, client domain.ClientInfo) error{
+	role = strings.ToLower(strings.TrimSpace(role))
+	if role != domain.RoleUser && role != domain.RoleAdmin{
+		return errors.New("invalid role: expected user or admin")
+	}
+	if id == user_id{
+		return errors.New("admins cannot change their own role")
+	}
+	user, err := ac.UserRepo.FindUserByID(id)
+	if err != nil{
+		return errors.New("user not found")
+	}
+	if user.Role == role{
+		return nil
+	}
+	before := user
+	user.Role = role
+	err = ac.saveUser(user, before, domain.AuditUserRoleChanged, user_id, client)
+	if err != nil{
+		return err
+	}
+	// Tokens carry no role, but sessions were 2FA-checked against the old role's requirements.
+	err = ac.SessionRepo.RevokeUserSessions(id, "")
+	if err != nil{
+		return errors.New("error revoking sessions")
+	}
+	return nil
+}
+
+
+func (ac *AdminUseCase) ListEmailTemplates(user_id string) (domain.EmailTemplateList, error){
+	return domain.EmailTemplateList{
+		Templates: ac.Emails.Templates(),
+		Locales: ac.Emails.Locales(),
+	}, nil
+}
+
+
+// PreviewEmail renders a template with sample values in the given locale.
+func (ac *AdminUseCase) PreviewEmail(name string, locale string, user_id string) (domain.EmailPreview, error){
+	if locale == ""{
+		locale = ac.Config.MailDefaultLocale
+	}
+	if !ac.Emails.SupportsLocale(locale){
+		return domain.EmailPreview{}, errors.New("unsupported locale")
+	}
+	preview, err := ac.Emails.Preview(name, locale)
+	if err != nil{
+		return domain.EmailPreview{}, errors.New("email template not found")
+	}
+	return preview, nil
+}
+
+
+// ListDeliveries pages through outbox messages with the given status, dead ones by default.
+func (ac *AdminUseCase) ListDeliveries(status string, pageNo, pageSize string, user_id string) ([]domain.OutboxMessage, error){
+	pageS, pageN, err := utils.PagePaginationValidator(pageSize, pageNo)
+	if err != nil{
+		return nil, err
+	}
+	if status == ""{
+		status = domain.OutboxDead
+	}
+	switch status{
+	case domain.OutboxPending, domain.OutboxSending, domain.OutboxSent, domain.OutboxDead:
+	default:
+		return nil, errors.New("invalid status: expected pending, sending, sent or dead")
+	}
+	messages, err := ac.Outbox.FindByStatus(status, pageN, pageS)
+	if err != nil{
+		return nil, errors.New("error getting deliveries")
+	}
+	return messages, nil
+}
+
+
+// RetryDelivery queues a dead-lettered message again with a fresh attempt count.
+func (ac *AdminUseCase) RetryDelivery(id string, user_id string) error{
+	err := ac.Outbox.Retry(id)
+	if err != nil{
+		return errors.New("dead delivery not found")
+	}
+	return nil
+}
+
+
+// GetDelivery returns an outbox message together with the log of its delivery attempts.
+func (ac *AdminUseCase) GetDelivery(id string, user_id string) (domain.OutboxMessage, error){
+	message, err := ac.Outbox.FindByID(id)
+	if err != nil{
+		return domain.OutboxMessage{}, errors.New("delivery not found")
+	}
+	return message, nil
+}
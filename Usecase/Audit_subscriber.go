@@ -0,0 +1,28 @@
+package usecases
+
+import (
+	"context"
+	domain "loan-tracker/Domain"
+)
+
+// AuditSubscriber queues the records of audited actions for the hash-chained audit log. It runs
+// synchronously, in the action's unit of work, so a record is stored with the action or not at all.
+// The outbox worker appends it to the log and retries until it succeeds.
+type AuditSubscriber struct {
+	Outbox domain.OutboxRepositoryInterface
+}
+
+func NewAuditSubscriber(outbox domain.OutboxRepositoryInterface) *AuditSubscriber {
+	return &AuditSubscriber{
+		Outbox: outbox,
+	}
+}
+
+func (as *AuditSubscriber) Register(bus *EventBus) {
+	Subscribe(bus, "audit", as.actionPerformed)
+}
+
+func (as *AuditSubscriber) actionPerformed(ctx context.Context, event domain.ActionPerformed) error {
+	record := event.Record
+	return as.Outbox.EnqueueMessage(ctx, domain.OutboxMessage{Channel: domain.ChannelAudit, Audit: &record})
+}
@@ -0,0 +1,182 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	domain "loan-tracker/Domain"
+	infrastructure "loan-tracker/Infrastructure"
+	utils "loan-tracker/Utils"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// errChainBroken stops the walk of the audit log at the first record that does not match.
+var errChainBroken = errors.New("audit chain broken")
+
+// AuditUseCase lets admins query, export and verify the audit log.
+type AuditUseCase struct {
+	AuditRepo domain.AuditRepositoryInterface
+}
+
+func NewAuditUseCase(auditRepo domain.AuditRepositoryInterface) *AuditUseCase {
+	return &AuditUseCase{
+		AuditRepo: auditRepo,
+	}
+}
+
+func validateAuditFilter(filter domain.AuditFilter) error {
+	if filter.Category != "" && filter.Category != domain.AuditAdmin && filter.Category != domain.AuditSecurity {
+		return errors.New("invalid category: expected admin or security")
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return errors.New("from must be before to")
+	}
+	return nil
+}
+
+// GetRecords lists the matching audit records, newest first.
+func (au *AuditUseCase) GetRecords(filter domain.AuditFilter, pageNo, pageSize string, user_id string) ([]domain.AuditRecord, error) {
+	if err := validateAuditFilter(filter); err != nil {
+		return nil, err
+	}
+	pageS, pageN, err := utils.PagePaginationValidator(pageSize, pageNo)
+	if err != nil {
+		return nil, err
+	}
+	records, err := au.AuditRepo.Find(filter, pageN, pageS)
+	if err != nil {
+		return nil, errors.New("error getting audit records")
+	}
+	return records, nil
+}
+
+// ExportRecords calls fn with every matching audit record, oldest first.
+func (au *AuditUseCase) ExportRecords(filter domain.AuditFilter, user_id string, fn func(record domain.AuditRecord) error) error {
+	if err := validateAuditFilter(filter); err != nil {
+		return err
+	}
+	return au.AuditRepo.Each(filter, fn)
+}
+
+// VerifyChain walks the whole log and checks that the sequence has no gaps, that every record
+// links to the hash of the one before it and that every hash matches its record's content. A
+// record whose client details were erased must hold none.
+func (au *AuditUseCase) VerifyChain(user_id string) (domain.AuditVerification, error) {
+	result := domain.AuditVerification{Valid: true}
+	previous := domain.AuditRecord{}
+	err := au.AuditRepo.Each(domain.AuditFilter{}, func(record domain.AuditRecord) error {
+		reason := ""
+		switch {
+		case record.Sequence != previous.Sequence+1:
+			reason = fmt.Sprintf("record %d is missing", previous.Sequence+1)
+		case record.PrevHash != previous.Hash:
+			reason = "previous hash does not match the record before"
+		case record.Hash != infrastructure.HashAuditRecord(record):
+			reason = "hash does not match the record's content"
+		case record.ClientSalt == "" && (record.IP != "" || record.UserAgent != ""):
+			reason = "client details were added after they were erased"
+		case record.ClientSalt != "" && record.ClientDigest != infrastructure.AuditClientDigest(record.ClientSalt, record.IP, record.UserAgent):
+			reason = "client details do not match the record"
+		}
+		if reason != "" {
+			result = domain.AuditVerification{Valid: false, Records: result.Records, BrokenAt: record.Sequence, Reason: reason}
+			return errChainBroken
+		}
+		result.Records++
+		previous = record
+		return nil
+	})
+	if err != nil && !errors.Is(err, errChainBroken) {
+		return domain.AuditVerification{}, errors.New("error reading audit records")
+	}
+	return result, nil
+}
+
+// newAuditRecord starts the audit record of an action on a target, made from client. Its id is set
+// up front so the record is appended once however often its delivery is retried.
+func newAuditRecord(category, action, actor_id, target_type, target_id string, client domain.ClientInfo, changes []domain.AuditChange) domain.AuditRecord {
+	return domain.AuditRecord{
+		ID:         primitive.NewObjectID(),
+		Category:   category,
+		Action:     action,
+		ActorID:    actor_id,
+		TargetType: target_type,
+		TargetID:   target_id,
+		Changes:    changes,
+		IP:         client.IP,
+		UserAgent:  client.UserAgent,
+		RequestID:  client.RequestID,
+		Created_At: time.Now(),
+	}
+}
+
+// recordAction publishes the audit record of an action, which queues it in the outbox. Given the
+// context of a unit of work, the record is queued in it, and the returned error should fail the
+// unit of work so the action is not kept without its record. Actions made outside of one are
+// already stored, so callers only have the failure logged.
+func recordAction(ctx context.Context, events domain.EventBusInterface, record domain.AuditRecord) error {
+	err := events.Publish(ctx, domain.ActionPerformed{Record: record})
+	if err != nil {
+		log.Println("error recording audit action:", err)
+	}
+	return err
+}
+
+func auditTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// userSnapshot holds the user fields audited by admin and security actions. Secrets are left out.
+func userSnapshot(user domain.User) map[string]string {
+	deleted := ""
+	if user.DeletedAt != nil {
+		deleted = auditTime(*user.DeletedAt)
+	}
+	return map[string]string{
+		"role":                user.Role,
+		"is_verified":         strconv.FormatBool(user.IsVerified),
+		"is_suspended":        strconv.FormatBool(user.IsSuspended),
+		"suspension_reason":   user.SuspensionReason,
+		"deleted_at":          deleted,
+		"deletion_reason":     user.DeletionReason,
+		"two_factor_enabled":  strconv.FormatBool(user.TwoFactorEnabled),
+		"password_changed_at": auditTime(user.PasswordChangedAt),
+	}
+}
+
+// loanSnapshot holds the loan fields audited by admin actions.
+func loanSnapshot(loan domain.Loan) map[string]string {
+	return map[string]string{
+		"status":              loan.LoanStatus,
+		"amount":              formatAmount(loan.Amount),
+		"outstanding_balance": formatAmount(loan.OutstandingBalance),
+		"rejection_reason":    loan.RejectionReason,
+		"decided_by":          loan.DecidedBy,
+		"decided_at":          auditTime(timeValue(loan.DecidedAt)),
+		"disbursed_at":        auditTime(timeValue(loan.DisbursedAt)),
+		"installments":        strconv.Itoa(len(loan.Installments)),
+	}
+}
+
+// auditDiff lists the fields that differ between two snapshots, by name.
+func auditDiff(before, after map[string]string) []domain.AuditChange {
+	changes := []domain.AuditChange{}
+	for field, value := range after {
+		if before[field] != value {
+			changes = append(changes, domain.AuditChange{Field: field, Before: before[field], After: value})
+		}
+	}
+	slices.SortFunc(changes, func(a, b domain.AuditChange) int {
+		return strings.Compare(a.Field, b.Field)
+	})
+	return changes
+}
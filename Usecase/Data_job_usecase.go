@@ -0,0 +1,385 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	domain "loan-tracker/Domain"
+	infrastructure "loan-tracker/Infrastructure"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// dataJobStaleAfter is how long a job may stay running before another worker takes it over.
+const dataJobStaleAfter = 30 * time.Minute
+
+// DataJobUseCase queues personal data exports and erasures and processes them in the background.
+type DataJobUseCase struct {
+	JobRepo       domain.DataJobRepositoryInterface
+	UserRepo      domain.UserRepositoryInterface
+	LoanRepo      domain.LoanRepositoryInterface
+	PaymentRepo   domain.PaymentRepositoryInterface
+	SessionRepo   domain.SessionRepositoryInterface
+	InboxRepo     domain.InboxRepositoryInterface
+	OutboxRepo    domain.OutboxRepositoryInterface
+	AuditRepo     domain.AuditRepositoryInterface
+	StatementRepo domain.StatementRepositoryInterface
+	PassService   infrastructure.PasswordService
+	Config        *infrastructure.Config
+}
+
+func NewDataJobUseCase(jobRepo domain.DataJobRepositoryInterface, userRepo domain.UserRepositoryInterface, loanRepo domain.LoanRepositoryInterface, paymentRepo domain.PaymentRepositoryInterface, sessionRepo domain.SessionRepositoryInterface, inboxRepo domain.InboxRepositoryInterface, outboxRepo domain.OutboxRepositoryInterface, auditRepo domain.AuditRepositoryInterface, statementRepo domain.StatementRepositoryInterface, passwordService infrastructure.PasswordService, config *infrastructure.Config) *DataJobUseCase {
+	return &DataJobUseCase{
+		JobRepo:       jobRepo,
+		UserRepo:      userRepo,
+		LoanRepo:      loanRepo,
+		PaymentRepo:   paymentRepo,
+		SessionRepo:   sessionRepo,
+		InboxRepo:     inboxRepo,
+		OutboxRepo:    outboxRepo,
+		AuditRepo:     auditRepo,
+		StatementRepo: statementRepo,
+		PassService:   passwordService,
+		Config:        config,
+	}
+}
+
+func (du *DataJobUseCase) queueJob(user_id string, job_type string) (domain.DataJob, error) {
+	if job, err := du.JobRepo.FindActiveJob(user_id, job_type); err == nil {
+		return job, nil
+	}
+	userId, err := primitive.ObjectIDFromHex(user_id)
+	if err != nil {
+		return domain.DataJob{}, errors.New("invalid user id")
+	}
+	job, err := du.JobRepo.CreateJob(domain.DataJob{
+		UserID:     userId,
+		Type:       job_type,
+		Status:     domain.DataJobPending,
+		Created_At: time.Now(),
+	})
+	if err != nil {
+		return domain.DataJob{}, errors.New("error creating job")
+	}
+	return job, nil
+}
+
+func (du *DataJobUseCase) findUserJob(id string, user_id string, job_type string) (domain.DataJob, error) {
+	job, err := du.JobRepo.FindJobByID(id)
+	if err != nil || job.UserID.Hex() != user_id || job.Type != job_type {
+		return domain.DataJob{}, errors.New("job not found")
+	}
+	return job, nil
+}
+
+// RequestExport queues an export of the user's data. A pending or running export is returned
+// instead of queueing another one.
+func (du *DataJobUseCase) RequestExport(user_id string) (domain.DataJob, error) {
+	return du.queueJob(user_id, domain.DataJobExport)
+}
+
+func (du *DataJobUseCase) GetExports(user_id string) ([]domain.DataJob, error) {
+	jobs, err := du.JobRepo.FindJobsByUserID(user_id, domain.DataJobExport)
+	if err != nil {
+		return nil, errors.New("error getting exports")
+	}
+	return jobs, nil
+}
+
+func (du *DataJobUseCase) GetExport(id string, user_id string) (domain.DataJob, error) {
+	return du.findUserJob(id, user_id, domain.DataJobExport)
+}
+
+// GetExportFile returns the path of a completed export archive that has not expired yet.
+func (du *DataJobUseCase) GetExportFile(id string, user_id string) (string, error) {
+	job, err := du.findUserJob(id, user_id, domain.DataJobExport)
+	if err != nil {
+		return "", err
+	}
+	if job.Status != domain.DataJobCompleted {
+		return "", errors.New("export is not ready")
+	}
+	if job.ExpiresAt == nil || time.Now().After(*job.ExpiresAt) {
+		return "", errors.New("export has expired")
+	}
+	return job.FilePath, nil
+}
+
+// RequestErasure queues the pseudonymization of the user's personal data. It is refused while a
+// loan is still pending, approved or being repaid, because the lender must be able to identify the borrower.
+func (du *DataJobUseCase) RequestErasure(user_id string, password string) (domain.DataJob, error) {
+	user, err := du.UserRepo.FindUserByID(user_id)
+	if err != nil {
+		return domain.DataJob{}, errors.New("user not found")
+	}
+	if !du.PassService.ComparePassword(password, user.Password) {
+		return domain.DataJob{}, errors.New("password is incorrect")
+	}
+	open, err := du.LoanRepo.CountOpenLoans(user_id)
+	if err != nil {
+		return domain.DataJob{}, errors.New("error checking loans")
+	}
+	if open > 0 {
+		return domain.DataJob{}, errors.New("personal data cannot be erased while a loan is pending, approved or not fully repaid")
+	}
+	return du.queueJob(user_id, domain.DataJobErasure)
+}
+
+func (du *DataJobUseCase) GetErasure(id string, user_id string) (domain.DataJob, error) {
+	return du.findUserJob(id, user_id, domain.DataJobErasure)
+}
+
+// Run polls for queued jobs until the context is cancelled. Every instance of the API may run it;
+// jobs are claimed atomically.
+func (du *DataJobUseCase) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(du.Config.DataJobPollSeconds) * time.Second)
+	defer ticker.Stop()
+	for {
+		du.removeExpiredExports()
+		for {
+			job, err := du.JobRepo.ClaimNextJob(time.Now().Add(-dataJobStaleAfter))
+			if err != nil {
+				if !errors.Is(err, mongo.ErrNoDocuments) {
+					log.Println("error claiming data job:", err)
+				}
+				break
+			}
+			du.process(job)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (du *DataJobUseCase) process(job domain.DataJob) {
+	var err error
+	switch job.Type {
+	case domain.DataJobExport:
+		err = du.export(&job)
+	case domain.DataJobErasure:
+		err = du.erase(job.UserID.Hex())
+	default:
+		err = fmt.Errorf("unknown job type %q", job.Type)
+	}
+	completed := time.Now()
+	job.CompletedAt = &completed
+	job.Status = domain.DataJobCompleted
+	if err != nil {
+		log.Printf("data job %s failed: %v", job.ID.Hex(), err)
+		job.Status = domain.DataJobFailed
+		job.Error = err.Error()
+	}
+	if err := du.JobRepo.UpdateJob(job); err != nil {
+		log.Println("error updating data job:", err)
+	}
+}
+
+func (du *DataJobUseCase) export(job *domain.DataJob) error {
+	user_id := job.UserID.Hex()
+	user, err := du.UserRepo.FindUserByID(user_id)
+	if err != nil {
+		return errors.New("user not found")
+	}
+	loans, err := du.LoanRepo.FindLoansByUserID(user_id)
+	if err != nil {
+		return errors.New("error getting loans")
+	}
+	loan_ids := make([]primitive.ObjectID, len(loans))
+	for i, loan := range loans {
+		loan_ids[i] = loan.ID
+	}
+	payments, err := du.PaymentRepo.FindByLoanIDs(loan_ids)
+	if err != nil {
+		return errors.New("error getting payments")
+	}
+	sessions, err := du.SessionRepo.FindSessionHistory(user_id)
+	if err != nil {
+		return errors.New("error getting login history")
+	}
+
+	path := filepath.Join(du.Config.DataExportDir, job.ID.Hex()+".zip")
+	if err := infrastructure.WriteDataArchive(path, exportSections(user, loans, payments, sessions)); err != nil {
+		return err
+	}
+	job.FilePath = path
+	expires := time.Now().Add(time.Duration(du.Config.DataExportTTLHours) * time.Hour)
+	job.ExpiresAt = &expires
+	return nil
+}
+
+// exportSections lays out everything held about the user. The service stores no uploaded documents,
+// so there is no documents section.
+func exportSections(user domain.User, loans []domain.Loan, payments []domain.Payment, sessions []domain.Session) []domain.ArchiveSection {
+	profile := domain.ArchiveSection{
+		Name:    "profile",
+		Records: user,
+		Header:  []string{"id", "user_name", "email", "contact", "role", "is_verified", "two_factor_enabled", "created_at", "password_changed_at"},
+		Rows: [][]string{{
+			user.ID.Hex(), user.User_Name, user.Email, user.Contact, user.Role,
+			strconv.FormatBool(user.IsVerified), strconv.FormatBool(user.TwoFactorEnabled),
+			formatTime(user.Created_At), formatTime(user.PasswordChangedAt),
+		}},
+	}
+
+	loanSection := domain.ArchiveSection{
+		Name:    "loans",
+		Records: loans,
+		Header:  []string{"id", "reference_number", "product", "amount", "term_months", "outstanding_balance", "loan_status", "created_at", "disbursed_at"},
+	}
+	for _, loan := range loans {
+		loanSection.Rows = append(loanSection.Rows, []string{
+			loan.ID.Hex(), loan.ReferenceNumber, loan.Product, strconv.FormatFloat(loan.Amount, 'f', 2, 64), strconv.Itoa(loan.TermMonths),
+			strconv.FormatFloat(loan.OutstandingBalance, 'f', 2, 64), loan.LoanStatus, formatTime(loan.Created_at), formatTime(timeValue(loan.DisbursedAt)),
+		})
+	}
+
+	paymentSection := domain.ArchiveSection{
+		Name:    "payments",
+		Records: payments,
+		Header:  []string{"id", "loan_id", "provider", "transaction_id", "reference", "amount", "currency", "status", "paid_at", "posted_at"},
+	}
+	for _, payment := range payments {
+		paymentSection.Rows = append(paymentSection.Rows, []string{
+			payment.ID.Hex(), payment.LoanID.Hex(), payment.Provider, payment.TransactionID, payment.Reference,
+			strconv.FormatFloat(payment.Amount, 'f', 2, 64), payment.Currency, payment.Status,
+			formatTime(payment.PaidAt), formatTime(payment.PostedAt),
+		})
+	}
+
+	history := domain.ArchiveSection{
+		Name:    "login_history",
+		Records: sessions,
+		Header:  []string{"id", "device", "ip", "user_agent", "created_at", "last_used_at", "revoked"},
+	}
+	for _, session := range sessions {
+		history.Rows = append(history.Rows, []string{
+			session.ID.Hex(), session.Device, session.IP, session.UserAgent,
+			formatTime(session.Created_At), formatTime(session.LastUsed_At), strconv.FormatBool(session.Revoked),
+		})
+	}
+	return []domain.ArchiveSection{profile, loanSection, paymentSection, history}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// erase pseudonymizes the user. Loans and payments are kept for the lender's records, as are webhook
+// deliveries and the audit records themselves; they only refer to the user ID, which no longer
+// leads to any personal data. The inbox, the queued and sent messages addressed to the user, the
+// client details of the user's sessions and audit records, the payer names of the statement lines
+// matched to the user's loans and earlier exports are removed.
+//
+// Every removal can be repeated, and the account is only pseudonymized and closed once they all
+// succeeded, in a single write. A failed erasure leaves the account as it was, so it can be
+// requested again.
+func (du *DataJobUseCase) erase(user_id string) error {
+	user, err := du.UserRepo.FindUserByID(user_id)
+	if err != nil {
+		return errors.New("user not found")
+	}
+	open, err := du.LoanRepo.CountOpenLoans(user_id)
+	if err != nil {
+		return errors.New("error checking loans")
+	}
+	if open > 0 {
+		return errors.New("user has open loans")
+	}
+
+	if _, err := du.InboxRepo.DeleteByUser(user_id); err != nil {
+		return errors.New("error deleting notifications")
+	}
+	addresses := []string{}
+	for _, address := range []string{user.Email, user.PendingEmail, user.Contact} {
+		if address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	if _, err := du.OutboxRepo.DeleteForRecipient(user_id, addresses); err != nil {
+		return errors.New("error deleting messages")
+	}
+	if _, err := du.AuditRepo.EraseClientDetails(user_id); err != nil {
+		return errors.New("error erasing audit client details")
+	}
+	if err := du.SessionRepo.AnonymizeUserSessions(user_id); err != nil {
+		return errors.New("error anonymizing sessions")
+	}
+	loans, err := du.LoanRepo.FindLoansByUserID(user_id)
+	if err != nil {
+		return errors.New("error getting loans")
+	}
+	if len(loans) > 0 {
+		loan_ids := make([]primitive.ObjectID, len(loans))
+		for i, loan := range loans {
+			loan_ids[i] = loan.ID
+		}
+		if _, err := du.StatementRepo.EraseLineNames(loan_ids); err != nil {
+			return errors.New("error erasing statement names")
+		}
+	}
+	// Earlier exports hold the personal data being erased.
+	exports, err := du.JobRepo.FindJobsByUserID(user_id, domain.DataJobExport)
+	if err != nil {
+		return errors.New("error getting exports")
+	}
+	for _, export := range exports {
+		du.expireExport(export)
+	}
+
+	pseudonym := "erased-" + user_id
+	now := time.Now()
+	erased := domain.User{
+		ID:             user.ID,
+		User_Name:      pseudonym,
+		Email:          pseudonym + "@invalid",
+		Role:           user.Role,
+		Created_At:     user.Created_At,
+		ErasedAt:       &now,
+		DeletedAt:      &now,
+		DeletedBy:      user_id,
+		DeletionReason: "personal data erasure",
+	}
+	if err := du.UserRepo.UpdateUser(erased); err != nil {
+		return errors.New("error pseudonymizing user")
+	}
+	return nil
+}
+
+func (du *DataJobUseCase) expireExport(job domain.DataJob) {
+	if job.FilePath != "" {
+		if err := os.Remove(job.FilePath); err != nil && !os.IsNotExist(err) {
+			log.Println("error removing export:", err)
+			return
+		}
+	}
+	if job.Status == domain.DataJobCompleted {
+		job.Status = domain.DataJobExpired
+	}
+	job.FilePath = ""
+	if err := du.JobRepo.UpdateJob(job); err != nil {
+		log.Println("error updating data job:", err)
+	}
+}
+
+func (du *DataJobUseCase) removeExpiredExports() {
+	jobs, err := du.JobRepo.FindExpiredExports(time.Now())
+	if err != nil {
+		log.Println("error finding expired exports:", err)
+		return
+	}
+	for _, job := range jobs {
+		du.expireExport(job)
+	}
+}
@@ -0,0 +1,20 @@
+package usecases
+
+import (
+	"fmt"
+	domain "loan-tracker/Domain"
+)
+
+// renderEmail renders a transactional email for one recipient in their locale.
+func renderEmail(templates domain.EmailTemplatesInterface, to string, locale string, name string, data domain.EmailData) (domain.EmailMessage, error) {
+	message, err := templates.Render(name, locale, data)
+	if err != nil {
+		return domain.EmailMessage{}, err
+	}
+	message.To = []string{to}
+	return message, nil
+}
+
+func formatAmount(amount float64) string {
+	return fmt.Sprintf("%.2f", amount)
+}
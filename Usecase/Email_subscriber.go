@@ -0,0 +1,162 @@
+package usecases
+
+import (
+	"context"
+	domain "loan-tracker/Domain"
+	infrastructure "loan-tracker/Infrastructure"
+	"time"
+)
+
+// EmailSubscriber sends the emails and notifications that follow from domain events. Its handlers
+// are synchronous: the messages are queued in the outbox within the publisher's unit of work.
+type EmailSubscriber struct {
+	Notifier domain.NotifierInterface
+	Outbox   domain.OutboxRepositoryInterface
+	Emails   domain.EmailTemplatesInterface
+	Config   *infrastructure.Config
+}
+
+func NewEmailSubscriber(notifier domain.NotifierInterface, outbox domain.OutboxRepositoryInterface, emails domain.EmailTemplatesInterface, config *infrastructure.Config) *EmailSubscriber {
+	return &EmailSubscriber{
+		Notifier: notifier,
+		Outbox:   outbox,
+		Emails:   emails,
+		Config:   config,
+	}
+}
+
+func (es *EmailSubscriber) Register(bus *EventBus) {
+	Subscribe(bus, "email", es.userRegistered)
+	Subscribe(bus, "email", es.verificationRequested)
+	Subscribe(bus, "email", es.passwordResetRequested)
+	Subscribe(bus, "email", es.passwordChanged)
+	Subscribe(bus, "email", es.emailChangeRequested)
+	Subscribe(bus, "email", es.accountLocked)
+	Subscribe(bus, "email", es.loanSubmitted)
+	Subscribe(bus, "email", es.loanStatusChanged)
+	Subscribe(bus, "email", es.paymentPosted)
+	Subscribe(bus, "email", es.paymentReminderDue)
+}
+
+// send queues an email to a single address, outside of the user's notification preferences.
+func (es *EmailSubscriber) send(ctx context.Context, to string, user domain.User, name string, data domain.EmailData) error {
+	message, err := renderEmail(es.Emails, to, user.Locale, name, data)
+	if err != nil {
+		return err
+	}
+	return es.Outbox.Enqueue(ctx, message)
+}
+
+func (es *EmailSubscriber) loanLink(loan domain.Loan) string {
+	return es.Config.PublicBaseURL + "/loans/" + loan.ID.Hex()
+}
+
+func (es *EmailSubscriber) userRegistered(ctx context.Context, event domain.UserRegistered) error {
+	return es.send(ctx, event.User.Email, event.User, domain.EmailVerification, domain.EmailData{
+		Name: event.User.User_Name,
+		Link: event.VerificationLink,
+	})
+}
+
+func (es *EmailSubscriber) verificationRequested(ctx context.Context, event domain.VerificationRequested) error {
+	return es.send(ctx, event.User.Email, event.User, domain.EmailVerification, domain.EmailData{
+		Name: event.User.User_Name,
+		Link: event.VerificationLink,
+	})
+}
+
+func (es *EmailSubscriber) passwordResetRequested(ctx context.Context, event domain.PasswordResetRequested) error {
+	return es.send(ctx, event.User.Email, event.User, domain.EmailPasswordReset, domain.EmailData{
+		Name: event.User.User_Name,
+		Link: event.ResetLink,
+	})
+}
+
+func (es *EmailSubscriber) passwordChanged(ctx context.Context, event domain.PasswordChanged) error {
+	return es.Notifier.Notify(ctx, event.User, domain.NotificationSecurity, domain.EmailPasswordChanged, domain.EmailData{
+		Name:      event.User.User_Name,
+		ChangedAt: event.User.PasswordChangedAt.UTC().Format(time.RFC1123),
+	})
+}
+
+// emailChangeRequested sends the confirmation link to the new address and tells the current one.
+func (es *EmailSubscriber) emailChangeRequested(ctx context.Context, event domain.EmailChangeRequested) error {
+	err := es.send(ctx, event.NewEmail, event.User, domain.EmailEmailChangeConfirmation, domain.EmailData{
+		Name: event.User.User_Name,
+		Link: event.ConfirmationLink,
+	})
+	if err != nil {
+		return err
+	}
+	return es.Notifier.Notify(ctx, event.User, domain.NotificationSecurity, domain.EmailEmailChangeNotice, domain.EmailData{
+		Name:     event.User.User_Name,
+		NewEmail: event.NewEmail,
+	})
+}
+
+func (es *EmailSubscriber) accountLocked(ctx context.Context, event domain.AccountLocked) error {
+	return es.Notifier.Notify(ctx, event.User, domain.NotificationSecurity, domain.EmailAccountLocked, domain.EmailData{
+		Name:  event.User.User_Name,
+		Until: event.Until.UTC().Format(time.RFC1123),
+	})
+}
+
+func (es *EmailSubscriber) loanSubmitted(ctx context.Context, event domain.LoanSubmitted) error {
+	return es.Notifier.Notify(ctx, event.Borrower, domain.NotificationLoan, domain.EmailLoanSubmitted, domain.EmailData{
+		Name:      event.Borrower.User_Name,
+		Reference: loanReference(event.Loan),
+		Amount:    formatAmount(event.Loan.Amount),
+	})
+}
+
+func (es *EmailSubscriber) loanStatusChanged(ctx context.Context, event domain.LoanStatusChanged) error {
+	loan := event.Loan
+	data := domain.EmailData{
+		Name:      event.Borrower.User_Name,
+		Reference: loanReference(loan),
+		Amount:    formatAmount(loan.Amount),
+	}
+	var name string
+	switch loan.LoanStatus {
+	case domain.LoanApproved:
+		name = domain.EmailLoanApproved
+		data.Link = es.loanLink(loan)
+	case domain.LoanRejected:
+		name = domain.EmailLoanRejected
+		data.Reason = loan.RejectionReason
+	case domain.LoanDisbursed:
+		name = domain.EmailLoanDisbursed
+		data.DueDate = loan.Installments[0].DueDate.Format("2006-01-02")
+		data.Link = es.loanLink(loan)
+	default:
+		return nil
+	}
+	return es.Notifier.Notify(ctx, event.Borrower, domain.NotificationLoan, name, data)
+}
+
+func (es *EmailSubscriber) paymentPosted(ctx context.Context, event domain.PaymentPosted) error {
+	return es.Notifier.Notify(ctx, event.Borrower, domain.NotificationPayment, domain.EmailPaymentReceived, domain.EmailData{
+		Name:      event.Borrower.User_Name,
+		Reference: loanReference(event.Loan),
+		Amount:    formatAmount(event.Payment.Amount),
+		Balance:   formatAmount(event.Loan.OutstandingBalance),
+		Link:      es.loanLink(event.Loan),
+	})
+}
+
+func (es *EmailSubscriber) paymentReminderDue(ctx context.Context, event domain.PaymentReminderDue) error {
+	installment := event.Installment
+	data := domain.EmailData{
+		Name:      event.Borrower.User_Name,
+		Reference: loanReference(event.Loan),
+		Amount:    formatAmount(installment.Amount - installment.PaidAmount),
+		Balance:   formatAmount(event.Loan.OutstandingBalance),
+		DueDate:   installment.DueDate.UTC().Format("2006-01-02"),
+	}
+	name := domain.EmailPaymentDue
+	if event.Offset > 0 {
+		name = domain.EmailPaymentOverdue
+		data.DaysOverdue = event.Offset
+	}
+	return es.Notifier.Notify(ctx, event.Borrower, domain.NotificationPayment, name, data)
+}
@@ -0,0 +1,186 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	domain "loan-tracker/Domain"
+	infrastructure "loan-tracker/Infrastructure"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// allEvents is the key of the subscriptions to every event.
+const allEvents = "*"
+
+type eventSubscription struct {
+	subscriber string
+	async      bool
+	handle     domain.EventHandler
+}
+
+// EventBus dispatches domain events to the subscribers registered at startup.
+//
+// Synchronous handlers run in order in the publisher's context, so the outbox messages they queue
+// commit or roll back with the change, and the first error fails the publish. Asynchronous handlers
+// run on their own goroutine once the unit of work the event was published in commits, or at once
+// outside of one; their errors and panics are reported and never reach the publisher or the other
+// handlers.
+type EventBus struct {
+	mu            sync.RWMutex
+	subscriptions map[string][]eventSubscription
+	reporters     []func(subscriber string, event domain.DomainEvent, err error)
+	running       sync.WaitGroup
+	timeout       time.Duration
+}
+
+func NewEventBus(config *infrastructure.Config) *EventBus {
+	return &EventBus{
+		subscriptions: map[string][]eventSubscription{},
+		timeout:       time.Duration(config.ContextTimeout) * time.Second,
+	}
+}
+
+// Subscribe registers a synchronous handler for the events of type E.
+func Subscribe[E domain.DomainEvent](bus *EventBus, subscriber string, handler func(ctx context.Context, event E) error) {
+	subscribe(bus, subscriber, false, handler)
+}
+
+// SubscribeAsync registers an asynchronous handler for the events of type E.
+func SubscribeAsync[E domain.DomainEvent](bus *EventBus, subscriber string, handler func(ctx context.Context, event E) error) {
+	subscribe(bus, subscriber, true, handler)
+}
+
+func subscribe[E domain.DomainEvent](bus *EventBus, subscriber string, async bool, handler func(ctx context.Context, event E) error) {
+	var name E
+	bus.add(name.EventName(), eventSubscription{
+		subscriber: subscriber,
+		async:      async,
+		handle: func(ctx context.Context, event domain.DomainEvent) error {
+			typed, ok := event.(E)
+			if !ok {
+				return fmt.Errorf("%s published as %T", event.EventName(), event)
+			}
+			return handler(ctx, typed)
+		},
+	})
+}
+
+// SubscribeAll registers an asynchronous handler for every event. Handlers of every event only
+// observe: they cannot fail a change.
+func (b *EventBus) SubscribeAll(subscriber string, handler domain.EventHandler) {
+	b.add(allEvents, eventSubscription{subscriber: subscriber, async: true, handle: handler})
+}
+
+func (b *EventBus) add(name string, subscription eventSubscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscriptions[name] = append(b.subscriptions[name], subscription)
+}
+
+// OnError registers a function told about every failed or panicked asynchronous handler. Failures
+// are logged whether or not there are reporters.
+func (b *EventBus) OnError(reporter func(subscriber string, event domain.DomainEvent, err error)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.reporters = append(b.reporters, reporter)
+}
+
+func (b *EventBus) handlers(event domain.DomainEvent, async bool) []eventSubscription {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	handlers := []eventSubscription{}
+	for _, name := range []string{event.EventName(), allEvents} {
+		for _, subscription := range b.subscriptions[name] {
+			if subscription.async == async {
+				handlers = append(handlers, subscription)
+			}
+		}
+	}
+	return handlers
+}
+
+func (b *EventBus) Publish(ctx context.Context, event domain.DomainEvent) error {
+	for _, subscription := range b.handlers(event, false) {
+		if err := handleEvent(ctx, subscription, event); err != nil {
+			return fmt.Errorf("%s handling %s: %w", subscription.subscriber, event.EventName(), err)
+		}
+	}
+	if pending, ok := ctx.Value(pendingEventsKey{}).(*[]domain.DomainEvent); ok {
+		*pending = append(*pending, event)
+		return nil
+	}
+	b.dispatch(event)
+	return nil
+}
+
+// dispatch starts the asynchronous handlers of the event.
+func (b *EventBus) dispatch(event domain.DomainEvent) {
+	for _, subscription := range b.handlers(event, true) {
+		b.running.Add(1)
+		go func() {
+			defer b.running.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+			defer cancel()
+			if err := handleEvent(ctx, subscription, event); err != nil {
+				b.report(subscription.subscriber, event, err)
+			}
+		}()
+	}
+}
+
+func (b *EventBus) report(subscriber string, event domain.DomainEvent, err error) {
+	log.Printf("event handler %s failed on %s %s: %v", subscriber, event.EventName(), event.AggregateID(), err)
+	b.mu.RLock()
+	reporters := b.reporters
+	b.mu.RUnlock()
+	for _, reporter := range reporters {
+		reporter(subscriber, event, err)
+	}
+}
+
+// Wait blocks until the asynchronous handlers started so far have returned.
+func (b *EventBus) Wait() {
+	b.running.Wait()
+}
+
+// handleEvent runs one handler, turning a panic into an error.
+func handleEvent(ctx context.Context, subscription eventSubscription, event domain.DomainEvent) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v\n%s", recovered, debug.Stack())
+		}
+	}()
+	return subscription.handle(ctx, event)
+}
+
+type pendingEventsKey struct{}
+
+// eventUnitOfWork holds back the asynchronous handlers of the events published in a unit of work
+// until it commits.
+type eventUnitOfWork struct {
+	unitOfWork domain.UnitOfWorkInterface
+	bus        *EventBus
+}
+
+// AfterCommit wraps the unit of work so asynchronous handlers only see the events of units of work
+// that succeed. A transaction that is retried dispatches the events of its last attempt.
+func (b *EventBus) AfterCommit(unitOfWork domain.UnitOfWorkInterface) domain.UnitOfWorkInterface {
+	return &eventUnitOfWork{unitOfWork: unitOfWork, bus: b}
+}
+
+func (u *eventUnitOfWork) Do(fn func(ctx context.Context) error) error {
+	var events []domain.DomainEvent
+	err := u.unitOfWork.Do(func(ctx context.Context) error {
+		events = nil
+		return fn(context.WithValue(ctx, pendingEventsKey{}, &events))
+	})
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		u.bus.dispatch(event)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package usecases
+
+import (
+	"errors"
+	domain "loan-tracker/Domain"
+	utils "loan-tracker/Utils"
+	"time"
+)
+
+// InboxUseCase serves the in-app notification inbox. Every method is scoped to the signed-in user.
+type InboxUseCase struct {
+	InboxRepo domain.InboxRepositoryInterface
+}
+
+func NewInboxUseCase(inboxRepo domain.InboxRepositoryInterface) *InboxUseCase {
+	return &InboxUseCase{
+		InboxRepo: inboxRepo,
+	}
+}
+
+// GetNotifications pages through the user's notifications, newest first, with their unread count.
+func (iu *InboxUseCase) GetNotifications(user_id string, unread_only bool, pageNo, pageSize string) (domain.InboxPage, error) {
+	pageS, pageN, err := utils.PagePaginationValidator(pageSize, pageNo)
+	if err != nil {
+		return domain.InboxPage{}, err
+	}
+	notifications, err := iu.InboxRepo.FindByUser(user_id, unread_only, pageN, pageS)
+	if err != nil {
+		return domain.InboxPage{}, errors.New("error getting notifications")
+	}
+	unread, err := iu.InboxRepo.CountUnread(user_id)
+	if err != nil {
+		return domain.InboxPage{}, errors.New("error counting unread notifications")
+	}
+	return domain.InboxPage{Notifications: notifications, Unread: unread}, nil
+}
+
+func (iu *InboxUseCase) CountUnread(user_id string) (int64, error) {
+	unread, err := iu.InboxRepo.CountUnread(user_id)
+	if err != nil {
+		return 0, errors.New("error counting unread notifications")
+	}
+	return unread, nil
+}
+
+func (iu *InboxUseCase) MarkRead(id string, user_id string) error {
+	err := iu.InboxRepo.MarkRead(id, user_id, time.Now())
+	if err != nil {
+		return errors.New("notification not found")
+	}
+	return nil
+}
+
+// MarkAllRead marks every unread notification of the user as read and returns how many there were.
+func (iu *InboxUseCase) MarkAllRead(user_id string) (int64, error) {
+	count, err := iu.InboxRepo.MarkAllRead(user_id, time.Now())
+	if err != nil {
+		return 0, errors.New("error updating notifications")
+	}
+	return count, nil
+}
@@ -1,10 +1,21 @@
 package usecases
 
 import (
+	"context"
 	"errors"
 	domain "loan-tracker/Domain"
 	infrastructure "loan-tracker/Infrastructure"
+	"math"
+	"strings"
 	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Repayment terms offered, in months.
+const (
+	defaultTermMonths = 12
+	maxTermMonths     = 60
 )
 
 type LoanUseCase struct {
@@ -12,33 +23,65 @@ type LoanUseCase struct {
 	UserRepo domain.UserRepositoryInterface
 	PassService infrastructure.PasswordService
 	Config *infrastructure.Config
+	UnitOfWork domain.UnitOfWorkInterface
+	Events domain.EventBusInterface
 }
 
 
-func NewLoanUseCase(loanRepo domain.LoanRepositoryInterface, passwordService infrastructure.PasswordService, config *infrastructure.Config, userRepo domain.UserRepositoryInterface) *LoanUseCase {
+func NewLoanUseCase(loanRepo domain.LoanRepositoryInterface, passwordService infrastructure.PasswordService, config *infrastructure.Config, userRepo domain.UserRepositoryInterface, unitOfWork domain.UnitOfWorkInterface, events domain.EventBusInterface) *LoanUseCase {
 	return &LoanUseCase{
 		LoanRepo: loanRepo,
 		UserRepo: userRepo,
 		PassService: passwordService,
 		Config: config,
+		UnitOfWork: unitOfWork,
+		Events: events,
 	}
 }
 
 
 func (lu *LoanUseCase) CreateLoan(loan domain.Loan, user_id string) error {
-	loan.LoanStatus = "pending"
-	loan.Created_at = time.Now()
+	loan = domain.Loan{
+		Amount: loan.Amount,
+		UserId: loan.UserId,
+		Product: loan.Product,
+		TermMonths: loan.TermMonths,
+		LoanStatus: domain.LoanPending,
+		Created_at: time.Now(),
+	}
 	if loan.UserId.Hex() != user_id {
 		return errors.New("Unauthorized")
 	}
 	if loan.Amount < 1 {
 		return errors.New("Invalid amount")
 	}
-	err := lu.LoanRepo.CreateLoan(loan)
+	if loan.Product == ""{
+		loan.Product = lu.Config.LoanProducts[0]
+	}
+	if !lu.Config.IsLoanProduct(loan.Product){
+		return errors.New("unknown loan product")
+	}
+	if loan.TermMonths == 0{
+		loan.TermMonths = defaultTermMonths
+	}
+	if loan.TermMonths < 1 || loan.TermMonths > maxTermMonths{
+		return errors.New("term must be between 1 and 60 months")
+	}
+	user, err := lu.UserRepo.FindUserByID(user_id)
+	if err != nil {
+		return errors.New("user not found")
+	}
+	loan.ID = primitive.NewObjectID()
+	loan.ReferenceNumber, err = infrastructure.GenerateLoanReference()
 	if err != nil {
-		return err
+		return errors.New("error creating loan reference")
 	}
-	return nil
+	return lu.UnitOfWork.Do(func(ctx context.Context) error {
+		if err := lu.LoanRepo.CreateLoanWithContext(ctx, loan); err != nil {
+			return err
+		}
+		return lu.Events.Publish(ctx, domain.LoanSubmitted{Loan: loan, Borrower: user})
+	})
 }
 
 
@@ -55,17 +98,173 @@ func (lu *LoanUseCase) CheckLoanStatus(id string, user_id string) (string, error
 
 
 func (lu *LoanUseCase) GetAllLoans(status string, order string, user_id string) ([]domain.Loan, error){
-	user, err := lu.UserRepo.FindUserByID(user_id)
-	if err != nil{
-		return nil, errors.New("user not found")
-	}
-	if user.Role != "admin"{
-		return nil, errors.New("unauthorized: Only admin can access this resource")
-	}
 	loans, err := lu.LoanRepo.GetAllLoans(status, order)
 	if err != nil{
 		return nil, errors.New("can not retrieve loans")
 	}
 	
 	return loans, nil
-}
\ No newline at end of file
+}
+
+
+// findPendingLoan loads a loan awaiting a decision together with its borrower.
+func (lu *LoanUseCase) findPendingLoan(id string) (domain.Loan, domain.User, error){
+	loan, err := lu.LoanRepo.FindLoanByID(id)
+	if err != nil{
+		return domain.Loan{}, domain.User{}, errors.New("loan not found")
+	}
+	if loan.LoanStatus != domain.LoanPending{
+		return domain.Loan{}, domain.User{}, errors.New("loan is not pending")
+	}
+	borrower, err := lu.UserRepo.FindUserByID(loan.UserId.Hex())
+	if err != nil{
+		return domain.Loan{}, domain.User{}, errors.New("borrower not found")
+	}
+	return loan, borrower, nil
+}
+
+
+// saveLoan stores the loan, publishes the change of its status from before and records the
+// decision in the audit log. It returns domain.ErrLoanChanged when another request changed the
+// loan's status since it was read.
+func (lu *LoanUseCase) saveLoan(loan domain.Loan, before domain.Loan, borrower domain.User, action string, user_id string, client domain.ClientInfo) error{
+	return lu.UnitOfWork.Do(func(ctx context.Context) error {
+		err := lu.LoanRepo.UpdateLoanWithContext(ctx, loan, before.LoanStatus)
+		if errors.Is(err, domain.ErrLoanChanged){
+			return err
+		}
+		if err != nil{
+			return errors.New("error updating loan")
+		}
+		err = lu.Events.Publish(ctx, domain.LoanStatusChanged{
+			Loan:      loan,
+			Borrower:  borrower,
+			Previous:  before.LoanStatus,
+			ChangedBy: user_id,
+		})
+		if err != nil{
+			return errors.New("error queueing loan notification")
+		}
+		changes := auditDiff(loanSnapshot(before), loanSnapshot(loan))
+		if err := recordAction(ctx, lu.Events, newAuditRecord(domain.AuditAdmin, action, user_id, "loan", loan.ID.Hex(), client, changes)); err != nil{
+			return errors.New("error recording audit action")
+		}
+		return nil
+	})
+}
+
+
+func (lu *LoanUseCase) ApproveLoan(id string, user_id string, client domain.ClientInfo) error{
+	loan, borrower, err := lu.findPendingLoan(id)
+	if err != nil{
+		return err
+	}
+	before := loan
+	loan.LoanStatus = domain.LoanApproved
+	decided := time.Now()
+	loan.DecidedAt = &decided
+	loan.DecidedBy = user_id
+	return lu.saveLoan(loan, before, borrower, domain.AuditLoanApproved, user_id, client)
+}
+
+
+func (lu *LoanUseCase) RejectLoan(id string, reason string, user_id string, client domain.ClientInfo) error{
+	loan, borrower, err := lu.findPendingLoan(id)
+	if err != nil{
+		return err
+	}
+	before := loan
+	loan.LoanStatus = domain.LoanRejected
+	decided := time.Now()
+	loan.DecidedAt = &decided
+	loan.DecidedBy = user_id
+	loan.RejectionReason = strings.TrimSpace(reason)
+	return lu.saveLoan(loan, before, borrower, domain.AuditLoanRejected, user_id, client)
+}
+
+
+// DisburseLoan pays out an approved loan and sets up its repayment schedule.
+func (lu *LoanUseCase) DisburseLoan(id string, user_id string, client domain.ClientInfo) error{
+	loan, err := lu.LoanRepo.FindLoanByID(id)
+	if err != nil{
+		return errors.New("loan not found")
+	}
+	if loan.LoanStatus != domain.LoanApproved{
+		return errors.New("only approved loans can be disbursed")
+	}
+	borrower, err := lu.UserRepo.FindUserByID(loan.UserId.Hex())
+	if err != nil{
+		return errors.New("borrower not found")
+	}
+	before := loan
+	if loan.ReferenceNumber == ""{
+		loan.ReferenceNumber, err = infrastructure.GenerateLoanReference()
+		if err != nil{
+			return errors.New("error creating loan reference")
+		}
+	}
+	loan.LoanStatus = domain.LoanDisbursed
+	disbursed := time.Now()
+	loan.DisbursedAt = &disbursed
+	loan.OutstandingBalance = loan.Amount
+	loan.Installments = installmentSchedule(loan.Amount, loan.TermMonths, disbursed)
+	return lu.saveLoan(loan, before, borrower, domain.AuditLoanDisbursed, user_id, client)
+}
+
+
+// loanReference is the reference borrowers pay with: the loan's reference number, or its id for
+// loans created before reference numbers existed.
+func loanReference(loan domain.Loan) string{
+	if loan.ReferenceNumber != ""{
+		return loan.ReferenceNumber
+	}
+	return loan.ID.Hex()
+}
+
+
+// addMonths moves a date by whole months, keeping it in the target month: a loan disbursed on
+// January 31st falls due on the last day of February, not in March.
+func addMonths(t time.Time, months int) time.Time{
+	first := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, months, 0)
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(t.Day(), last)-1)
+}
+
+
+// installmentSchedule splits the amount into equal monthly installments, the first due a month after
+// disbursement. The last installment absorbs the rounding to cents.
+func installmentSchedule(amount float64, months int, disbursed time.Time) []domain.Installment{
+	if months < 1{
+		months = 1
+	}
+	cents := int64(math.Round(amount * 100))
+	share := cents / int64(months)
+	start := startOfDay(disbursed)
+	installments := make([]domain.Installment, months)
+	for i := range installments{
+		due := share
+		if i == months-1{
+			due = cents - share*int64(months-1)
+		}
+		installments[i] = domain.Installment{
+			Number:  i + 1,
+			DueDate: addMonths(start, i+1),
+			Amount:  float64(due) / 100,
+		}
+	}
+	return installments
+}
+
+func startOfDay(t time.Time) time.Time{
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+
+// timeValue is the time a loan milestone was reached, or the zero time when it was not.
+func timeValue(t *time.Time) time.Time{
+	if t == nil{
+		return time.Time{}
+	}
+	return *t
+}
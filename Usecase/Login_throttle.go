@@ -0,0 +1,96 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	domain "loan-tracker/Domain"
+	"log"
+	"math"
+	"strings"
+	"time"
+)
+
+// Failed attempts allowed before each further attempt on the same key has to wait, and the
+// bounds of that progressively doubling wait.
+const (
+	loginFreeAttempts = 3
+	loginBaseDelay    = time.Second
+	loginMaxDelay     = 30 * time.Second
+)
+
+// Returned for both an unknown email and a wrong password so Login does not reveal which accounts exist.
+var errInvalidCredentials = errors.New("invalid credentials")
+
+func accountAttemptKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipAttemptKey(ip string) string {
+	return "ip:" + ip
+}
+
+func loginDelay(failures int) time.Duration {
+	if failures < loginFreeAttempts {
+		return 0
+	}
+	delay := loginBaseDelay * time.Duration(math.Pow(2, float64(failures-loginFreeAttempts)))
+	if delay > loginMaxDelay || delay <= 0 {
+		return loginMaxDelay
+	}
+	return delay
+}
+
+// checkLoginAllowed refuses the attempt while any of the keys is locked or still inside its progressive delay.
+func (uc *UserUseCase) checkLoginAllowed(keys ...string) error {
+	now := time.Now()
+	for _, key := range keys {
+		attempt := uc.LoginAttempts.Get(key)
+		if attempt.LockedUntil.After(now) {
+			return errors.New("too many failed login attempts: account temporarily locked, try again later")
+		}
+		retryAt := attempt.LastFailureAt.Add(loginDelay(attempt.Failures))
+		if retryAt.After(now) {
+			return fmt.Errorf("too many failed login attempts: try again in %d seconds", int(math.Ceil(retryAt.Sub(now).Seconds())))
+		}
+	}
+	return nil
+}
+
+// recordLoginFailure counts a failed attempt against the account and the client IP and locks them once
+// they reach their limits. The owner of a known account is notified when it gets locked. The failure
+// and any lock are recorded in the audit log.
+func (uc *UserUseCase) recordLoginFailure(email string, client domain.ClientInfo, user *domain.User) {
+	now := time.Now()
+	lockout := time.Duration(uc.Config.LoginLockoutMinutes) * time.Minute
+	// The email is only recorded when it matches no account; otherwise the user id identifies it,
+	// and erasing the user's personal data does not leave it behind in the audit log.
+	user_id := ""
+	attempted := []domain.AuditChange{{Field: "email", After: strings.ToLower(strings.TrimSpace(email))}}
+	if user != nil {
+		user_id = user.ID.Hex()
+		attempted = nil
+	}
+	recordAction(context.Background(), uc.Events, newAuditRecord(domain.AuditSecurity, domain.AuditLoginFailed, user_id, "user", user_id, client, attempted))
+
+	accountKey := accountAttemptKey(email)
+	attempt := uc.LoginAttempts.RecordFailure(accountKey, now)
+	if attempt.Failures >= uc.Config.LoginMaxFailures && !attempt.LockedUntil.After(now) {
+		until := now.Add(lockout)
+		uc.LoginAttempts.Lock(accountKey, until)
+		recordAction(context.Background(), uc.Events, newAuditRecord(domain.AuditSecurity, domain.AuditAccountLocked, "", "user", user_id, client, []domain.AuditChange{
+			{Field: "locked_until", After: auditTime(until)},
+		}))
+		if user != nil {
+			if err := uc.Events.Publish(context.Background(), domain.AccountLocked{User: *user, Until: until}); err != nil {
+				log.Println("error queueing account locked notification:", err)
+			}
+		}
+	}
+
+	ipKey := ipAttemptKey(client.IP)
+	attempt = uc.LoginAttempts.RecordFailure(ipKey, now)
+	if attempt.Failures >= uc.Config.LoginIPMaxFailures && !attempt.LockedUntil.After(now) {
+		uc.LoginAttempts.Lock(ipKey, now.Add(lockout))
+	}
+}
@@ -0,0 +1,39 @@
+package usecases
+
+import (
+	"context"
+	"expvar"
+	domain "loan-tracker/Domain"
+)
+
+// The counters are published once per process: expvar refuses to publish a name twice.
+var (
+	eventCounts   = expvar.NewMap("domain_events")
+	failureCounts = expvar.NewMap("event_failures")
+)
+
+// MetricsSubscriber counts committed domain events by name, and failed asynchronous handlers by
+// subscriber. The counters are published with expvar as "domain_events" and "event_failures".
+type MetricsSubscriber struct {
+	Events   *expvar.Map
+	Failures *expvar.Map
+}
+
+func NewMetricsSubscriber() *MetricsSubscriber {
+	return &MetricsSubscriber{
+		Events:   eventCounts,
+		Failures: failureCounts,
+	}
+}
+
+func (ms *MetricsSubscriber) Register(bus *EventBus) {
+	bus.SubscribeAll("metrics", ms.count)
+	bus.OnError(func(subscriber string, event domain.DomainEvent, err error) {
+		ms.Failures.Add(subscriber, 1)
+	})
+}
+
+func (ms *MetricsSubscriber) count(ctx context.Context, event domain.DomainEvent) error {
+	ms.Events.Add(event.EventName(), 1)
+	return nil
+}
@@ -0,0 +1,144 @@
+package usecases
+
+import (
+	"context"
+	domain "loan-tracker/Domain"
+	infrastructure "loan-tracker/Infrastructure"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// inboxTemplates are the notifications that are also kept in the user's in-app inbox.
+var inboxTemplates = []string{
+	domain.EmailLoanSubmitted,
+	domain.EmailLoanApproved,
+	domain.EmailLoanRejected,
+	domain.EmailLoanDisbursed,
+	domain.EmailPaymentReceived,
+	domain.EmailPaymentOverdue,
+}
+
+// Notifier turns a notification into one outbox message per channel the user chose. Every message
+// of a notification shares its NotificationID, so their delivery logs can be read together.
+type Notifier struct {
+	Outbox domain.OutboxRepositoryInterface
+	Inbox  domain.InboxRepositoryInterface
+	Emails domain.EmailTemplatesInterface
+	Config *infrastructure.Config
+}
+
+func NewNotifier(outbox domain.OutboxRepositoryInterface, inbox domain.InboxRepositoryInterface, emails domain.EmailTemplatesInterface, config *infrastructure.Config) *Notifier {
+	return &Notifier{
+		Outbox: outbox,
+		Inbox:  inbox,
+		Emails: emails,
+		Config: config,
+	}
+}
+
+// notificationChannels returns the channels the user is notified on, email unless they chose otherwise.
+func notificationChannels(user domain.User) []string {
+	if len(user.NotificationPreferences.Channels) == 0 {
+		return []string{domain.ChannelEmail}
+	}
+	return user.NotificationPreferences.Channels
+}
+
+// wantsNotification reports whether the user accepts notifications of the category. Security
+// notifications cannot be opted out of.
+func wantsNotification(user domain.User, category string) bool {
+	return category == domain.NotificationSecurity || !slices.Contains(user.NotificationPreferences.OptOuts, category)
+}
+
+// Notify queues the notification rendered from the named template. SMS and push carry the summary
+// (or the subject) and link of the email. When none of the chosen channels can reach the user, such
+// as SMS without a contact number, the notification falls back to email. Loan lifecycle
+// notifications are also written to the in-app inbox, even when the user opted out of their
+// delivery.
+func (n *Notifier) Notify(ctx context.Context, user domain.User, category string, name string, data domain.EmailData) error {
+	inbox := slices.Contains(inboxTemplates, name)
+	wanted := wantsNotification(user, category)
+	if !inbox && !wanted {
+		return nil
+	}
+	email, err := renderEmail(n.Emails, user.Email, user.Locale, name, data)
+	if err != nil {
+		return err
+	}
+	short := email.Summary
+	if short == "" {
+		short = email.Subject
+	}
+	if inbox {
+		err := n.Inbox.Create(ctx, domain.InboxNotification{
+			UserID:     user.ID,
+			Type:       name,
+			Title:      email.Subject,
+			Body:       short,
+			Link:       data.Link,
+			Reference:  data.Reference,
+			Created_At: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if !wanted {
+		return nil
+	}
+	notification_id := primitive.NewObjectID()
+	message := func(channel string) domain.OutboxMessage {
+		return domain.OutboxMessage{
+			NotificationID: notification_id,
+			UserID:         user.ID,
+			Template:       name,
+			Channel:        channel,
+		}
+	}
+
+	messages := []domain.OutboxMessage{}
+	for _, channel := range notificationChannels(user) {
+		switch channel {
+		case domain.ChannelEmail:
+			outbox := message(channel)
+			outbox.Message = email
+			messages = append(messages, outbox)
+		case domain.ChannelSMS:
+			if user.Contact == "" {
+				continue
+			}
+			body := short
+			if data.Link != "" {
+				body += " " + data.Link
+			}
+			outbox := message(channel)
+			outbox.SMS = &domain.SMSMessage{To: user.Contact, Body: body}
+			messages = append(messages, outbox)
+		case domain.ChannelPush:
+			for _, subscription := range user.PushSubscriptions {
+				outbox := message(channel)
+				outbox.Push = &domain.PushMessage{
+					Subscription: subscription,
+					Title:        n.Config.BrandName,
+					Body:         short,
+					URL:          data.Link,
+				}
+				messages = append(messages, outbox)
+			}
+		}
+	}
+	if len(messages) == 0 {
+		outbox := message(domain.ChannelEmail)
+		outbox.Message = email
+		messages = append(messages, outbox)
+	}
+
+	for _, outbox := range messages {
+		if err := n.Outbox.EnqueueMessage(ctx, outbox); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	domain "loan-tracker/Domain"
+	infrastructure "loan-tracker/Infrastructure"
+	"log"
+	"math/rand"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// outboxLease is how long a worker holds a message while sending it before another worker may take it over.
+const outboxLease = 2 * time.Minute
+
+// OutboxWorker delivers the messages queued in the outbox on their channel. Failed sends are retried
+// with exponential backoff, and a message that fails OUTBOX_MAX_ATTEMPTS times, or is rejected for
+// good, is dead-lettered for an admin to retry.
+type OutboxWorker struct {
+	Outbox    domain.OutboxRepositoryInterface
+	Mailer    domain.Mailer
+	SMS       domain.SMSSender
+	Push      domain.PushSender
+	Webhooks  domain.WebhookSender
+	Endpoints domain.WebhookRepositoryInterface
+	Audit     domain.AuditRepositoryInterface
+	Config    *infrastructure.Config
+}
+
+func NewOutboxWorker(outbox domain.OutboxRepositoryInterface, mailer domain.Mailer, sms domain.SMSSender, push domain.PushSender, webhooks domain.WebhookSender, endpoints domain.WebhookRepositoryInterface, audit domain.AuditRepositoryInterface, config *infrastructure.Config) *OutboxWorker {
+	return &OutboxWorker{
+		Outbox:    outbox,
+		Mailer:    mailer,
+		SMS:       sms,
+		Push:      push,
+		Webhooks:  webhooks,
+		Endpoints: endpoints,
+		Audit:     audit,
+		Config:    config,
+	}
+}
+
+// Run delivers due messages every OUTBOX_POLL_SECONDS until ctx is cancelled.
+func (ow *OutboxWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(ow.Config.OutboxPollSeconds) * time.Second)
+	defer ticker.Stop()
+	for {
+		for {
+			message, err := ow.Outbox.ClaimDue(time.Now(), outboxLease)
+			if err != nil {
+				if !errors.Is(err, mongo.ErrNoDocuments) {
+					log.Println("error claiming outbox message:", err)
+				}
+				break
+			}
+			ow.deliver(message)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (ow *OutboxWorker) deliver(message domain.OutboxMessage) {
+	err := ow.send(message)
+	if err == nil {
+		if err := ow.Outbox.MarkSent(message, time.Now()); err != nil {
+			log.Println("error marking outbox message sent:", err)
+		}
+		return
+	}
+
+	message.Attempts++
+	message.LastError = err.Error()
+	if message.Attempts >= ow.Config.OutboxMaxAttempts || errors.Is(err, domain.ErrDeliveryRejected) {
+		log.Printf("outbox message %s dead after %d attempts: %v", message.ID.Hex(), message.Attempts, err)
+		message.Status = domain.OutboxDead
+	} else {
+		message.Status = domain.OutboxPending
+		message.NextAttemptAt = time.Now().Add(ow.backoff(message.Attempts))
+	}
+	if err := ow.Outbox.MarkFailed(message); err != nil {
+		log.Println("error storing outbox failure:", err)
+	}
+}
+
+// send hands the message to the driver of its channel. Messages queued before channels existed are email.
+func (ow *OutboxWorker) send(message domain.OutboxMessage) error {
+	switch message.Channel {
+	case "", domain.ChannelEmail:
+		return ow.Mailer.Send(message.Message)
+	case domain.ChannelSMS:
+		if message.SMS == nil {
+			return fmt.Errorf("%w: sms message has no content", domain.ErrDeliveryRejected)
+		}
+		return ow.SMS.Send(*message.SMS)
+	case domain.ChannelPush:
+		if message.Push == nil {
+			return fmt.Errorf("%w: push message has no content", domain.ErrDeliveryRejected)
+		}
+		return ow.Push.Send(*message.Push)
+	case domain.ChannelWebhook:
+		return ow.sendWebhook(message)
+	case domain.ChannelAudit:
+		if message.Audit == nil {
+			return fmt.Errorf("%w: audit message has no record", domain.ErrDeliveryRejected)
+		}
+		_, err := ow.Audit.Append(*message.Audit)
+		return err
+	}
+	return fmt.Errorf("%w: unknown channel %q", domain.ErrDeliveryRejected, message.Channel)
+}
+
+// sendWebhook posts a webhook with the current URL and secret of its endpoint. Deliveries to
+// deleted or disabled endpoints are dead-lettered, so they can be replayed once it is back.
+func (ow *OutboxWorker) sendWebhook(message domain.OutboxMessage) error {
+	if message.Webhook == nil {
+		return fmt.Errorf("%w: webhook message has no content", domain.ErrDeliveryRejected)
+	}
+	endpoint, err := ow.Endpoints.FindByID(message.Webhook.EndpointID.Hex())
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return fmt.Errorf("%w: webhook endpoint was deleted", domain.ErrDeliveryRejected)
+	}
+	if err != nil {
+		return err
+	}
+	if !endpoint.Active {
+		return fmt.Errorf("%w: webhook endpoint is disabled", domain.ErrDeliveryRejected)
+	}
+	return ow.Webhooks.Send(endpoint, *message.Webhook)
+}
+
+// backoff doubles the wait after every failed attempt, up to OUTBOX_MAX_BACKOFF_MINUTES. Up to a
+// fifth of the wait is added at random so messages that failed together are not retried together.
+func (ow *OutboxWorker) backoff(attempts int) time.Duration {
+	base := time.Duration(ow.Config.OutboxBackoffSeconds) * time.Second
+	limit := time.Duration(ow.Config.OutboxMaxBackoffMinutes) * time.Minute
+	wait := base
+	for i := 1; i < attempts && wait < limit; i++ {
+		wait *= 2
+	}
+	if wait > limit {
+		wait = limit
+	}
+	return wait + time.Duration(rand.Int63n(int64(wait)/5+1))
+}
@@ -0,0 +1,92 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	domain "loan-tracker/Domain"
+	"log"
+	"time"
+)
+
+// setPassword replaces the user's password, refusing the current password and the last
+// PasswordHistorySize ones. The caller persists the user.
+func (uc *UserUseCase) setPassword(user *domain.User, password string) error {
+	if uc.PassService.ComparePassword(password, user.Password) {
+		return errors.New("new password must be different from the current one")
+	}
+	for _, previous := range user.PasswordHistory {
+		if uc.PassService.ComparePassword(password, previous) {
+			return errors.New("new password must not match a recently used password")
+		}
+	}
+	hashed, err := uc.PassService.HashPassword(password)
+	if err != nil {
+		return errors.New("error hashing password")
+	}
+
+	history := append([]string{user.Password}, user.PasswordHistory...)
+	if len(history) > uc.Config.PasswordHistorySize {
+		history = history[:uc.Config.PasswordHistorySize]
+	}
+	user.PasswordHistory = history
+	user.Password = hashed
+	user.PasswordChangedAt = time.Now()
+	return nil
+}
+
+// rehashPassword re-hashes a password that was just verified, after the configured bcrypt cost changed.
+func (uc *UserUseCase) rehashPassword(user *domain.User, password string) {
+	hashed, err := uc.PassService.HashPassword(password)
+	if err != nil {
+		log.Println("error rehashing password:", err)
+		return
+	}
+	user.Password = hashed
+	if err := uc.UserRepo.UpdateUser(*user); err != nil {
+		log.Println("error storing rehashed password:", err)
+	}
+}
+
+// ChangePassword lets a signed-in user replace their password. Every other session is signed out.
+func (uc *UserUseCase) ChangePassword(id string, session_id string, current_password string, new_password string, client domain.ClientInfo) error {
+	user, err := uc.UserRepo.FindUserByID(id)
+	if err != nil {
+		return errors.New("user not found")
+	}
+	if !uc.PassService.ComparePassword(current_password, user.Password) {
+		return errors.New("current password is incorrect")
+	}
+	if err := uc.PassService.ValidatePassword(new_password, user.User_Name, user.Email); err != nil {
+		return err
+	}
+	if err := uc.setPassword(&user, new_password); err != nil {
+		return err
+	}
+	err = uc.savePasswordChange(user, domain.AuditPasswordChanged, client)
+	if err != nil {
+		return err
+	}
+
+	err = uc.SessionRepo.RevokeUserSessions(id, session_id)
+	if err != nil {
+		return errors.New("error revoking sessions")
+	}
+	return nil
+}
+
+// savePasswordChange stores the user's new password, notifies them that it changed and records the
+// change in the audit log as action.
+func (uc *UserUseCase) savePasswordChange(user domain.User, action string, client domain.ClientInfo) error {
+	return uc.UnitOfWork.Do(func(ctx context.Context) error {
+		if err := uc.UserRepo.UpdateUserWithContext(ctx, user); err != nil {
+			return errors.New("error updating password")
+		}
+		if err := uc.Events.Publish(ctx, domain.PasswordChanged{User: user}); err != nil {
+			return errors.New("error queueing password changed notification")
+		}
+		if err := recordAction(ctx, uc.Events, newAuditRecord(domain.AuditSecurity, action, user.ID.Hex(), "user", user.ID.Hex(), client, nil)); err != nil {
+			return errors.New("error recording audit action")
+		}
+		return nil
+	})
+}
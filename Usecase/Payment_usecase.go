@@ -0,0 +1,349 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	domain "loan-tracker/Domain"
+	infrastructure "loan-tracker/Infrastructure"
+	utils "loan-tracker/Utils"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// postAttempts is how many times posting a payment is tried when the loan keeps changing under it.
+const postAttempts = 3
+
+// paymentRefusal explains why a payment cannot be posted to a loan. Received payments that are
+// refused go to the suspense queue with the explanation.
+type paymentRefusal struct {
+	reason string
+}
+
+func (pr paymentRefusal) Error() string {
+	return pr.reason
+}
+
+// PaymentUseCase receives payments from the payment providers, posts them to the loans they pay
+// and keeps the ones it cannot match in suspense for an admin to assign.
+type PaymentUseCase struct {
+	PaymentRepo domain.PaymentRepositoryInterface
+	LoanRepo    domain.LoanRepositoryInterface
+	UserRepo    domain.UserRepositoryInterface
+	UnitOfWork  domain.UnitOfWorkInterface
+	Events      domain.EventBusInterface
+	Providers   map[string]domain.PaymentProvider
+	Simulator   domain.PaymentSimulator
+	Config      *infrastructure.Config
+}
+
+// NewPaymentUseCase accepts notifications from the given providers. A provider that is also a
+// simulator can be driven with SimulatePayment.
+func NewPaymentUseCase(paymentRepo domain.PaymentRepositoryInterface, loanRepo domain.LoanRepositoryInterface, userRepo domain.UserRepositoryInterface, unitOfWork domain.UnitOfWorkInterface, events domain.EventBusInterface, providers []domain.PaymentProvider, config *infrastructure.Config) *PaymentUseCase {
+	pu := &PaymentUseCase{
+		PaymentRepo: paymentRepo,
+		LoanRepo:    loanRepo,
+		UserRepo:    userRepo,
+		UnitOfWork:  unitOfWork,
+		Events:      events,
+		Providers:   map[string]domain.PaymentProvider{},
+		Config:      config,
+	}
+	for _, provider := range providers {
+		pu.Providers[provider.Name()] = provider
+		if simulator, ok := provider.(domain.PaymentSimulator); ok {
+			pu.Simulator = simulator
+		}
+	}
+	return pu
+}
+
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// ReceivePayment verifies a provider's notification and reconciles the payment. A transaction the
+// provider sent before is not processed again; the payment stored for it is returned.
+func (pu *PaymentUseCase) ReceivePayment(provider string, header http.Header, body []byte) (domain.Payment, error) {
+	gateway, ok := pu.Providers[provider]
+	if !ok {
+		return domain.Payment{}, domain.ErrUnknownPaymentProvider
+	}
+	notification, err := gateway.Parse(header, body)
+	if err != nil {
+		return domain.Payment{}, err
+	}
+	notification.TransactionID = strings.TrimSpace(notification.TransactionID)
+	if notification.TransactionID == "" {
+		return domain.Payment{}, fmt.Errorf("%w: transaction id is required", domain.ErrInvalidPaymentNotification)
+	}
+	if toCents(notification.Amount) <= 0 || math.IsInf(notification.Amount, 0) {
+		return domain.Payment{}, fmt.Errorf("%w: amount must be positive", domain.ErrInvalidPaymentNotification)
+	}
+
+	payment, err := pu.PaymentRepo.Create(domain.Payment{
+		Provider:      provider,
+		TransactionID: notification.TransactionID,
+		Reference:     infrastructure.NormalizeLoanReference(notification.Reference),
+		Amount:        float64(toCents(notification.Amount)) / 100,
+		Currency:      strings.ToUpper(strings.TrimSpace(notification.Currency)),
+		PaidAt:        notification.PaidAt,
+		Status:        domain.PaymentStatusReceived,
+		Received_At:   time.Now(),
+	})
+	if errors.Is(err, domain.ErrDuplicatePayment) {
+		payment, err = pu.PaymentRepo.FindByTransaction(provider, notification.TransactionID)
+		if err != nil {
+			return domain.Payment{}, err
+		}
+		// A payment still marked received was stored but not reconciled, so it is tried again.
+		if payment.Status != domain.PaymentStatusReceived {
+			return payment, nil
+		}
+	} else if err != nil {
+		return domain.Payment{}, err
+	}
+	return pu.reconcile(payment)
+}
+
+// PostPayment records a payment that did not come from a provider, such as a bank statement line,
+// and posts it to the chosen loan. A transaction recorded before is not posted again: the stored
+// payment is returned with domain.ErrDuplicatePayment. A payment the loan cannot take goes to the
+// suspense queue.
+func (pu *PaymentUseCase) PostPayment(payment domain.Payment, loan_id string, assigned_by string) (domain.Payment, error) {
+	loan, err := pu.LoanRepo.FindLoanByID(loan_id)
+	if err != nil {
+		return domain.Payment{}, errors.New("loan not found")
+	}
+	payment.Status = domain.PaymentStatusReceived
+	payment.Received_At = time.Now()
+	stored, err := pu.PaymentRepo.Create(payment)
+	if errors.Is(err, domain.ErrDuplicatePayment) {
+		stored, err = pu.PaymentRepo.FindByTransaction(payment.Provider, payment.TransactionID)
+		if err != nil {
+			return domain.Payment{}, err
+		}
+		if stored.Status != domain.PaymentStatusReceived {
+			return stored, domain.ErrDuplicatePayment
+		}
+	} else if err != nil {
+		return domain.Payment{}, err
+	}
+	posted, err := pu.post(stored, loan, assigned_by, nil)
+	var refusal paymentRefusal
+	if errors.As(err, &refusal) {
+		return pu.suspend(stored, refusal.reason)
+	}
+	return posted, err
+}
+
+// reconcile matches the payment to a loan by its reference and posts it, or puts it in suspense
+// when no single loan matches or the loan cannot take it.
+func (pu *PaymentUseCase) reconcile(payment domain.Payment) (domain.Payment, error) {
+	if payment.Reference == "" {
+		return pu.suspend(payment, "payment has no reference")
+	}
+	loans, err := pu.LoanRepo.FindLoansByReference(payment.Reference)
+	if err != nil {
+		return payment, err
+	}
+	if len(loans) == 0 {
+		return pu.suspend(payment, "no loan matches the reference")
+	}
+	if len(loans) > 1 {
+		return pu.suspend(payment, "reference matches several loans")
+	}
+	posted, err := pu.post(payment, loans[0], "", nil)
+	var refusal paymentRefusal
+	if errors.As(err, &refusal) {
+		return pu.suspend(payment, refusal.reason)
+	}
+	return posted, err
+}
+
+func (pu *PaymentUseCase) suspend(payment domain.Payment, reason string) (domain.Payment, error) {
+	payment.Status = domain.PaymentStatusSuspense
+	payment.SuspenseReason = reason
+	if err := pu.PaymentRepo.UpdateWithContext(context.Background(), payment); err != nil {
+		return payment, err
+	}
+	return payment, nil
+}
+
+// postable refuses payments to loans that are not being repaid and payments larger than the balance.
+func postable(loan domain.Loan, amount float64) error {
+	if loan.LoanStatus != domain.LoanDisbursed {
+		return paymentRefusal{"loan is not being repaid"}
+	}
+	if toCents(amount) > toCents(loan.OutstandingBalance) {
+		return paymentRefusal{"payment exceeds the outstanding balance"}
+	}
+	return nil
+}
+
+// applyRepayment pays off the loan's installments in order and lowers its balance. A loan with
+// nothing left to pay is repaid.
+func applyRepayment(loan *domain.Loan, amount float64) {
+	remaining := toCents(amount)
+	for i := range loan.Installments {
+		installment := &loan.Installments[i]
+		if installment.Paid || remaining == 0 {
+			continue
+		}
+		paid := toCents(installment.PaidAmount)
+		share := min(toCents(installment.Amount)-paid, remaining)
+		remaining -= share
+		installment.PaidAmount = float64(paid+share) / 100
+		installment.Paid = paid+share >= toCents(installment.Amount)
+	}
+	balance := toCents(loan.OutstandingBalance) - toCents(amount)
+	if balance <= 0 {
+		balance = 0
+		loan.LoanStatus = domain.LoanRepaid
+	}
+	loan.OutstandingBalance = float64(balance) / 100
+}
+
+// post applies the payment to the loan, marks it posted and publishes PaymentPosted in one unit of
+// work, along with record when an admin action posts it. When another payment changed the loan
+// first, the loan is reloaded and the payment applied to its new state.
+func (pu *PaymentUseCase) post(payment domain.Payment, loan domain.Loan, assigned_by string, record *domain.AuditRecord) (domain.Payment, error) {
+	for attempt := 0; attempt < postAttempts; attempt++ {
+		posted := payment
+		posted.Status = domain.PaymentStatusPosted
+		posted.LoanID = loan.ID
+		posted.AssignedBy = assigned_by
+		posted.PostedAt = time.Now()
+		// An earlier attempt applied the payment but did not get to mark it posted.
+		if slices.Contains(loan.PaymentIDs, payment.ID) {
+			return posted, pu.UnitOfWork.Do(func(ctx context.Context) error {
+				if err := pu.PaymentRepo.UpdateWithContext(ctx, posted); err != nil {
+					return err
+				}
+				return pu.recordPosting(ctx, record)
+			})
+		}
+		if err := postable(loan, payment.Amount); err != nil {
+			return payment, err
+		}
+		borrower, err := pu.UserRepo.FindUserByID(loan.UserId.Hex())
+		if err != nil {
+			return payment, errors.New("borrower not found")
+		}
+
+		previous := loan.OutstandingBalance
+		applyRepayment(&loan, payment.Amount)
+		err = pu.UnitOfWork.Do(func(ctx context.Context) error {
+			if err := pu.LoanRepo.ApplyPaymentWithContext(ctx, loan, payment.ID, previous); err != nil {
+				return err
+			}
+			if err := pu.PaymentRepo.UpdateWithContext(ctx, posted); err != nil {
+				return err
+			}
+			if err := pu.Events.Publish(ctx, domain.PaymentPosted{Payment: posted, Loan: loan, Borrower: borrower}); err != nil {
+				return err
+			}
+			return pu.recordPosting(ctx, record)
+		})
+		if errors.Is(err, domain.ErrLoanChanged) {
+			loan, err = pu.LoanRepo.FindLoanByID(loan.ID.Hex())
+			if err != nil {
+				return payment, errors.New("loan not found")
+			}
+			continue
+		}
+		if err != nil {
+			return payment, err
+		}
+		return posted, nil
+	}
+	return payment, errors.New("loan is being updated, try again")
+}
+
+// recordPosting queues the audit record of the admin action that posted a payment, if any.
+func (pu *PaymentUseCase) recordPosting(ctx context.Context, record *domain.AuditRecord) error {
+	if record == nil {
+		return nil
+	}
+	if err := recordAction(ctx, pu.Events, *record); err != nil {
+		return errors.New("error recording audit action")
+	}
+	return nil
+}
+
+// GetPayments lists payments by status, newest first. It lists the suspense queue by default.
+func (pu *PaymentUseCase) GetPayments(status string, pageNo, pageSize string, user_id string) ([]domain.Payment, error) {
+	pageS, pageN, err := utils.PagePaginationValidator(pageSize, pageNo)
+	if err != nil {
+		return nil, err
+	}
+	if status == "" {
+		status = domain.PaymentStatusSuspense
+	}
+	if !slices.Contains([]string{domain.PaymentStatusReceived, domain.PaymentStatusPosted, domain.PaymentStatusSuspense}, status) {
+		return nil, errors.New("invalid status")
+	}
+	payments, err := pu.PaymentRepo.FindByStatus(status, pageN, pageS)
+	if err != nil {
+		return nil, errors.New("error getting payments")
+	}
+	return payments, nil
+}
+
+func (pu *PaymentUseCase) GetPayment(id string, user_id string) (domain.Payment, error) {
+	payment, err := pu.PaymentRepo.FindByID(id)
+	if err != nil {
+		return domain.Payment{}, errors.New("payment not found")
+	}
+	return payment, nil
+}
+
+// AssignPayment posts a payment from the suspense queue to the loan an admin picked.
+func (pu *PaymentUseCase) AssignPayment(id string, loan_id string, user_id string, client domain.ClientInfo) (domain.Payment, error) {
+	payment, err := pu.GetPayment(id, user_id)
+	if err != nil {
+		return domain.Payment{}, err
+	}
+	if payment.Status != domain.PaymentStatusSuspense {
+		return domain.Payment{}, errors.New("only payments in suspense can be assigned")
+	}
+	loan, err := pu.LoanRepo.FindLoanByID(loan_id)
+	if err != nil {
+		return domain.Payment{}, errors.New("loan not found")
+	}
+	record := newAuditRecord(domain.AuditAdmin, domain.AuditPaymentAssigned, user_id, "payment", payment.ID.Hex(), client, []domain.AuditChange{
+		{Field: "loan_id", After: loan.ID.Hex()},
+		{Field: "status", Before: payment.Status, After: domain.PaymentStatusPosted},
+	})
+	posted, err := pu.post(payment, loan, user_id, &record)
+	if err != nil {
+		return domain.Payment{}, err
+	}
+	return posted, nil
+}
+
+// SimulatePayment makes the local simulator send a signed notification for the payment and
+// receives it like any other.
+func (pu *PaymentUseCase) SimulatePayment(request domain.SimulatePaymentRequest, user_id string) (domain.Payment, error) {
+	if pu.Simulator == nil {
+		return domain.Payment{}, errors.New("payment simulator is disabled")
+	}
+	if request.TransactionID == "" {
+		request.TransactionID = "sim_" + primitive.NewObjectID().Hex()
+	}
+	header, body, err := pu.Simulator.Simulate(domain.PaymentNotification{
+		TransactionID: request.TransactionID,
+		Reference:     request.Reference,
+		Amount:        request.Amount,
+		PaidAt:        time.Now().UTC(),
+	})
+	if err != nil {
+		return domain.Payment{}, err
+	}
+	return pu.ReceivePayment(pu.Simulator.Name(), header, body)
+}
@@ -0,0 +1,303 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	domain "loan-tracker/Domain"
+	infrastructure "loan-tracker/Infrastructure"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func installments(amounts ...float64) []domain.Installment {
+	schedule := []domain.Installment{}
+	for i, amount := range amounts {
+		schedule = append(schedule, domain.Installment{Number: i + 1, Amount: amount})
+	}
+	return schedule
+}
+
+func TestApplyRepayment(t *testing.T) {
+	tests := []struct {
+		name         string
+		installments []domain.Installment
+		balance      float64
+		amount       float64
+		paidAmounts  []float64
+		paid         []bool
+		wantBalance  float64
+		wantStatus   string
+	}{
+		{
+			name:         "exact installment",
+			installments: installments(33.33, 33.33, 33.34),
+			balance:      100,
+			amount:       33.33,
+			paidAmounts:  []float64{33.33, 0, 0},
+			paid:         []bool{true, false, false},
+			wantBalance:  66.67,
+			wantStatus:   domain.LoanDisbursed,
+		},
+		{
+			name:         "split across installments",
+			installments: installments(33.33, 33.33, 33.34),
+			balance:      100,
+			amount:       50,
+			paidAmounts:  []float64{33.33, 16.67, 0},
+			paid:         []bool{true, false, false},
+			wantBalance:  50,
+			wantStatus:   domain.LoanDisbursed,
+		},
+		{
+			name:         "tops up a partly paid installment",
+			installments: []domain.Installment{{Number: 1, Amount: 33.33, PaidAmount: 33.33, Paid: true}, {Number: 2, Amount: 33.33, PaidAmount: 16.67}, {Number: 3, Amount: 33.34}},
+			balance:      50,
+			amount:       16.66,
+			paidAmounts:  []float64{33.33, 33.33, 0},
+			paid:         []bool{true, true, false},
+			wantBalance:  33.34,
+			wantStatus:   domain.LoanDisbursed,
+		},
+		{
+			name:         "amounts that do not add up in floating point",
+			installments: installments(0.1, 0.2, 0.3),
+			balance:      0.6,
+			amount:       0.3,
+			paidAmounts:  []float64{0.1, 0.2, 0},
+			paid:         []bool{true, true, false},
+			wantBalance:  0.3,
+			wantStatus:   domain.LoanDisbursed,
+		},
+		{
+			name:         "last cent repays the loan",
+			installments: []domain.Installment{{Number: 1, Amount: 33.33, PaidAmount: 33.33, Paid: true}, {Number: 2, Amount: 33.33, PaidAmount: 33.33, Paid: true}, {Number: 3, Amount: 33.34, PaidAmount: 33.33}},
+			balance:      0.01,
+			amount:       0.01,
+			paidAmounts:  []float64{33.33, 33.33, 33.34},
+			paid:         []bool{true, true, true},
+			wantBalance:  0,
+			wantStatus:   domain.LoanRepaid,
+		},
+		{
+			name:         "full repayment at once",
+			installments: installments(33.33, 33.33, 33.34),
+			balance:      100,
+			amount:       100,
+			paidAmounts:  []float64{33.33, 33.33, 33.34},
+			paid:         []bool{true, true, true},
+			wantBalance:  0,
+			wantStatus:   domain.LoanRepaid,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			loan := domain.Loan{LoanStatus: domain.LoanDisbursed, OutstandingBalance: test.balance, Installments: test.installments}
+			applyRepayment(&loan, test.amount)
+			for i, installment := range loan.Installments {
+				if toCents(installment.PaidAmount) != toCents(test.paidAmounts[i]) || installment.Paid != test.paid[i] {
+					t.Errorf("installment %d = paid %.2f (%t), want %.2f (%t)", installment.Number, installment.PaidAmount, installment.Paid, test.paidAmounts[i], test.paid[i])
+				}
+			}
+			if toCents(loan.OutstandingBalance) != toCents(test.wantBalance) {
+				t.Errorf("balance = %.2f, want %.2f", loan.OutstandingBalance, test.wantBalance)
+			}
+			if loan.LoanStatus != test.wantStatus {
+				t.Errorf("status = %q, want %q", loan.LoanStatus, test.wantStatus)
+			}
+		})
+	}
+}
+
+// fakeProvider hands out the notification it is given, as a gateway that verified it would.
+type fakeProvider struct {
+	notification domain.PaymentNotification
+}
+
+func (fp *fakeProvider) Name() string {
+	return "fake"
+}
+
+func (fp *fakeProvider) Parse(header http.Header, body []byte) (domain.PaymentNotification, error) {
+	return fp.notification, nil
+}
+
+// fakePaymentRepo stores payments in memory, unique by provider and transaction id.
+type fakePaymentRepo struct {
+	domain.PaymentRepositoryInterface
+	payments map[string]domain.Payment
+}
+
+func (fr *fakePaymentRepo) Create(payment domain.Payment) (domain.Payment, error) {
+	key := payment.Provider + "/" + payment.TransactionID
+	if _, ok := fr.payments[key]; ok {
+		return domain.Payment{}, domain.ErrDuplicatePayment
+	}
+	payment.ID = primitive.NewObjectID()
+	fr.payments[key] = payment
+	return payment, nil
+}
+
+func (fr *fakePaymentRepo) FindByTransaction(provider string, transaction_id string) (domain.Payment, error) {
+	payment, ok := fr.payments[provider+"/"+transaction_id]
+	if !ok {
+		return domain.Payment{}, errors.New("payment not found")
+	}
+	return payment, nil
+}
+
+func (fr *fakePaymentRepo) UpdateWithContext(ctx context.Context, payment domain.Payment) error {
+	fr.payments[payment.Provider+"/"+payment.TransactionID] = payment
+	return nil
+}
+
+// fakeLoanRepo holds a single loan.
+type fakeLoanRepo struct {
+	domain.LoanRepositoryInterface
+	loan    domain.Loan
+	applied int
+}
+
+func (fr *fakeLoanRepo) FindLoanByID(id string) (domain.Loan, error) {
+	return fr.loan, nil
+}
+
+func (fr *fakeLoanRepo) FindLoansByReference(reference string) ([]domain.Loan, error) {
+	if reference != fr.loan.ReferenceNumber {
+		return []domain.Loan{}, nil
+	}
+	return []domain.Loan{fr.loan}, nil
+}
+
+func (fr *fakeLoanRepo) ApplyPaymentWithContext(ctx context.Context, loan domain.Loan, payment_id primitive.ObjectID, previous_balance float64) error {
+	if toCents(previous_balance) != toCents(fr.loan.OutstandingBalance) {
+		return domain.ErrLoanChanged
+	}
+	loan.PaymentIDs = append(loan.PaymentIDs, payment_id)
+	fr.loan = loan
+	fr.applied++
+	return nil
+}
+
+type fakeUserRepo struct {
+	domain.UserRepositoryInterface
+}
+
+func (fr *fakeUserRepo) FindUserByID(id string) (domain.User, error) {
+	return domain.User{}, nil
+}
+
+type fakeUnitOfWork struct{}
+
+func (fakeUnitOfWork) Do(fn func(ctx context.Context) error) error {
+	return fn(context.Background())
+}
+
+type fakeEvents struct{}
+
+func (fakeEvents) Publish(ctx context.Context, event domain.DomainEvent) error {
+	return nil
+}
+
+func TestReceivePaymentDuplicateTransaction(t *testing.T) {
+	tests := []struct {
+		name          string
+		transactions  []string
+		wantApplied   int
+		wantBalance   float64
+		wantPayments  int
+		stored        *domain.Payment
+		wantLastState string
+	}{
+		{name: "one transaction", transactions: []string{"tx-1"}, wantApplied: 1, wantBalance: 75, wantPayments: 1, wantLastState: domain.PaymentStatusPosted},
+		{name: "same transaction twice", transactions: []string{"tx-1", "tx-1"}, wantApplied: 1, wantBalance: 75, wantPayments: 1, wantLastState: domain.PaymentStatusPosted},
+		{name: "transaction id padded with spaces", transactions: []string{"tx-1", " tx-1 "}, wantApplied: 1, wantBalance: 75, wantPayments: 1, wantLastState: domain.PaymentStatusPosted},
+		{name: "two transactions", transactions: []string{"tx-1", "tx-2"}, wantApplied: 2, wantBalance: 50, wantPayments: 2, wantLastState: domain.PaymentStatusPosted},
+		{
+			name:          "stored but not reconciled",
+			transactions:  []string{"tx-1"},
+			stored:        &domain.Payment{ID: primitive.NewObjectID(), Provider: "fake", TransactionID: "tx-1", Reference: "LN-7K3M9Q2D", Amount: 25, Status: domain.PaymentStatusReceived},
+			wantApplied:   1,
+			wantBalance:   75,
+			wantPayments:  1,
+			wantLastState: domain.PaymentStatusPosted,
+		},
+		{
+			name:          "stored in suspense",
+			transactions:  []string{"tx-1"},
+			stored:        &domain.Payment{ID: primitive.NewObjectID(), Provider: "fake", TransactionID: "tx-1", Reference: "LN-7K3M9Q2D", Amount: 25, Status: domain.PaymentStatusSuspense},
+			wantApplied:   0,
+			wantBalance:   100,
+			wantPayments:  1,
+			wantLastState: domain.PaymentStatusSuspense,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := &fakeProvider{}
+			payments := &fakePaymentRepo{payments: map[string]domain.Payment{}}
+			if test.stored != nil {
+				payments.payments["fake/tx-1"] = *test.stored
+			}
+			loans := &fakeLoanRepo{loan: domain.Loan{
+				ID:                 primitive.NewObjectID(),
+				UserId:             primitive.NewObjectID(),
+				ReferenceNumber:    "LN-7K3M9Q2D",
+				LoanStatus:         domain.LoanDisbursed,
+				OutstandingBalance: 100,
+				Installments:       installments(25, 25, 25, 25),
+			}}
+			pu := NewPaymentUseCase(payments, loans, &fakeUserRepo{}, fakeUnitOfWork{}, fakeEvents{}, []domain.PaymentProvider{provider}, &infrastructure.Config{})
+
+			var payment domain.Payment
+			for _, transaction := range test.transactions {
+				provider.notification = domain.PaymentNotification{TransactionID: transaction, Reference: "ln 7k3m9q2d", Amount: 25, PaidAt: time.Now()}
+				var err error
+				payment, err = pu.ReceivePayment("fake", nil, nil)
+				if err != nil {
+					t.Fatalf("ReceivePayment(%q) error = %v", transaction, err)
+				}
+			}
+			if loans.applied != test.wantApplied {
+				t.Errorf("payments applied = %d, want %d", loans.applied, test.wantApplied)
+			}
+			if toCents(loans.loan.OutstandingBalance) != toCents(test.wantBalance) {
+				t.Errorf("balance = %.2f, want %.2f", loans.loan.OutstandingBalance, test.wantBalance)
+			}
+			if len(payments.payments) != test.wantPayments {
+				t.Errorf("payments stored = %d, want %d", len(payments.payments), test.wantPayments)
+			}
+			if payment.Status != test.wantLastState {
+				t.Errorf("status = %q, want %q", payment.Status, test.wantLastState)
+			}
+		})
+	}
+}
+
+func TestPostPaymentDuplicateTransaction(t *testing.T) {
+	payments := &fakePaymentRepo{payments: map[string]domain.Payment{}}
+	loans := &fakeLoanRepo{loan: domain.Loan{
+		ID:                 primitive.NewObjectID(),
+		UserId:             primitive.NewObjectID(),
+		LoanStatus:         domain.LoanDisbursed,
+		OutstandingBalance: 100,
+		Installments:       installments(50, 50),
+	}}
+	pu := NewPaymentUseCase(payments, loans, &fakeUserRepo{}, fakeUnitOfWork{}, fakeEvents{}, nil, &infrastructure.Config{})
+	line := domain.Payment{Provider: "statement:cbe", TransactionID: "line:abc", Amount: 50}
+
+	first, err := pu.PostPayment(line, loans.loan.ID.Hex(), "admin")
+	if err != nil || first.Status != domain.PaymentStatusPosted {
+		t.Fatalf("first PostPayment() = %+v, %v", first, err)
+	}
+	second, err := pu.PostPayment(line, loans.loan.ID.Hex(), "admin")
+	if !errors.Is(err, domain.ErrDuplicatePayment) {
+		t.Fatalf("second PostPayment() error = %v, want %v", err, domain.ErrDuplicatePayment)
+	}
+	if second.ID != first.ID || loans.applied != 1 || toCents(loans.loan.OutstandingBalance) != 5000 {
+		t.Errorf("second PostPayment() = %+v, applied %d times, balance %.2f", second, loans.applied, loans.loan.OutstandingBalance)
+	}
+}
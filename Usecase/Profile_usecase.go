@@ -0,0 +1,266 @@
+package usecases
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	domain "loan-tracker/Domain"
+	infrastructure "loan-tracker/Infrastructure"
+	"slices"
+	"strings"
+	"time"
+)
+
+// How long the confirmation link of an email change stays valid.
+const emailChangeTTL = 24 * time.Hour
+
+// How many browsers a user can receive push notifications on.
+const maxPushSubscriptions = 10
+
+func userProfile(user domain.User) domain.UserProfile {
+	return domain.UserProfile{
+		ID:           user.ID,
+		User_Name:    user.User_Name,
+		Email:        user.Email,
+		Contact:      user.Contact,
+		PendingEmail: user.PendingEmail,
+		Locale:       user.Locale,
+		Created_At:   user.Created_At,
+	}
+}
+
+func (uc *UserUseCase) UpdateProfile(id string, request domain.UpdateProfileRequest) (domain.UserProfile, error) {
+	user, err := uc.UserRepo.FindUserByID(id)
+	if err != nil {
+		return domain.UserProfile{}, errors.New("user not found")
+	}
+
+	if request.User_Name != nil {
+		userName := strings.TrimSpace(*request.User_Name)
+		if userName == "" {
+			return domain.UserProfile{}, errors.New("user name can not be empty")
+		}
+		if userName != user.User_Name {
+			existing, err := uc.UserRepo.FindUserByUserName(userName)
+			if err == nil && existing.ID != user.ID {
+				return domain.UserProfile{}, domain.ErrUserNameTaken
+			}
+			user.User_Name = userName
+		}
+	}
+	if request.Contact != nil {
+		contact := strings.TrimSpace(*request.Contact)
+		if contact != "" {
+			if err := infrastructure.ValidateContact(contact); err != nil {
+				return domain.UserProfile{}, err
+			}
+		}
+		user.Contact = contact
+	}
+	if request.Locale != nil {
+		locale := infrastructure.NormalizeLocale(*request.Locale)
+		if !uc.Emails.SupportsLocale(locale) {
+			return domain.UserProfile{}, errors.New("unsupported locale")
+		}
+		user.Locale = locale
+	}
+
+	err = uc.UserRepo.UpdateUser(user)
+	if errors.Is(err, domain.ErrUserNameTaken) {
+		return domain.UserProfile{}, err
+	}
+	if err != nil {
+		return domain.UserProfile{}, errors.New("error updating profile")
+	}
+	return userProfile(user), nil
+}
+
+// RequestEmailChange starts an email change. The new address only replaces the current one once the
+// link sent to it is followed; the current address is told about the request.
+func (uc *UserUseCase) RequestEmailChange(id string, new_email string, password string) error {
+	user, err := uc.UserRepo.FindUserByID(id)
+	if err != nil {
+		return errors.New("user not found")
+	}
+	if !uc.PassService.ComparePassword(password, user.Password) {
+		return errInvalidCredentials
+	}
+	new_email = infrastructure.NormalizeEmail(new_email)
+	if infrastructure.ValidateEmail(new_email) != nil {
+		return errors.New("invalid email format")
+	}
+	if strings.EqualFold(new_email, user.Email) {
+		return errors.New("new email is the same as the current one")
+	}
+	if _, err := uc.UserRepo.FindUserByEmail(new_email); err == nil {
+		return domain.ErrEmailTaken
+	}
+
+	token, err := infrastructure.GenerateVerificationToken()
+	if err != nil {
+		return errors.New("error generating token")
+	}
+	user.PendingEmail = new_email
+	user.EmailChangeToken = infrastructure.HashToken(token)
+	user.EmailChangeExpires = time.Now().Add(emailChangeTTL)
+
+	event := domain.EmailChangeRequested{
+		User:             user,
+		NewEmail:         new_email,
+		ConfirmationLink: infrastructure.EmailChangeLink(uc.Config.PublicBaseURL, user.ID.Hex(), token),
+	}
+	return uc.UnitOfWork.Do(func(ctx context.Context) error {
+		if err := uc.UserRepo.UpdateUserWithContext(ctx, user); err != nil {
+			return errors.New("error updating user")
+		}
+		if err := uc.Events.Publish(ctx, event); err != nil {
+			return errors.New("error queueing email change emails")
+		}
+		return nil
+	})
+}
+
+func (uc *UserUseCase) ConfirmEmailChange(id string, token string) error {
+	user, err := uc.UserRepo.FindUserByID(id)
+	if err != nil {
+		return errors.New("invalid or expired link")
+	}
+	if user.PendingEmail == "" || subtle.ConstantTimeCompare([]byte(user.EmailChangeToken), []byte(infrastructure.HashToken(token))) != 1 {
+		return errors.New("invalid or expired link")
+	}
+	if user.EmailChangeExpires.Before(time.Now()) {
+		return errors.New("invalid or expired link")
+	}
+	// The address may have been registered by someone else since the change was requested.
+	if _, err := uc.UserRepo.FindUserByEmail(user.PendingEmail); err == nil {
+		return domain.ErrEmailTaken
+	}
+
+	user.Email = user.PendingEmail
+	user.PendingEmail = ""
+	user.EmailChangeToken = ""
+	user.EmailChangeExpires = time.Time{}
+	err = uc.UserRepo.UpdateUser(user)
+	if errors.Is(err, domain.ErrEmailTaken) {
+		return err
+	}
+	if err != nil {
+		return errors.New("error updating email")
+	}
+	return nil
+}
+
+func (uc *UserUseCase) GetNotificationPreferences(id string) (domain.NotificationPreferences, error) {
+	user, err := uc.UserRepo.FindUserByID(id)
+	if err != nil {
+		return domain.NotificationPreferences{}, errors.New("user not found")
+	}
+	optOuts := user.NotificationPreferences.OptOuts
+	if optOuts == nil {
+		optOuts = []string{}
+	}
+	return domain.NotificationPreferences{Channels: notificationChannels(user), OptOuts: optOuts}, nil
+}
+
+// UpdateNotificationPreferences replaces the channels the user is notified on and the categories
+// they opted out of. At least one channel is required so security notifications reach the user.
+func (uc *UserUseCase) UpdateNotificationPreferences(id string, request domain.UpdateNotificationPreferencesRequest) (domain.NotificationPreferences, error) {
+	user, err := uc.UserRepo.FindUserByID(id)
+	if err != nil {
+		return domain.NotificationPreferences{}, errors.New("user not found")
+	}
+	channels, err := normalizeChoices(request.Channels, domain.NotificationChannels, "unsupported channel: ")
+	if err != nil {
+		return domain.NotificationPreferences{}, err
+	}
+	if len(channels) == 0 {
+		return domain.NotificationPreferences{}, errors.New("at least one channel is required")
+	}
+	if slices.Contains(channels, domain.ChannelSMS) && user.Contact == "" {
+		return domain.NotificationPreferences{}, errors.New("add a contact number to your profile to receive sms")
+	}
+	if slices.Contains(channels, domain.ChannelPush) && len(user.PushSubscriptions) == 0 {
+		return domain.NotificationPreferences{}, errors.New("subscribe a browser to receive push notifications")
+	}
+	optOuts, err := normalizeChoices(request.OptOuts, domain.NotificationCategories, "cannot opt out of: ")
+	if err != nil {
+		return domain.NotificationPreferences{}, err
+	}
+	user.NotificationPreferences = domain.NotificationPreferences{Channels: channels, OptOuts: optOuts}
+	err = uc.UserRepo.UpdateUser(user)
+	if err != nil {
+		return domain.NotificationPreferences{}, errors.New("error updating notification preferences")
+	}
+	return user.NotificationPreferences, nil
+}
+
+// normalizeChoices lowercases and deduplicates the values, refusing any that is not allowed.
+func normalizeChoices(values []string, allowed []string, message string) ([]string, error) {
+	chosen := []string{}
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if !slices.Contains(allowed, value) {
+			return nil, errors.New(message + value)
+		}
+		if !slices.Contains(chosen, value) {
+			chosen = append(chosen, value)
+		}
+	}
+	return chosen, nil
+}
+
+// PushPublicKey returns the VAPID public key browsers need to subscribe to push notifications.
+func (uc *UserUseCase) PushPublicKey() string {
+	return uc.Config.VAPIDPublicKey
+}
+
+// AddPushSubscription stores a browser's push subscription, replacing any with the same endpoint.
+func (uc *UserUseCase) AddPushSubscription(id string, request domain.PushSubscriptionRequest) error {
+	user, err := uc.UserRepo.FindUserByID(id)
+	if err != nil {
+		return errors.New("user not found")
+	}
+	subscriptions := slices.DeleteFunc(user.PushSubscriptions, func(subscription domain.PushSubscription) bool {
+		return subscription.Endpoint == request.Endpoint
+	})
+	if len(subscriptions) >= maxPushSubscriptions {
+		subscriptions = subscriptions[len(subscriptions)-maxPushSubscriptions+1:]
+	}
+	user.PushSubscriptions = append(subscriptions, domain.PushSubscription{
+		Endpoint:  request.Endpoint,
+		P256dh:    request.Keys.P256dh,
+		Auth:      request.Keys.Auth,
+		CreatedAt: time.Now(),
+	})
+	err = uc.UserRepo.UpdateUser(user)
+	if err != nil {
+		return errors.New("error updating user")
+	}
+	return nil
+}
+
+// RemovePushSubscription forgets a browser's push subscription. Push is dropped from the user's
+// channels along with their last subscription.
+func (uc *UserUseCase) RemovePushSubscription(id string, endpoint string) error {
+	user, err := uc.UserRepo.FindUserByID(id)
+	if err != nil {
+		return errors.New("user not found")
+	}
+	count := len(user.PushSubscriptions)
+	user.PushSubscriptions = slices.DeleteFunc(user.PushSubscriptions, func(subscription domain.PushSubscription) bool {
+		return subscription.Endpoint == endpoint
+	})
+	if len(user.PushSubscriptions) == count {
+		return errors.New("push subscription not found")
+	}
+	if len(user.PushSubscriptions) == 0 {
+		user.NotificationPreferences.Channels = slices.DeleteFunc(user.NotificationPreferences.Channels, func(channel string) bool {
+			return channel == domain.ChannelPush
+		})
+	}
+	err = uc.UserRepo.UpdateUser(user)
+	if err != nil {
+		return errors.New("error updating user")
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	domain "loan-tracker/Domain"
+	infrastructure "loan-tracker/Infrastructure"
+	"log"
+	"time"
+)
+
+// reminderGraceDays is how long after its last stage an unpaid installment is still scanned, so a
+// stage missed while no scheduler was running is sent late rather than never.
+const reminderGraceDays = 7
+
+// ReminderScheduler sends payment due reminders and overdue notices for the installments of disbursed
+// loans, on the days set by the product's reminder cadence.
+type ReminderScheduler struct {
+	LoanRepo     domain.LoanRepositoryInterface
+	UserRepo     domain.UserRepositoryInterface
+	ReminderRepo domain.ReminderRepositoryInterface
+	UnitOfWork   domain.UnitOfWorkInterface
+	Events       domain.EventBusInterface
+	Config       *infrastructure.Config
+}
+
+func NewReminderScheduler(loanRepo domain.LoanRepositoryInterface, userRepo domain.UserRepositoryInterface, reminderRepo domain.ReminderRepositoryInterface, unitOfWork domain.UnitOfWorkInterface, events domain.EventBusInterface, config *infrastructure.Config) *ReminderScheduler {
+	return &ReminderScheduler{
+		LoanRepo:     loanRepo,
+		UserRepo:     userRepo,
+		ReminderRepo: reminderRepo,
+		UnitOfWork:   unitOfWork,
+		Events:       events,
+		Config:       config,
+	}
+}
+
+// Run scans for reminders to send every REMINDER_POLL_MINUTES until ctx is cancelled.
+func (rs *ReminderScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(rs.Config.ReminderPollMinutes) * time.Minute)
+	defer ticker.Stop()
+	for {
+		if err := rs.scan(time.Now()); err != nil {
+			log.Println("error scanning for payment reminders:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (rs *ReminderScheduler) scan(now time.Time) error {
+	today := startOfDay(now)
+	earliest, latest := 0, 0
+	for _, cadence := range rs.Config.ReminderCadences {
+		if len(cadence) == 0 {
+			continue
+		}
+		earliest = min(earliest, cadence[0])
+		latest = max(latest, cadence[len(cadence)-1])
+	}
+	// An installment needs a reminder from its earliest stage until its last one has passed.
+	from := today.AddDate(0, 0, -latest-reminderGraceDays)
+	to := today.AddDate(0, 0, -earliest)
+	loans, err := rs.LoanRepo.FindLoansWithUnpaidInstallments(from, to)
+	if err != nil {
+		return err
+	}
+	for _, loan := range loans {
+		borrower, err := rs.UserRepo.FindUserByID(loan.UserId.Hex())
+		if err != nil {
+			continue
+		}
+		for _, installment := range loan.Installments {
+			if installment.Paid {
+				continue
+			}
+			offset, ok := currentStage(rs.Config.ReminderCadence(loan.Product), installment.DueDate, today)
+			if !ok {
+				continue
+			}
+			if err := rs.remind(loan, installment, borrower, offset); err != nil && !errors.Is(err, domain.ErrReminderAlreadySent) {
+				log.Printf("error sending reminder for loan %s installment %d: %v", loan.ID.Hex(), installment.Number, err)
+			}
+		}
+	}
+	return nil
+}
+
+// currentStage returns the latest stage of the cadence reached by today. Earlier stages that were
+// skipped are not sent any more: only the most recent reminder is relevant to the borrower.
+func currentStage(cadence []int, due time.Time, today time.Time) (int, bool) {
+	due = startOfDay(due)
+	stage, found := 0, false
+	for _, offset := range cadence {
+		if !due.AddDate(0, 0, offset).After(today) {
+			stage, found = offset, true
+		}
+	}
+	return stage, found
+}
+
+// remind records the stage as sent and publishes the reminder in one unit of work. When another
+// replica recorded the stage first, nothing is published. Stages are recorded for borrowers who
+// opted out of payment notifications too, so their inbox still gets overdue notices.
+func (rs *ReminderScheduler) remind(loan domain.Loan, installment domain.Installment, borrower domain.User, offset int) error {
+	return rs.UnitOfWork.Do(func(ctx context.Context) error {
+		err := rs.ReminderRepo.Record(ctx, domain.ReminderLog{
+			LoanID:      loan.ID,
+			UserID:      borrower.ID,
+			Installment: installment.Number,
+			Stage:       reminderStage(offset),
+			Channels:    notificationChannels(borrower),
+			SentAt:      time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		return rs.Events.Publish(ctx, domain.PaymentReminderDue{
+			Loan:        loan,
+			Installment: installment,
+			Borrower:    borrower,
+			Offset:      offset,
+		})
+	})
+}
+
+// reminderStage names a cadence stage, such as "before_3d", "due" or "overdue_7d".
+func reminderStage(offset int) string {
+	switch {
+	case offset < 0:
+		return fmt.Sprintf("before_%dd", -offset)
+	case offset == 0:
+		return "due"
+	default:
+		return fmt.Sprintf("overdue_%dd", offset)
+	}
+}
@@ -0,0 +1,129 @@
+package usecases
+
+import (
+	"errors"
+	domain "loan-tracker/Domain"
+	infrastructure "loan-tracker/Infrastructure"
+	"math"
+	"time"
+)
+
+// ReportUseCase serves the aggregate reports of the admin dashboard.
+type ReportUseCase struct {
+	ReportRepo domain.ReportRepositoryInterface
+	Config     *infrastructure.Config
+}
+
+func NewReportUseCase(reportRepo domain.ReportRepositoryInterface, config *infrastructure.Config) *ReportUseCase {
+	return &ReportUseCase{
+		ReportRepo: reportRepo,
+		Config:     config,
+	}
+}
+
+func (ru *ReportUseCase) validateFilter(filter domain.ReportFilter) error {
+	switch filter.GroupBy {
+	case "", domain.ReportGroupProduct, domain.ReportGroupStatus, domain.ReportGroupMonth:
+	default:
+		return errors.New("invalid group_by: expected product, status or month")
+	}
+	if filter.Product != "" && !ru.Config.IsLoanProduct(filter.Product) {
+		return errors.New("unknown loan product")
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return errors.New("from must be before to")
+	}
+	return nil
+}
+
+// ratio is part of total rounded to four decimals, or 0 for an empty total.
+func ratio(part float64, total float64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return math.Round(part/total*10000) / 10000
+}
+
+// GetDashboard gathers the totals of every report for the filter's product and date range.
+func (ru *ReportUseCase) GetDashboard(filter domain.ReportFilter, user_id string) (domain.PortfolioDashboard, error) {
+	filter.GroupBy = ""
+	dashboard := domain.PortfolioDashboard{
+		AsOf:        time.Now().UTC(),
+		Activity:    domain.LoanActivityRow{Group: domain.ReportTotal},
+		Portfolio:   domain.PortfolioRow{Group: domain.ReportTotal},
+		Collections: domain.CollectionsRow{Group: domain.ReportTotal},
+	}
+	activity, err := ru.GetLoanActivity(filter, user_id)
+	if err != nil {
+		return domain.PortfolioDashboard{}, err
+	}
+	if len(activity) > 0 {
+		dashboard.Activity = activity[0]
+	}
+	portfolio, err := ru.portfolio(filter, dashboard.AsOf)
+	if err != nil {
+		return domain.PortfolioDashboard{}, err
+	}
+	if len(portfolio) > 0 {
+		dashboard.Portfolio = portfolio[0]
+	}
+	collections, err := ru.ReportRepo.Collections(filter)
+	if err != nil {
+		return domain.PortfolioDashboard{}, errors.New("error getting collections")
+	}
+	if len(collections) > 0 {
+		dashboard.Collections = collections[0]
+	}
+	return dashboard, nil
+}
+
+// GetLoanActivity counts applications, decisions and disbursements. The approval rate is the share
+// of approvals among the decisions made.
+func (ru *ReportUseCase) GetLoanActivity(filter domain.ReportFilter, user_id string) ([]domain.LoanActivityRow, error) {
+	if err := ru.validateFilter(filter); err != nil {
+		return nil, err
+	}
+	rows, err := ru.ReportRepo.LoanActivity(filter)
+	if err != nil {
+		return nil, errors.New("error getting loan activity")
+	}
+	for i := range rows {
+		rows[i].ApprovalRate = ratio(float64(rows[i].Approved), float64(rows[i].Approved+rows[i].Rejected))
+		rows[i].AvgDecisionHours = math.Round(rows[i].AvgDecisionHours*100) / 100
+	}
+	return rows, nil
+}
+
+// GetPortfolio reports the outstanding principal and portfolio at risk of the loans disbursed in
+// the filter's range, as of now.
+func (ru *ReportUseCase) GetPortfolio(filter domain.ReportFilter, user_id string) ([]domain.PortfolioRow, error) {
+	if err := ru.validateFilter(filter); err != nil {
+		return nil, err
+	}
+	return ru.portfolio(filter, time.Now())
+}
+
+func (ru *ReportUseCase) portfolio(filter domain.ReportFilter, as_of time.Time) ([]domain.PortfolioRow, error) {
+	rows, err := ru.ReportRepo.Portfolio(filter, as_of)
+	if err != nil {
+		return nil, errors.New("error getting portfolio")
+	}
+	for i := range rows {
+		rows[i].PAR1 = ratio(rows[i].Arrears1, rows[i].OutstandingPrincipal)
+		rows[i].PAR30 = ratio(rows[i].Arrears30, rows[i].OutstandingPrincipal)
+		rows[i].PAR90 = ratio(rows[i].Arrears90, rows[i].OutstandingPrincipal)
+	}
+	return rows, nil
+}
+
+// GetCollections sums the payments posted to loans in the filter's range.
+func (ru *ReportUseCase) GetCollections(filter domain.ReportFilter, user_id string) ([]domain.CollectionsRow, error) {
+	if err := ru.validateFilter(filter); err != nil {
+		return nil, err
+	}
+	rows, err := ru.ReportRepo.Collections(filter)
+	if err != nil {
+		return nil, errors.New("error getting collections")
+	}
+	return rows, nil
+}
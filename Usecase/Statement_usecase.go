@@ -0,0 +1,435 @@
+package usecases
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	domain "loan-tracker/Domain"
+	infrastructure "loan-tracker/Infrastructure"
+	utils "loan-tracker/Utils"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Points a statement line earns towards a loan. A line that quotes the loan's reference, pays its
+// next installment and names its borrower scores 100.
+const (
+	referenceScore   = 60
+	installmentScore = 25
+	balanceScore     = 10
+	nameScore        = 15
+)
+
+var (
+	bankPattern          = regexp.MustCompile(`^[a-z0-9_-]{1,40}$`)
+	loanReferencePattern = regexp.MustCompile(`(?i)\bLN[\s-]?[0-9A-Z]{8}\b`)
+	loanIDPattern        = regexp.MustCompile(`(?i)\b[0-9a-f]{24}\b`)
+)
+
+// confirmableLines are the line statuses an admin can confirm.
+var confirmableLines = []string{domain.LineMatched, domain.LineReview, domain.LineUnmatched, domain.LineSkipped, domain.LineFailed}
+
+// StatementUseCase imports bank statements: it reads their credits with the bank's column mapping,
+// proposes a loan for each with a confidence score, and posts the lines an admin confirms as
+// repayments. Lines are posted as payments keyed by their bank transaction, so uploading a file
+// again or a statement that overlaps an earlier one never posts a transaction twice.
+type StatementUseCase struct {
+	StatementRepo domain.StatementRepositoryInterface
+	PaymentRepo   domain.PaymentRepositoryInterface
+	Payments      domain.PaymentUseCaseInterface
+	LoanRepo      domain.LoanRepositoryInterface
+	UserRepo      domain.UserRepositoryInterface
+	Config        *infrastructure.Config
+}
+
+func NewStatementUseCase(statementRepo domain.StatementRepositoryInterface, paymentRepo domain.PaymentRepositoryInterface, payments domain.PaymentUseCaseInterface, loanRepo domain.LoanRepositoryInterface, userRepo domain.UserRepositoryInterface, config *infrastructure.Config) *StatementUseCase {
+	return &StatementUseCase{
+		StatementRepo: statementRepo,
+		PaymentRepo:   paymentRepo,
+		Payments:      payments,
+		LoanRepo:      loanRepo,
+		UserRepo:      userRepo,
+		Config:        config,
+	}
+}
+
+// statementProvider is the payment provider the lines of a bank's statements are posted as.
+func statementProvider(bank string) string {
+	return "statement:" + bank
+}
+
+func (su *StatementUseCase) GetMappings(user_id string) ([]domain.StatementMapping, error) {
+	mappings, err := su.StatementRepo.FindMappings()
+	if err != nil {
+		return nil, errors.New("error getting mappings")
+	}
+	return mappings, nil
+}
+
+// SaveMapping creates or replaces the column mapping of a bank.
+func (su *StatementUseCase) SaveMapping(bank string, mapping domain.StatementMapping, user_id string) (domain.StatementMapping, error) {
+	mapping.Bank = strings.ToLower(strings.TrimSpace(bank))
+	if !bankPattern.MatchString(mapping.Bank) {
+		return domain.StatementMapping{}, errors.New("bank must be 1 to 40 lowercase letters, digits, dashes or underscores")
+	}
+	if mapping.SkipRows < 0 {
+		return domain.StatementMapping{}, errors.New("skip_rows cannot be negative")
+	}
+	if mapping.DateFormat == "" {
+		mapping.DateFormat = infrastructure.DefaultStatementDateFormat
+	}
+	if utf8.RuneCountInString(mapping.Delimiter) > 1 || strings.ContainsAny(mapping.Delimiter, "\"\r\n") {
+		return domain.StatementMapping{}, errors.New("delimiter must be a single character")
+	}
+	mapping.Updated_At = time.Now()
+	if err := su.StatementRepo.SaveMapping(mapping); err != nil {
+		return domain.StatementMapping{}, errors.New("error saving mapping")
+	}
+	return mapping, nil
+}
+
+func (su *StatementUseCase) DeleteMapping(bank string, user_id string) error {
+	if err := su.StatementRepo.DeleteMapping(bank); err != nil {
+		return errors.New("mapping not found")
+	}
+	return nil
+}
+
+// ImportStatement reads a statement and stores it as a preview with a proposed loan for each line.
+// Nothing is posted until the import is confirmed. A file already uploaded for the bank returns
+// its earlier import.
+func (su *StatementUseCase) ImportStatement(bank string, filename string, content []byte, user_id string) (domain.StatementImport, error) {
+	bank = strings.ToLower(strings.TrimSpace(bank))
+	mapping, err := su.StatementRepo.FindMapping(bank)
+	if err != nil {
+		return domain.StatementImport{}, errors.New("no column mapping for this bank")
+	}
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	if existing, err := su.StatementRepo.FindImportByHash(bank, hash); err == nil {
+		return existing, nil
+	}
+
+	lines, err := infrastructure.ParseStatement(mapping, content, su.Config.StatementMaxLines)
+	if err != nil {
+		return domain.StatementImport{}, err
+	}
+	matcher, err := su.newMatcher()
+	if err != nil {
+		return domain.StatementImport{}, errors.New("error loading loans")
+	}
+	for i := range lines {
+		line := &lines[i]
+		if line.Status != "" {
+			continue
+		}
+		if payment, err := su.PaymentRepo.FindByTransaction(statementProvider(bank), line.TransactionKey); err == nil {
+			line.Status = domain.LineDuplicate
+			line.PaymentID = payment.ID
+			line.LoanID = payment.LoanID
+			continue
+		}
+		matcher.match(line)
+	}
+
+	statement, err := su.StatementRepo.CreateImport(domain.StatementImport{
+		Bank:       bank,
+		Filename:   filename,
+		FileHash:   hash,
+		Status:     domain.StatementPreview,
+		Lines:      lines,
+		Created_At: time.Now(),
+		CreatedBy:  user_id,
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return su.StatementRepo.FindImportByHash(bank, hash)
+	}
+	if err != nil {
+		return domain.StatementImport{}, errors.New("error saving import")
+	}
+	return statement, nil
+}
+
+func (su *StatementUseCase) GetImports(pageNo, pageSize string, user_id string) ([]domain.StatementImport, error) {
+	pageS, pageN, err := utils.PagePaginationValidator(pageSize, pageNo)
+	if err != nil {
+		return nil, err
+	}
+	statements, err := su.StatementRepo.FindImports(pageN, pageS)
+	if err != nil {
+		return nil, errors.New("error getting imports")
+	}
+	return statements, nil
+}
+
+func (su *StatementUseCase) GetImport(id string, user_id string) (domain.StatementImport, error) {
+	statement, err := su.StatementRepo.FindImportByID(id)
+	if err != nil {
+		return domain.StatementImport{}, errors.New("import not found")
+	}
+	return statement, nil
+}
+
+// ConfirmImport posts the chosen lines, every matched line when none are listed, and skips the
+// other lines still awaiting confirmation. It can be called again for lines skipped or failed.
+func (su *StatementUseCase) ConfirmImport(id string, request domain.ConfirmStatementRequest, user_id string) (domain.StatementImport, error) {
+	statement, err := su.GetImport(id, user_id)
+	if err != nil {
+		return domain.StatementImport{}, err
+	}
+	chosen := map[int]string{}
+	if len(request.Lines) == 0 {
+		for _, line := range statement.Lines {
+			if line.Status == domain.LineMatched {
+				chosen[line.Number] = line.LoanID.Hex()
+			}
+		}
+	}
+	for _, confirmation := range request.Lines {
+		index := slices.IndexFunc(statement.Lines, func(line domain.StatementLine) bool {
+			return line.Number == confirmation.Line
+		})
+		if index < 0 {
+			return domain.StatementImport{}, fmt.Errorf("statement has no line %d", confirmation.Line)
+		}
+		line := statement.Lines[index]
+		if !slices.Contains(confirmableLines, line.Status) {
+			return domain.StatementImport{}, fmt.Errorf("line %d is %s and cannot be confirmed", line.Number, line.Status)
+		}
+		loan_id := confirmation.LoanID
+		if loan_id == "" && !line.LoanID.IsZero() {
+			loan_id = line.LoanID.Hex()
+		}
+		if loan_id == "" {
+			return domain.StatementImport{}, fmt.Errorf("line %d has no loan", line.Number)
+		}
+		chosen[line.Number] = loan_id
+	}
+	if len(chosen) == 0 {
+		return domain.StatementImport{}, errors.New("no lines to confirm")
+	}
+
+	for i := range statement.Lines {
+		line := &statement.Lines[i]
+		loan_id, ok := chosen[line.Number]
+		if !ok {
+			if slices.Contains(confirmableLines, line.Status) && line.Status != domain.LineFailed {
+				line.Status = domain.LineSkipped
+			}
+			continue
+		}
+		payment, err := su.Payments.PostPayment(domain.Payment{
+			Provider:      statementProvider(statement.Bank),
+			TransactionID: line.TransactionKey,
+			Reference:     line.Reference,
+			Amount:        line.Amount,
+			PaidAt:        line.Date,
+		}, loan_id, user_id)
+		line.Error = ""
+		switch {
+		case errors.Is(err, domain.ErrDuplicatePayment):
+			line.Status = domain.LineDuplicate
+			line.PaymentID = payment.ID
+		case err != nil:
+			line.Status = domain.LineFailed
+			line.Error = err.Error()
+		case payment.Status == domain.PaymentStatusPosted:
+			line.Status = domain.LinePosted
+			line.PaymentID = payment.ID
+			line.LoanID = payment.LoanID
+		default:
+			line.Status = domain.LineSuspense
+			line.PaymentID = payment.ID
+			line.Error = payment.SuspenseReason
+		}
+	}
+	if statement.Status == domain.StatementPreview {
+		statement.Status = domain.StatementConfirmed
+		statement.ConfirmedAt = time.Now()
+		statement.ConfirmedBy = user_id
+	}
+	if err := su.StatementRepo.UpdateImport(statement); err != nil {
+		return domain.StatementImport{}, errors.New("error saving import")
+	}
+	return statement, nil
+}
+
+// loanCandidate is a loan being repaid, with what statement lines are compared against.
+type loanCandidate struct {
+	loan  domain.Loan
+	names []string
+	due   int64
+}
+
+// statementMatcher indexes the loans being repaid by reference, next installment and borrower name.
+type statementMatcher struct {
+	byReference   map[string][]*loanCandidate
+	byInstallment map[int64][]*loanCandidate
+	byName        map[string][]*loanCandidate
+	matchScore    int
+	reviewScore   int
+}
+
+func (su *StatementUseCase) newMatcher() (*statementMatcher, error) {
+	loans, err := su.LoanRepo.GetAllLoans(domain.LoanDisbursed, "")
+	if err != nil {
+		return nil, err
+	}
+	matcher := &statementMatcher{
+		byReference:   map[string][]*loanCandidate{},
+		byInstallment: map[int64][]*loanCandidate{},
+		byName:        map[string][]*loanCandidate{},
+		matchScore:    su.Config.StatementMatchThreshold,
+		reviewScore:   su.Config.StatementReviewThreshold,
+	}
+	for _, loan := range loans {
+		candidate := &loanCandidate{loan: loan, due: nextInstallmentDue(loan)}
+		if borrower, err := su.UserRepo.FindUserByID(loan.UserId.Hex()); err == nil {
+			candidate.names = nameTokens(borrower.User_Name)
+		}
+		matcher.byReference[strings.ToUpper(loan.ID.Hex())] = append(matcher.byReference[strings.ToUpper(loan.ID.Hex())], candidate)
+		if loan.ReferenceNumber != "" {
+			matcher.byReference[loan.ReferenceNumber] = append(matcher.byReference[loan.ReferenceNumber], candidate)
+		}
+		if candidate.due > 0 {
+			matcher.byInstallment[candidate.due] = append(matcher.byInstallment[candidate.due], candidate)
+		}
+		for _, name := range candidate.names {
+			matcher.byName[name] = append(matcher.byName[name], candidate)
+		}
+	}
+	return matcher, nil
+}
+
+// nextInstallmentDue is what is left to pay on the loan's first unpaid installment, in cents.
+func nextInstallmentDue(loan domain.Loan) int64 {
+	for _, installment := range loan.Installments {
+		if !installment.Paid {
+			return toCents(installment.Amount) - toCents(installment.PaidAmount)
+		}
+	}
+	return 0
+}
+
+// nameTokens splits a name into lowercase words of two letters or more.
+func nameTokens(name string) []string {
+	tokens := []string{}
+	for _, token := range strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(token)) > 1 && !slices.Contains(tokens, token) {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// match proposes the best scoring loan for the line. Lines that quote a reference are only matched
+// to the loans it names; others are compared with the loans whose next installment is the amount
+// paid or whose borrower shares a name with the payer.
+func (sm *statementMatcher) match(line *domain.StatementLine) {
+	text := line.Reference + " " + line.Description
+	referenced := map[*loanCandidate]bool{}
+	candidates := []*loanCandidate{}
+	add := func(candidate *loanCandidate) {
+		if !slices.Contains(candidates, candidate) {
+			candidates = append(candidates, candidate)
+		}
+	}
+	for _, reference := range append(loanReferencePattern.FindAllString(text, -1), loanIDPattern.FindAllString(text, -1)...) {
+		for _, candidate := range sm.byReference[infrastructure.NormalizeLoanReference(reference)] {
+			referenced[candidate] = true
+			add(candidate)
+		}
+	}
+	payer := nameTokens(line.Name)
+	if len(payer) == 0 {
+		payer = nameTokens(line.Description)
+	}
+	if len(candidates) == 0 {
+		for _, candidate := range sm.byInstallment[toCents(line.Amount)] {
+			add(candidate)
+		}
+		for _, name := range payer {
+			for _, candidate := range sm.byName[name] {
+				add(candidate)
+			}
+		}
+	}
+
+	best, bestScore, tied := (*loanCandidate)(nil), -1, false
+	var bestReasons []string
+	for _, candidate := range candidates {
+		score, reasons := scoreLine(line, candidate, referenced[candidate], payer)
+		switch {
+		case score > bestScore:
+			best, bestScore, bestReasons, tied = candidate, score, reasons, false
+		case score == bestScore:
+			tied = true
+		}
+	}
+	if best == nil {
+		line.Status = domain.LineUnmatched
+		line.Reasons = []string{"no loan matches the reference, amount or name"}
+		return
+	}
+	line.Confidence = bestScore
+	line.Reasons = bestReasons
+	switch {
+	case tied:
+		line.Status = domain.LineReview
+		line.LoanID = best.loan.ID
+		line.Reasons = append(line.Reasons, "several loans match equally well")
+	case bestScore >= sm.matchScore:
+		line.Status = domain.LineMatched
+		line.LoanID = best.loan.ID
+	case bestScore >= sm.reviewScore:
+		line.Status = domain.LineReview
+		line.LoanID = best.loan.ID
+	default:
+		line.Status = domain.LineUnmatched
+	}
+}
+
+// scoreLine scores how likely the line is a repayment of the candidate loan.
+func scoreLine(line *domain.StatementLine, candidate *loanCandidate, referenced bool, payer []string) (int, []string) {
+	score := 0
+	reasons := []string{}
+	if referenced {
+		score += referenceScore
+		reasons = append(reasons, "reference matches")
+	}
+	amount := toCents(line.Amount)
+	switch {
+	case amount == candidate.due:
+		score += installmentScore
+		reasons = append(reasons, "amount matches the next installment")
+	case amount <= toCents(candidate.loan.OutstandingBalance):
+		score += balanceScore
+		reasons = append(reasons, "amount is within the outstanding balance")
+	default:
+		reasons = append(reasons, "amount exceeds the outstanding balance")
+	}
+	if len(candidate.names) > 0 {
+		shared := 0
+		for _, name := range candidate.names {
+			if slices.Contains(payer, name) {
+				shared++
+			}
+		}
+		if shared > 0 {
+			similarity := float64(shared) / float64(len(candidate.names))
+			score += int(math.Round(nameScore * similarity))
+			reasons = append(reasons, fmt.Sprintf("payer name matches %.0f%% of the borrower's name", similarity*100))
+		}
+	}
+	return score, reasons
+}
@@ -0,0 +1,132 @@
+package usecases
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	domain "loan-tracker/Domain"
+	infrastructure "loan-tracker/Infrastructure"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fakeLoansRepo lists the loans being repaid.
+type fakeLoansRepo struct {
+	domain.LoanRepositoryInterface
+	loans []domain.Loan
+}
+
+func (fr *fakeLoansRepo) GetAllLoans(status string, order string) ([]domain.Loan, error) {
+	return fr.loans, nil
+}
+
+// fakeBorrowerRepo finds borrowers by id.
+type fakeBorrowerRepo struct {
+	domain.UserRepositoryInterface
+	users map[string]domain.User
+}
+
+func (fr *fakeBorrowerRepo) FindUserByID(id string) (domain.User, error) {
+	user, ok := fr.users[id]
+	if !ok {
+		return domain.User{}, errors.New("user not found")
+	}
+	return user, nil
+}
+
+func TestStatementMatch(t *testing.T) {
+	borrowers := &fakeBorrowerRepo{users: map[string]domain.User{}}
+	loan := func(reference string, borrower string, balance float64, schedule ...float64) domain.Loan {
+		user := domain.User{ID: primitive.NewObjectID(), User_Name: borrower}
+		borrowers.users[user.ID.Hex()] = user
+		return domain.Loan{
+			ID:                 primitive.NewObjectID(),
+			UserId:             user.ID,
+			ReferenceNumber:    reference,
+			LoanStatus:         domain.LoanDisbursed,
+			OutstandingBalance: balance,
+			Installments:       installments(schedule...),
+		}
+	}
+	abebe := loan("LN-7K3M0Q2D", "Abebe Kebede", 300, 100, 100, 100)
+	sara := loan("LN-AB12CD34", "Sara Tesfaye", 500, 250, 250)
+	girma := loan("LN-ZZ99ZZ99", "Abebe Girma", 200, 100, 100)
+	none := primitive.ObjectID{}
+
+	tests := []struct {
+		name       string
+		line       domain.StatementLine
+		match      int
+		review     int
+		status     string
+		loan       primitive.ObjectID
+		confidence int
+		reason     string
+	}{
+		{name: "reference, installment and name", line: domain.StatementLine{Reference: "LN-7K3M0Q2D", Amount: 100, Name: "Abebe Kebede"}, status: domain.LineMatched, loan: abebe.ID, confidence: 100},
+		{name: "reference and installment", line: domain.StatementLine{Reference: "LN-7K3M0Q2D", Amount: 100}, status: domain.LineMatched, loan: abebe.ID, confidence: 85},
+		{name: "reference with look-alikes in the description", line: domain.StatementLine{Description: "repayment ln 7k3moq2d", Amount: 100}, status: domain.LineMatched, loan: abebe.ID, confidence: 85},
+		{name: "loan id in the description", line: domain.StatementLine{Description: "loan " + abebe.ID.Hex(), Amount: 100}, status: domain.LineMatched, loan: abebe.ID, confidence: 85},
+		{name: "reference and part of the balance", line: domain.StatementLine{Reference: "LN-7K3M0Q2D", Amount: 50}, status: domain.LineReview, loan: abebe.ID, confidence: 70, reason: "amount is within the outstanding balance"},
+		{name: "reference and part of the balance with a lower match threshold", line: domain.StatementLine{Reference: "LN-7K3M0Q2D", Amount: 50}, match: 70, status: domain.LineMatched, loan: abebe.ID, confidence: 70},
+		{name: "reference and more than the balance", line: domain.StatementLine{Reference: "LN-7K3M0Q2D", Amount: 400, Name: "Abebe Kebede"}, status: domain.LineReview, loan: abebe.ID, confidence: 75, reason: "amount exceeds the outstanding balance"},
+		{name: "installment and full name", line: domain.StatementLine{Amount: 250, Name: "SARA TESFAYE"}, status: domain.LineReview, loan: sara.ID, confidence: 40, reason: "payer name matches 100% of the borrower's name"},
+		{name: "installment and name in the description", line: domain.StatementLine{Amount: 250, Description: "Transfer from Sara Tesfaye"}, status: domain.LineReview, loan: sara.ID, confidence: 40},
+		{name: "installment only", line: domain.StatementLine{Amount: 250}, status: domain.LineUnmatched, loan: none, confidence: 25},
+		{name: "installment only with a lower review threshold", line: domain.StatementLine{Amount: 250}, review: 25, status: domain.LineReview, loan: sara.ID, confidence: 25},
+		{name: "best of several borrowers", line: domain.StatementLine{Amount: 100, Name: "Abebe Kebede"}, status: domain.LineReview, loan: abebe.ID, confidence: 40},
+		{name: "tied borrowers", line: domain.StatementLine{Amount: 100, Name: "Abebe"}, status: domain.LineReview, confidence: 33, reason: "several loans match equally well"},
+		{name: "unknown reference falls back to amount and name", line: domain.StatementLine{Reference: "LN-QQQQQQQQ", Amount: 100, Name: "Abebe Girma"}, status: domain.LineReview, loan: girma.ID, confidence: 40},
+		{name: "nothing in common", line: domain.StatementLine{Amount: 7, Name: "Someone Else"}, status: domain.LineUnmatched, loan: none, confidence: 0, reason: "no loan matches the reference, amount or name"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := &infrastructure.Config{StatementMatchThreshold: 80, StatementReviewThreshold: 40}
+			if test.match > 0 {
+				config.StatementMatchThreshold = test.match
+			}
+			if test.review > 0 {
+				config.StatementReviewThreshold = test.review
+			}
+			su := NewStatementUseCase(nil, nil, nil, &fakeLoansRepo{loans: []domain.Loan{abebe, sara, girma}}, borrowers, config)
+			matcher, err := su.newMatcher()
+			if err != nil {
+				t.Fatal(err)
+			}
+			line := test.line
+			matcher.match(&line)
+			if line.Status != test.status || line.Confidence != test.confidence {
+				t.Errorf("match() = %s with %d, want %s with %d (%v)", line.Status, line.Confidence, test.status, test.confidence, line.Reasons)
+			}
+			if test.reason == "several loans match equally well" {
+				if line.LoanID != abebe.ID && line.LoanID != girma.ID {
+					t.Errorf("loan = %s, want one of the tied loans", line.LoanID.Hex())
+				}
+			} else if line.LoanID != test.loan {
+				t.Errorf("loan = %s, want %s", line.LoanID.Hex(), test.loan.Hex())
+			}
+			if test.reason != "" && !slices.Contains(line.Reasons, test.reason) {
+				t.Errorf("reasons = %v, want %q", line.Reasons, test.reason)
+			}
+		})
+	}
+}
+
+func TestNextInstallmentDue(t *testing.T) {
+	tests := []struct {
+		name         string
+		installments []domain.Installment
+		want         int64
+	}{
+		{name: "first installment", installments: installments(33.33, 33.33, 33.34), want: 3333},
+		{name: "partly paid installment", installments: []domain.Installment{{Amount: 33.33, PaidAmount: 33.33, Paid: true}, {Amount: 33.33, PaidAmount: 10.1}, {Amount: 33.34}}, want: 2323},
+		{name: "all paid", installments: []domain.Installment{{Amount: 50, PaidAmount: 50, Paid: true}}, want: 0},
+		{name: "no schedule", want: 0},
+	}
+	for _, test := range tests {
+		if got := nextInstallmentDue(domain.Loan{Installments: test.installments}); got != test.want {
+			t.Errorf("%s: nextInstallmentDue() = %d, want %d", test.name, got, test.want)
+		}
+	}
+}
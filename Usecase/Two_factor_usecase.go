@@ -0,0 +1,226 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	domain "loan-tracker/Domain"
+	infrastructure "loan-tracker/Infrastructure"
+	"log"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	recoveryCodeCount          = 10
+	twoFactorChallengeDuration = 5 * time.Minute
+)
+
+func (uc *UserUseCase) createTwoFactorChallenge(user *domain.User) (string, error) {
+	claims := &domain.JwtCustomClaims{
+		ID:      user.ID.Hex(),
+		Purpose: domain.TwoFactorChallengePurpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(twoFactorChallengeDuration)),
+		},
+	}
+	return uc.Tokens.CreateToken(claims)
+}
+
+// openTwoFactorSecret decrypts a TOTP secret sealed for the user.
+func (uc *UserUseCase) openTwoFactorSecret(user *domain.User, sealed string) string {
+	secret, err := uc.Secrets.Open(sealed, user.ID.Hex())
+	if err != nil {
+		log.Println("error opening two-factor secret:", err)
+		return ""
+	}
+	return secret
+}
+
+// checkTwoFactorCode accepts either a TOTP code or an unused recovery code. Accepted codes are
+// burnt on the user (the TOTP step or the recovery code), so the caller must persist the user.
+// A secret stored before secrets were encrypted is sealed at the same time.
+func (uc *UserUseCase) checkTwoFactorCode(user *domain.User, code string) bool {
+	secret := uc.openTwoFactorSecret(user, user.TwoFactorSecret)
+	if step, ok := infrastructure.ValidateTOTPCode(secret, code, time.Now()); secret != "" && ok {
+		if step <= user.TwoFactorLastStep {
+			return false
+		}
+		user.TwoFactorLastStep = step
+		if !infrastructure.IsSealed(user.TwoFactorSecret) {
+			if sealed, err := uc.Secrets.Seal(secret, user.ID.Hex()); err == nil {
+				user.TwoFactorSecret = sealed
+			}
+		}
+		return true
+	}
+	hashed := infrastructure.HashToken(code)
+	for i, recoveryCode := range user.RecoveryCodes {
+		if recoveryCode == hashed {
+			user.RecoveryCodes = append(user.RecoveryCodes[:i], user.RecoveryCodes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (uc *UserUseCase) newRecoveryCodes(user *domain.User) ([]string, error) {
+	codes, err := infrastructure.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	user.RecoveryCodes = make([]string, 0, len(codes))
+	for _, code := range codes {
+		user.RecoveryCodes = append(user.RecoveryCodes, infrastructure.HashToken(code))
+	}
+	return codes, nil
+}
+
+func (uc *UserUseCase) EnrollTwoFactor(user_id string) (domain.TwoFactorEnrollment, error) {
+	user, err := uc.UserRepo.FindUserByID(user_id)
+	if err != nil {
+		return domain.TwoFactorEnrollment{}, errors.New("user not found")
+	}
+	if user.TwoFactorEnabled {
+		return domain.TwoFactorEnrollment{}, errors.New("two-factor authentication is already enabled")
+	}
+	secret, err := infrastructure.GenerateTOTPSecret()
+	if err != nil {
+		return domain.TwoFactorEnrollment{}, errors.New("error generating two-factor secret")
+	}
+	user.TwoFactorPendingSecret, err = uc.Secrets.Seal(secret, user.ID.Hex())
+	if err != nil {
+		log.Println("error sealing two-factor secret:", err)
+		return domain.TwoFactorEnrollment{}, errors.New("error storing two-factor secret")
+	}
+	err = uc.UserRepo.UpdateUser(user)
+	if err != nil {
+		return domain.TwoFactorEnrollment{}, errors.New("error updating user")
+	}
+	return domain.TwoFactorEnrollment{
+		Secret:          secret,
+		ProvisioningURI: infrastructure.TOTPProvisioningURI(uc.Config.TwoFactorIssuer, user.Email, secret),
+	}, nil
+}
+
+func (uc *UserUseCase) ConfirmTwoFactor(user_id string, session_id string, code string, client domain.ClientInfo) ([]string, error) {
+	user, err := uc.UserRepo.FindUserByID(user_id)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	if user.TwoFactorEnabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+	if user.TwoFactorPendingSecret == "" {
+		return nil, errors.New("two-factor enrollment not started")
+	}
+	secret := uc.openTwoFactorSecret(&user, user.TwoFactorPendingSecret)
+	step, ok := infrastructure.ValidateTOTPCode(secret, code, time.Now())
+	if secret == "" || !ok {
+		return nil, errors.New("invalid two-factor code")
+	}
+	codes, err := uc.newRecoveryCodes(&user)
+	if err != nil {
+		return nil, errors.New("error generating recovery codes")
+	}
+	user.TwoFactorEnabled = true
+	user.TwoFactorSecret = user.TwoFactorPendingSecret
+	user.TwoFactorPendingSecret = ""
+	user.TwoFactorLastStep = step
+	err = uc.saveTwoFactorChange(user, domain.AuditTwoFactorEnabled, client)
+	if err != nil {
+		return nil, err
+	}
+	// The user just proved possession of the second factor on this session.
+	uc.SessionRepo.MarkSessionTwoFactorVerified(session_id)
+	return codes, nil
+}
+
+func (uc *UserUseCase) DisableTwoFactor(user_id string, code string, client domain.ClientInfo) error {
+	user, err := uc.UserRepo.FindUserByID(user_id)
+	if err != nil {
+		return errors.New("user not found")
+	}
+	if !user.TwoFactorEnabled {
+		return errors.New("two-factor authentication is not enabled")
+	}
+	if uc.Config.RequiresTwoFactor(user.Role) {
+		return errors.New("two-factor authentication is required for your role")
+	}
+	if !uc.checkTwoFactorCode(&user, code) {
+		return errors.New("invalid two-factor code")
+	}
+	user.TwoFactorEnabled = false
+	user.TwoFactorSecret = ""
+	user.TwoFactorLastStep = 0
+	user.RecoveryCodes = nil
+	err = uc.saveTwoFactorChange(user, domain.AuditTwoFactorDisabled, client)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (uc *UserUseCase) RegenerateRecoveryCodes(user_id string, code string, client domain.ClientInfo) ([]string, error) {
+	user, err := uc.UserRepo.FindUserByID(user_id)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	if !user.TwoFactorEnabled {
+		return nil, errors.New("two-factor authentication is not enabled")
+	}
+	if !uc.checkTwoFactorCode(&user, code) {
+		return nil, errors.New("invalid two-factor code")
+	}
+	codes, err := uc.newRecoveryCodes(&user)
+	if err != nil {
+		return nil, errors.New("error generating recovery codes")
+	}
+	err = uc.saveTwoFactorChange(user, domain.AuditRecoveryCodesRegenerated, client)
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// saveTwoFactorChange stores a change the user made to their own second factor and its audit record
+// in one unit of work.
+func (uc *UserUseCase) saveTwoFactorChange(user domain.User, action string, client domain.ClientInfo) error {
+	return uc.UnitOfWork.Do(func(ctx context.Context) error {
+		if err := uc.UserRepo.UpdateUserWithContext(ctx, user); err != nil {
+			return errors.New("error updating user")
+		}
+		if err := recordAction(ctx, uc.Events, newAuditRecord(domain.AuditSecurity, action, user.ID.Hex(), "user", user.ID.Hex(), client, nil)); err != nil {
+			return errors.New("error recording audit action")
+		}
+		return nil
+	})
+}
+
+// VerifyTwoFactorLogin exchanges the challenge token returned by Login and a TOTP or recovery code for a session.
+func (uc *UserUseCase) VerifyTwoFactorLogin(challenge_token string, code string, client domain.ClientInfo) (domain.LoginResponse, error) {
+	claims, err := uc.Tokens.ExtractClaimsFromToken(challenge_token)
+	if err != nil || claims.Purpose != domain.TwoFactorChallengePurpose {
+		return domain.LoginResponse{}, errors.New("invalid or expired challenge")
+	}
+	user, err := uc.UserRepo.FindUserByID(claims.ID)
+	if err != nil || !user.TwoFactorEnabled {
+		return domain.LoginResponse{}, errors.New("invalid or expired challenge")
+	}
+	if err := uc.checkLoginAllowed(accountAttemptKey(user.Email), ipAttemptKey(client.IP)); err != nil {
+		return domain.LoginResponse{}, err
+	}
+	if !uc.checkTwoFactorCode(&user, code) {
+		uc.recordLoginFailure(user.Email, client, &user)
+		return domain.LoginResponse{}, errors.New("invalid two-factor code")
+	}
+	uc.LoginAttempts.Reset(accountAttemptKey(user.Email))
+	if user.IsSuspended {
+		return domain.LoginResponse{}, errors.New("account suspended: contact support")
+	}
+	err = uc.UserRepo.UpdateUser(user)
+	if err != nil {
+		return domain.LoginResponse{}, errors.New("error updating user")
+	}
+	return uc.startSession(&user, client, true)
+}
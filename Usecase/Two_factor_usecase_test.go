@@ -0,0 +1,61 @@
+package usecases
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	domain "loan-tracker/Domain"
+	infrastructure "loan-tracker/Infrastructure"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCheckTwoFactorCode(t *testing.T) {
+	secrets, err := infrastructure.NewSecretBox(&infrastructure.Config{TwoFactorEncryptionKey: base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uc := &UserUseCase{Secrets: secrets}
+	secret, err := infrastructure.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := domain.User{ID: primitive.NewObjectID(), TwoFactorSecret: secret, RecoveryCodes: []string{infrastructure.HashToken("abcde-12345")}}
+	now := time.Now()
+	code, err := infrastructure.GenerateTOTPCode(secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous, err := infrastructure.GenerateTOTPCode(secret, now.Add(-infrastructure.TOTPPeriod*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !uc.checkTwoFactorCode(&user, code) {
+		t.Fatal("current code refused")
+	}
+	if user.TwoFactorLastStep < infrastructure.TOTPStep(now)-1 {
+		t.Errorf("TwoFactorLastStep = %d, want the step of the code", user.TwoFactorLastStep)
+	}
+	if !infrastructure.IsSealed(user.TwoFactorSecret) {
+		t.Error("a secret stored before encryption was not sealed")
+	}
+	if uc.checkTwoFactorCode(&user, code) {
+		t.Error("the same code was accepted twice")
+	}
+	if previous != code && uc.checkTwoFactorCode(&user, previous) {
+		t.Error("a code of an earlier step was accepted after a later one")
+	}
+
+	if !uc.checkTwoFactorCode(&user, "abcde-12345") {
+		t.Error("recovery code refused")
+	}
+	if uc.checkTwoFactorCode(&user, "abcde-12345") {
+		t.Error("the same recovery code was accepted twice")
+	}
+	if uc.checkTwoFactorCode(&user, "fghij-67890") {
+		t.Error("an unknown recovery code was accepted")
+	}
+}
@@ -1,122 +1,297 @@
 package usecases
 
 import (
+	"context"
+	"crypto/subtle"
 	"errors"
+	"fmt"
 	domain "loan-tracker/Domain"
 	infrastructure "loan-tracker/Infrastructure"
+	"log"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type UserUseCase struct {
 	UserRepo domain.UserRepositoryInterface
+	SessionRepo domain.SessionRepositoryInterface
+	LoginAttempts domain.LoginAttemptStoreInterface
 	PassService infrastructure.PasswordService
+	Tokens *infrastructure.TokenService
+	Secrets *infrastructure.SecretBox
 	Config *infrastructure.Config
+	LoanRepo domain.LoanRepositoryInterface
+	UnitOfWork domain.UnitOfWorkInterface
+	Emails domain.EmailTemplatesInterface
+	Events domain.EventBusInterface
 }
 
 
-func NewUserUseCase(userRepo domain.UserRepositoryInterface, passwordService infrastructure.PasswordService, config *infrastructure.Config) *UserUseCase {
+func NewUserUseCase(userRepo domain.UserRepositoryInterface, sessionRepo domain.SessionRepositoryInterface, loginAttempts domain.LoginAttemptStoreInterface, passwordService infrastructure.PasswordService, tokens *infrastructure.TokenService, secrets *infrastructure.SecretBox, config *infrastructure.Config, loanRepo domain.LoanRepositoryInterface, unitOfWork domain.UnitOfWorkInterface, emails domain.EmailTemplatesInterface, events domain.EventBusInterface) *UserUseCase {
 	return &UserUseCase{
 		UserRepo: userRepo,
+		SessionRepo: sessionRepo,
+		LoginAttempts: loginAttempts,
 		PassService: passwordService,
+		Tokens: tokens,
+		Secrets: secrets,
 		Config: config,
+		LoanRepo: loanRepo,
+		UnitOfWork: unitOfWork,
+		Emails: emails,
+		Events: events,
 	}
 }
 
 func (uc *UserUseCase) RegisterUser(user domain.User) error {
+	user.Email = infrastructure.NormalizeEmail(user.Email)
 	if user.Email == "" || user.User_Name == "" || user.Password == "" {
 		return errors.New("all fields are required")
 	}
 	if infrastructure.ValidateEmail(user.Email) != nil {
 		return errors.New("invalid email format")
 	}
-	if infrastructure.ValidatePassword(user.Password) != nil {
-		return errors.New("invalid password format")
+	if err := uc.PassService.ValidatePassword(user.Password, user.User_Name, user.Email); err != nil {
+		return err
 	}
 
 	existingUser, err := uc.UserRepo.FindUserByEmail(user.Email)
 
 	if err == nil && existingUser.Email != "" && !existingUser.IsVerified{
-		return errors.New("user already exists: Verify your account")
+		return errors.New("user already exists: Verify your account or request a new verification email")
 	}else if err == nil{
 		return errors.New("user already exists: Try with another email")
 	}
+	if _, err := uc.UserRepo.FindUserByUserName(user.User_Name); err == nil {
+		return errors.New("user name already taken")
+	}
+	if user.Locale != "" {
+		user.Locale = infrastructure.NormalizeLocale(user.Locale)
+		if !uc.Emails.SupportsLocale(user.Locale) {
+			return errors.New("unsupported locale")
+		}
+	}
 
 	hashedPassword, _ := uc.PassService.HashPassword(user.Password)
 	user.Password = hashedPassword
 
-	token, err  := infrastructure.GenerateVerificationToken()
-	if err != nil{
-		return errors.New("error generating verification token")
-	}
-	err = infrastructure.SendVerificationEmail(user.Email, token)
+	link, err := uc.prepareVerification(&user)
 	if err != nil {
-		return errors.New("error sending verification email")
+		return err
 	}
 
+	user.ID = primitive.NewObjectID()
 	user.IsVerified = false
 	user.Created_At = time.Now()
-	user.VerificationToken = token
-	user.VerificationExpires = time.Now().Add(time.Hour * 24)
 	user.Role = "user"
-	err = uc.UserRepo.RegisterUser(user)
+	// The user and the verification email are stored together: no email for a user that was
+	// never created, and no user without its email.
+	return uc.UnitOfWork.Do(func(ctx context.Context) error {
+		err := uc.UserRepo.RegisterUserWithContext(ctx, user)
+		switch {
+		case errors.Is(err, domain.ErrEmailTaken):
+			return errors.New("user already exists: Try with another email")
+		case errors.Is(err, domain.ErrUserNameTaken):
+			return errors.New("user name already taken")
+		case err != nil:
+			return errors.New("error creating user")
+		}
+		if err := uc.Events.Publish(ctx, domain.UserRegistered{User: user, VerificationLink: link}); err != nil {
+			return errors.New("error queueing verification email")
+		}
+		return nil
+	})
+}
+
+// prepareVerification issues a fresh verification token, keeps only the token's hash on the user and
+// returns the link carrying it. The caller stores the user and publishes the link together.
+func (uc *UserUseCase) prepareVerification(user *domain.User) (string, error) {
+	token, err  := infrastructure.GenerateVerificationToken()
+	if err != nil{
+		return "", errors.New("error generating verification token")
+	}
+	now := time.Now()
+	expires := now.Add(time.Hour * 24)
+	link := infrastructure.VerificationLink(uc.Config.PublicBaseURL, user.Email, token, expires, uc.Config.VerificationLinkSecret)
+
+	if now.Sub(user.VerificationSentAt) > time.Hour * 24 {
+		user.VerificationSendCount = 0
+	}
+	user.VerificationSendCount++
+	user.VerificationSentAt = now
+	user.VerificationToken = infrastructure.HashToken(token)
+	user.VerificationExpires = expires
+	return link, nil
+}
+
+
+// ResendVerificationEmail replaces the verification token of an unverified account and emails a new link.
+// Like ResetPassword it succeeds silently for unknown or already verified emails, and for requests over
+// the resend limits, and when the new link cannot be stored or queued, so the responses do not tell which
+// addresses have pending accounts.
+func (uc *UserUseCase) ResendVerificationEmail(email string) error {
+	user, err := uc.UserRepo.FindUserByEmail(email)
+	if err != nil || user.IsVerified {
+		return nil
+	}
+
+	now := time.Now()
+	cooldown := time.Duration(uc.Config.VerificationResendCooldownSeconds) * time.Second
+	if now.Sub(user.VerificationSentAt) < cooldown {
+		return nil
+	}
+	if now.Sub(user.VerificationSentAt) < time.Hour * 24 && user.VerificationSendCount >= uc.Config.VerificationResendDailyLimit {
+		return nil
+	}
+
+	link, err := uc.prepareVerification(&user)
 	if err != nil {
-		return errors.New("error creating user")
+		log.Println("error preparing verification:", err)
+		return nil
+	}
+	err = uc.UnitOfWork.Do(func(ctx context.Context) error {
+		if err := uc.UserRepo.UpdateUserWithContext(ctx, user); err != nil {
+			return fmt.Errorf("error updating user: %w", err)
+		}
+		if err := uc.Events.Publish(ctx, domain.VerificationRequested{User: user, VerificationLink: link}); err != nil {
+			return fmt.Errorf("error queueing verification email: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println("error resending verification email:", err)
 	}
 	return nil
 }
 
 
+func (uc *UserUseCase) VerifyEmail(email string, token string, expires string, signature string)error{
+	if uc.Config.VerificationLinkSecret != "" && !infrastructure.ValidVerificationSignature(email, token, expires, signature, uc.Config.VerificationLinkSecret) {
+		return errors.New("invalid or expired verification link")
+	}
 
-func (uc *UserUseCase) VerifyEmail(email string, token string)error{
 	user, err := uc.UserRepo.FindUserByEmail(email)
 	if err != nil {
-		return errors.New("user not found")
+		return errors.New("invalid or expired verification link")
 	}
-
-	if user.VerificationToken != token {
-		return errors.New("invalid verification token")
+	if user.IsVerified {
+		return errors.New("account already verified")
+	}
+	if user.VerificationToken == "" || subtle.ConstantTimeCompare([]byte(user.VerificationToken), []byte(infrastructure.HashToken(token))) != 1 {
+		return errors.New("invalid or expired verification link")
 	}
 	if user.VerificationExpires.Before(time.Now()) {
 		return errors.New("verification token expired. Please request a new one")
 	}
 
-	user.IsVerified = true
-	user.VerificationToken = ""
-	user.VerificationExpires = time.Time{}
-	err = uc.UserRepo.UpdateUser(user)
+	err = uc.UnitOfWork.Do(func(ctx context.Context) error {
+		return markVerified(ctx, uc.UserRepo, uc.Events, user, "")
+	})
 	if err != nil {
 		return errors.New("error verifying user")
 	}
 	return nil
 }
 
-func (uc *UserUseCase) Login(user domain.User)(domain.LoginResponse, error){
+
+// markVerified marks the user's email as verified and publishes UserVerified in the unit of work
+// of ctx. verified_by is the admin who verified the user, empty when they followed their link.
+func markVerified(ctx context.Context, userRepo domain.UserRepositoryInterface, events domain.EventBusInterface, user domain.User, verified_by string) error {
+	user.IsVerified = true
+	user.VerificationToken = ""
+	user.VerificationExpires = time.Time{}
+	if err := userRepo.UpdateUserWithContext(ctx, user); err != nil {
+		return err
+	}
+	return events.Publish(ctx, domain.UserVerified{User: user, VerifiedAt: time.Now(), VerifiedBy: verified_by})
+}
+
+func (uc *UserUseCase) Login(user domain.User, client domain.ClientInfo)(domain.LoginResponse, error){
 	var newUser domain.User
 	var err error
 	if user.Email == "" || user.Password == "" {
 		return domain.LoginResponse{}, errors.New("all fields are required")
 	}
+	if err := uc.checkLoginAllowed(accountAttemptKey(user.Email), ipAttemptKey(client.IP)); err != nil {
+		return domain.LoginResponse{}, err
+	}
 	if user.Email != ""{
 		newUser, err = uc.UserRepo.FindUserByEmail(user.Email)	
 	}else if user.User_Name != ""{
 		newUser, err = uc.UserRepo.FindUserByUserName(user.User_Name)
 	}
 	if err != nil{
-		return domain.LoginResponse{}, errors.New("user not found")
+		uc.PassService.CompareDummyPassword(user.Password)
+		uc.recordLoginFailure(user.Email, client, nil)
+		return domain.LoginResponse{}, errInvalidCredentials
+	}
+	if !uc.PassService.ComparePassword(user.Password, newUser.Password){
+		uc.recordLoginFailure(user.Email, client, &newUser)
+		return domain.LoginResponse{}, errInvalidCredentials
+	}
+	uc.LoginAttempts.Reset(accountAttemptKey(user.Email))
+	if newUser.IsSuspended{
+		return domain.LoginResponse{}, errors.New("account suspended: contact support")
+	}
+	if uc.PassService.NeedsRehash(newUser.Password){
+		uc.rehashPassword(&newUser, user.Password)
 	}
 	if !newUser.IsVerified{
 		return domain.LoginResponse{}, errors.New("user not verified. Please Verify Your Account")
 	}
-	if !uc.PassService.ComparePassword(user.Password, newUser.Password){
-		return domain.LoginResponse{}, errors.New("invalid credentials: Password does not match")
+
+	if newUser.TwoFactorEnabled{
+		challengeToken, err := uc.createTwoFactorChallenge(&newUser)
+		if err != nil {
+			return domain.LoginResponse{}, errors.New("error creating two-factor challenge")
+		}
+		return domain.LoginResponse{
+			TwoFactorRequired: true,
+			ChallengeToken: challengeToken,
+		}, nil
+	}
+	return uc.startSession(&newUser, client, false)
+}
+
+// startSession records a new session for the user, with the audit record of the login in the same
+// unit of work, and issues the token pair bound to it.
+func (uc *UserUseCase) startSession(user *domain.User, client domain.ClientInfo, twoFactorVerified bool)(domain.LoginResponse, error){
+	now := time.Now()
+	var session domain.Session
+	err := uc.UnitOfWork.Do(func(ctx context.Context) error {
+		var err error
+		session, err = uc.SessionRepo.CreateSessionWithContext(ctx, domain.Session{
+			UserID: user.ID,
+			Device: client.Device,
+			IP: client.IP,
+			UserAgent: client.UserAgent,
+			Created_At: now,
+			LastUsed_At: now,
+			ExpiresAt: now.Add(time.Hour * time.Duration(uc.Config.RefreshTokenExpiryHour)),
+			TwoFactorVerified: twoFactorVerified,
+		})
+		if err != nil {
+			return errors.New("error creating session")
+		}
+		err = recordAction(ctx, uc.Events, newAuditRecord(domain.AuditSecurity, domain.AuditLoginSucceeded, user.ID.Hex(), "session", session.ID.Hex(), client, []domain.AuditChange{
+			{Field: "two_factor_verified", After: strconv.FormatBool(twoFactorVerified)},
+		}))
+		if err != nil {
+			return errors.New("error recording audit action")
+		}
+		return nil
+	})
+	if err != nil {
+		return domain.LoginResponse{}, err
 	}
-	accessToken, err := uc.CreateAccessToken(&newUser, uc.Config.AccessTokenSecret, uc.Config.AccessTokenExpiryHour)
+	accessToken, err := uc.CreateAccessToken(user, session.ID.Hex(), uc.Config.AccessTokenExpiryHour)
 	if err != nil {
 		return domain.LoginResponse{}, errors.New("error creating access token")
 	}
-	refreshToken, err  := uc.CreateRefreshToken(&newUser, uc.Config.RefreshTokenSecret, uc.Config.RefreshTokenExpiryHour)
+	refreshToken, err  := uc.CreateRefreshToken(user, session.ID.Hex(), uc.Config.RefreshTokenExpiryHour)
 	if err != nil {
 		return domain.LoginResponse{}, errors.New("error creating refresh token")
 	}
@@ -127,41 +302,43 @@ func (uc *UserUseCase) Login(user domain.User)(domain.LoginResponse, error){
 }
 
 
-func (uc *UserUseCase) CreateAccessToken(user *domain.User, secret string, expiry int) (accessToken string, err error) {
+func (uc *UserUseCase) CreateAccessToken(user *domain.User, session_id string, expiry int) (accessToken string, err error) {
 	exp := time.Now().Add(time.Hour * time.Duration(expiry))
 	claims := &domain.JwtCustomClaims{
 		ID: user.ID.Hex(),
+		SessionID: session_id,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(exp),
 		},
 	}
-	return infrastructure.CreateToken(claims, secret)
+	return uc.Tokens.CreateToken(claims)
 }
 
-func (uc *UserUseCase) CreateRefreshToken(user *domain.User, secret string, expiry int) (refreshToken string, err error) {
+func (uc *UserUseCase) CreateRefreshToken(user *domain.User, session_id string, expiry int) (refreshToken string, err error) {
 	exp := time.Now().Add(time.Hour * time.Duration(expiry))
 
 	claims := &domain.JwtCustomClaims{
 		ID: user.ID.Hex(),
+		SessionID: session_id,
+		Purpose: domain.RefreshTokenPurpose,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(exp),
 		},
 	}
-	return infrastructure.CreateToken(claims, secret)
+	return uc.Tokens.CreateToken(claims)
 }
 
 
 func (uc *UserUseCase) RefreshToken(request domain.RefreshTokenRequest, user_id string) (domain.RefreshTokenResponse, error) {
-	id, err := infrastructure.ExtractIDFromToken(request.RefreshToken, uc.Config.RefreshTokenSecret)
-	if err != nil {
-		return domain.RefreshTokenResponse{}, errors.New("user not found")
+	claims, err := uc.Tokens.ExtractClaimsFromToken(request.RefreshToken)
+	if err != nil || claims.Purpose != domain.RefreshTokenPurpose {
+		return domain.RefreshTokenResponse{}, errors.New("session expired")
 	}
-	valid, err := infrastructure.IsAuthorized(request.RefreshToken, uc.Config.RefreshTokenSecret)
-	if err != nil {
-		return domain.RefreshTokenResponse{}, errors.New("user not found")
+	if claims.ID != user_id {
+		return domain.RefreshTokenResponse{}, errors.New("session expired")
 	}
-
-	if id != user_id && !valid {
+	session, err := uc.SessionRepo.FindSessionByID(claims.SessionID)
+	if err != nil || session.Revoked || session.ExpiresAt.Before(time.Now()) {
 		return domain.RefreshTokenResponse{}, errors.New("session expired")
 	}
 
@@ -169,85 +346,134 @@ func (uc *UserUseCase) RefreshToken(request domain.RefreshTokenRequest, user_id
 	if err != nil {
 		return domain.RefreshTokenResponse{}, errors.New("user not found")
 	}
-	accessToken, err := uc.CreateAccessToken(&user, uc.Config.AccessTokenSecret, uc.Config.AccessTokenExpiryHour)
+	accessToken, err := uc.CreateAccessToken(&user, claims.SessionID, uc.Config.AccessTokenExpiryHour)
 	if err != nil {
 		return domain.RefreshTokenResponse{}, errors.New(err.Error())
 	}
+	uc.SessionRepo.TouchSession(claims.SessionID, time.Now())
 	return domain.RefreshTokenResponse{
 		AccessToken:  accessToken,
 	}, nil
 }
 
 
+func (uc *UserUseCase) Logout(session_id string, user_id string) error{
+	return uc.RevokeSession(session_id, user_id)
+}
+
+func (uc *UserUseCase) GetSessions(user_id string, current_session string) ([]domain.Session, error){
+	sessions, err := uc.SessionRepo.FindSessionsByUserID(user_id)
+	if err != nil {
+		return nil, errors.New("error retrieving sessions")
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID.Hex() == current_session
+	}
+	return sessions, nil
+}
+
+func (uc *UserUseCase) RevokeSession(id string, user_id string) error{
+	session, err := uc.SessionRepo.FindSessionByID(id)
+	if err != nil {
+		return errors.New("session not found")
+	}
+	if session.UserID.Hex() != user_id {
+		return errors.New("session not found")
+	}
+	if session.Revoked {
+		return nil
+	}
+	err = uc.SessionRepo.RevokeSession(id)
+	if err != nil {
+		return errors.New("error revoking session")
+	}
+	return nil
+}
+
+
 func (uc *UserUseCase) GetUserProfile(id string)(domain.UserProfile, error){
 	user, err := uc.UserRepo.FindUserByID(id)
 	if err != nil {
 		return domain.UserProfile{}, errors.New("user not found")
 	}
-	return domain.UserProfile{
-		ID: user.ID,
-		User_Name: user.User_Name,
-		Email: user.Email,
-		Contact: user.Contact,
-		Created_At: user.Created_At,
-	}, nil
+	return userProfile(user), nil
+}
+
+// ResetPassword emails a single-use reset link. It reports success whether or not the email
+// belongs to an account, and the link is issued in the background so the response takes as long
+// either way; the endpoint cannot be used to discover registered addresses. Failures are logged.
+func (uc *UserUseCase) ResetPassword(email string) error{
+	go uc.issuePasswordResetByEmail(email)
+	return nil
 }
 
-func (uc *UserUseCase) ResetPassword(email string, user_id string) error{
+func (uc *UserUseCase) issuePasswordResetByEmail(email string) {
 	user, err := uc.UserRepo.FindUserByEmail(email)
 	if err != nil {
-		return errors.New("user not found")
+		return
 	}
-	if user.ID.Hex() != user_id {
-		return errors.New("unauthorized: User not found")
+	err = uc.UnitOfWork.Do(func(ctx context.Context) error {
+		return issuePasswordReset(ctx, uc.UserRepo, uc.Events, uc.Config, user, "")
+	})
+	if err != nil {
+		log.Println("error issuing password reset:", err)
 	}
+}
 
+// issuePasswordReset stores the hash of a new single-use reset token on the user and publishes the
+// reset link in the unit of work of ctx. requested_by is the admin who asked for the reset, if any.
+func issuePasswordReset(ctx context.Context, userRepo domain.UserRepositoryInterface, events domain.EventBusInterface, config *infrastructure.Config, user domain.User, requested_by string) error{
 	token, err := infrastructure.GenerateVerificationToken()
 	if err != nil {
 		return errors.New("error generating token")
 	}
-	err = infrastructure.SendResetPasswordVerificationEmail(email, token)
-	if err != nil {
-		return errors.New("error sending email")
+	user.ResetPasswordToken = infrastructure.HashToken(token)
+	user.ResetPasswordExpires = time.Now().Add(infrastructure.TokenTTlL)
+	event := domain.PasswordResetRequested{
+		User:        user,
+		ResetLink:   infrastructure.PasswordResetLink(config.PublicBaseURL, user.Email, token),
+		RequestedBy: requested_by,
 	}
-
-	user.ResetPasswordToken = token
-	user.ResetPasswordExpires = time.Now().Add(time.Hour * 24)
-	err = uc.UserRepo.UpdateUser(user)
-	if err != nil {
+	if err := userRepo.UpdateUserWithContext(ctx, user); err != nil {
 		return errors.New("error updating user")
 	}
+	if err := events.Publish(ctx, event); err != nil {
+		return errors.New("error queueing password reset email")
+	}
 	return nil
 }
 
 
-func (uc *UserUseCase) ResetPasswordVerify(email string, token string, user_id string, password string) error{
+func (uc *UserUseCase) ResetPasswordVerify(email string, token string, password string, client domain.ClientInfo) error{
 	user, err := uc.UserRepo.FindUserByEmail(email)
 	if err != nil {
-		return errors.New("user not found")
+		return errors.New("invalid or expired token")
 	}
-	if user.ID.Hex() != user_id {
-		return errors.New("unauthorized: User not found")
-	}
-
-	if err := infrastructure.ValidatePassword(password); err != nil {
-		return errors.New("invalid password format")
-	}
-
-	if user.ResetPasswordToken != token {
-		return errors.New("invalid token")
+	if user.ResetPasswordToken == "" || subtle.ConstantTimeCompare([]byte(user.ResetPasswordToken), []byte(infrastructure.HashToken(token))) != 1 {
+		return errors.New("invalid or expired token")
 	}
 	if user.ResetPasswordExpires.Before(time.Now()) {
-		return errors.New("token expired")
+		return errors.New("invalid or expired token")
 	}
-	newPassword, err := uc.PassService.HashPassword(password)
-	if err != nil{
-		return errors.New("error hashing password")
+	if err := uc.PassService.ValidatePassword(password, user.User_Name, user.Email); err != nil {
+		return err
+	}
+	if err := uc.setPassword(&user, password); err != nil {
+		return err
 	}
-	user.Password = newPassword
 	user.ResetPasswordToken = ""
 	user.ResetPasswordExpires = time.Time{}
 
-	err = uc.UserRepo.UpdateUser(user)
+	err = uc.savePasswordChange(user, domain.AuditPasswordReset, client)
+	if err != nil {
+		return err
+	}
+
+	// Whoever knew the old password must not stay signed in.
+	err = uc.SessionRepo.RevokeUserSessions(user.ID.Hex(), "")
+	if err != nil {
+		return errors.New("error revoking sessions")
+	}
+	uc.LoginAttempts.Reset(accountAttemptKey(user.Email))
 	return nil
-}
\ No newline at end of file
+}
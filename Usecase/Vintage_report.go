@@ -0,0 +1,113 @@
+package usecases
+
+import (
+	"errors"
+	domain "loan-tracker/Domain"
+	"time"
+)
+
+// vintageTail is how many months a cohort is followed past its longest schedule, long enough for
+// a loan left unpaid after its last installment to turn 90 days past due.
+const vintageTail = 4
+
+// GetVintages groups the loans disbursed in the filter's range by the month they were disbursed in
+// and follows each cohort, month on book after month on book, up to the last month that ended.
+func (ru *ReportUseCase) GetVintages(filter domain.ReportFilter, user_id string) (domain.VintageReport, error) {
+	if err := ru.validateFilter(filter); err != nil {
+		return domain.VintageReport{}, err
+	}
+	loans, err := ru.ReportRepo.VintageLoans(filter)
+	if err != nil {
+		return domain.VintageReport{}, errors.New("error getting vintages")
+	}
+	report := domain.VintageReport{
+		AsOf:    time.Now().UTC(),
+		Cohorts: []domain.VintageCohort{},
+	}
+	// The loans come sorted by disbursement, so each cohort is a run of them.
+	for start := 0; start < len(loans); {
+		month := startOfMonth(loans[start].DisbursedAt)
+		end := start
+		for end < len(loans) && startOfMonth(loans[end].DisbursedAt).Equal(month) {
+			end++
+		}
+		report.Cohorts = append(report.Cohorts, vintageCohort(month, loans[start:end], report.AsOf))
+		start = end
+	}
+	return report, nil
+}
+
+func startOfMonth(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// vintageCohort observes the loans of a cohort at the end of each month on book: month on book 1
+// ends with the month after the disbursement month.
+func vintageCohort(month time.Time, loans []domain.VintageLoan, as_of time.Time) domain.VintageCohort {
+	cohort := domain.VintageCohort{
+		Cohort: month.Format("2006-01"),
+		Loans:  len(loans),
+		Points: []domain.VintagePoint{},
+	}
+	var disbursed int64
+	longest := 0
+	for _, loan := range loans {
+		disbursed += toCents(loan.Amount)
+		longest = max(longest, len(loan.Installments))
+	}
+	cohort.DisbursedVolume = float64(disbursed) / 100
+	for mob := 1; mob <= longest+vintageTail; mob++ {
+		observed := month.AddDate(0, mob+1, 0)
+		if observed.After(as_of) {
+			break
+		}
+		var repaid, outstanding, late30, late90 int64
+		for _, loan := range loans {
+			paid, owed, days := vintageState(loan, observed)
+			repaid += paid
+			outstanding += owed
+			if days > 30 {
+				late30 += owed
+			}
+			if days > 90 {
+				late90 += owed
+			}
+		}
+		cohort.Points = append(cohort.Points, domain.VintagePoint{
+			MonthOnBook:          mob,
+			ObservedAt:           observed,
+			RepaymentRate:        ratio(float64(repaid), float64(disbursed)),
+			Delinquent30Rate:     ratio(float64(late30), float64(disbursed)),
+			Delinquent90Rate:     ratio(float64(late90), float64(disbursed)),
+			OutstandingPrincipal: float64(outstanding) / 100,
+		})
+	}
+	return cohort
+}
+
+// vintageState rebuilds a loan as it stood at a time: the cents repaid and still owed, and how many
+// days its oldest unpaid installment was past due. Payments pay installments off in order, as
+// applyRepayment does.
+func vintageState(loan domain.VintageLoan, at time.Time) (int64, int64, int) {
+	amount := toCents(loan.Amount)
+	var paid int64
+	for _, payment := range loan.Payments {
+		if payment.PostedAt.Before(at) {
+			paid += toCents(payment.Amount)
+		}
+	}
+	paid = min(paid, amount)
+	days := 0
+	var scheduled int64
+	for _, installment := range loan.Installments {
+		scheduled += toCents(installment.Amount)
+		if scheduled > paid {
+			if installment.DueDate.Before(at) {
+				days = int(at.Sub(installment.DueDate).Hours() / 24)
+			}
+			break
+		}
+	}
+	return paid, amount - paid, days
+}
@@ -0,0 +1,83 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	domain "loan-tracker/Domain"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WebhookPublisher queues events for the webhook endpoints subscribed to them. The event is
+// serialized once, so every endpoint, retry and replay gets the same id and body.
+type WebhookPublisher struct {
+	Webhooks domain.WebhookRepositoryInterface
+	Outbox   domain.OutboxRepositoryInterface
+}
+
+func NewWebhookPublisher(webhooks domain.WebhookRepositoryInterface, outbox domain.OutboxRepositoryInterface) *WebhookPublisher {
+	return &WebhookPublisher{
+		Webhooks: webhooks,
+		Outbox:   outbox,
+	}
+}
+
+func (wp *WebhookPublisher) Publish(ctx context.Context, event string, data any) error {
+	endpoints, err := wp.Webhooks.FindSubscribed(ctx, event)
+	if err != nil || len(endpoints) == 0 {
+		return err
+	}
+	id := "evt_" + primitive.NewObjectID().Hex()
+	body, err := json.Marshal(domain.WebhookEvent{
+		ID:        id,
+		Type:      event,
+		Version:   domain.WebhookSchemaVersion,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+	for _, endpoint := range endpoints {
+		err := wp.Outbox.EnqueueMessage(ctx, domain.OutboxMessage{
+			Channel: domain.ChannelWebhook,
+			Webhook: &domain.WebhookDelivery{
+				EndpointID: endpoint.ID,
+				EventID:    id,
+				Event:      event,
+				Body:       string(body),
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// optionalTime returns nil for the zero time so it is left out of event payloads.
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+func webhookLoan(loan domain.Loan) domain.WebhookLoan {
+	return domain.WebhookLoan{
+		LoanID:             loan.ID.Hex(),
+		ReferenceNumber:    loanReference(loan),
+		UserID:             loan.UserId.Hex(),
+		Status:             loan.LoanStatus,
+		Product:            loan.Product,
+		Amount:             loan.Amount,
+		TermMonths:         loan.TermMonths,
+		OutstandingBalance: loan.OutstandingBalance,
+		RejectionReason:    loan.RejectionReason,
+		CreatedAt:          loan.Created_at.UTC(),
+		DecidedAt:          optionalTime(timeValue(loan.DecidedAt)),
+		DisbursedAt:        optionalTime(timeValue(loan.DisbursedAt)),
+	}
+}
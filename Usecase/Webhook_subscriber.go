@@ -0,0 +1,79 @@
+package usecases
+
+import (
+	"context"
+	domain "loan-tracker/Domain"
+)
+
+// loanStatusEvents are the webhook events of the loan statuses an admin sets.
+var loanStatusEvents = map[string]string{
+	domain.LoanApproved:  domain.EventLoanApproved,
+	domain.LoanRejected:  domain.EventLoanRejected,
+	domain.LoanDisbursed: domain.EventLoanDisbursed,
+}
+
+// WebhookSubscriber turns domain events into webhook events. Its handlers are synchronous so the
+// deliveries are queued within the publisher's unit of work.
+type WebhookSubscriber struct {
+	Webhooks domain.WebhookPublisherInterface
+}
+
+func NewWebhookSubscriber(webhooks domain.WebhookPublisherInterface) *WebhookSubscriber {
+	return &WebhookSubscriber{
+		Webhooks: webhooks,
+	}
+}
+
+func (ws *WebhookSubscriber) Register(bus *EventBus) {
+	Subscribe(bus, "webhooks", ws.userVerified)
+	Subscribe(bus, "webhooks", ws.loanSubmitted)
+	Subscribe(bus, "webhooks", ws.loanStatusChanged)
+	Subscribe(bus, "webhooks", ws.paymentPosted)
+	Subscribe(bus, "webhooks", ws.paymentReminderDue)
+}
+
+func (ws *WebhookSubscriber) userVerified(ctx context.Context, event domain.UserVerified) error {
+	return ws.Webhooks.Publish(ctx, domain.EventUserVerified, domain.WebhookUser{
+		UserID:     event.User.ID.Hex(),
+		VerifiedAt: event.VerifiedAt.UTC(),
+	})
+}
+
+func (ws *WebhookSubscriber) loanSubmitted(ctx context.Context, event domain.LoanSubmitted) error {
+	return ws.Webhooks.Publish(ctx, domain.EventLoanCreated, webhookLoan(event.Loan))
+}
+
+func (ws *WebhookSubscriber) loanStatusChanged(ctx context.Context, event domain.LoanStatusChanged) error {
+	name, ok := loanStatusEvents[event.Loan.LoanStatus]
+	if !ok {
+		return nil
+	}
+	return ws.Webhooks.Publish(ctx, name, webhookLoan(event.Loan))
+}
+
+func (ws *WebhookSubscriber) paymentPosted(ctx context.Context, event domain.PaymentPosted) error {
+	return ws.Webhooks.Publish(ctx, domain.EventPaymentReceived, domain.WebhookPayment{
+		LoanID:             event.Loan.ID.Hex(),
+		UserID:             event.Loan.UserId.Hex(),
+		Amount:             event.Payment.Amount,
+		OutstandingBalance: event.Loan.OutstandingBalance,
+		TransactionID:      event.Payment.TransactionID,
+		ReceivedAt:         event.Payment.Received_At.UTC(),
+	})
+}
+
+// paymentReminderDue publishes loan.overdue for the stages after the due date.
+func (ws *WebhookSubscriber) paymentReminderDue(ctx context.Context, event domain.PaymentReminderDue) error {
+	if event.Offset <= 0 {
+		return nil
+	}
+	installment := event.Installment
+	return ws.Webhooks.Publish(ctx, domain.EventLoanOverdue, domain.WebhookOverdue{
+		LoanID:      event.Loan.ID.Hex(),
+		UserID:      event.Borrower.ID.Hex(),
+		Installment: installment.Number,
+		DueDate:     installment.DueDate.UTC(),
+		AmountDue:   installment.Amount - installment.PaidAmount,
+		DaysOverdue: event.Offset,
+	})
+}
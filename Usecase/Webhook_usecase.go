@@ -0,0 +1,169 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	domain "loan-tracker/Domain"
+	infrastructure "loan-tracker/Infrastructure"
+	utils "loan-tracker/Utils"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const webhookLookupTimeout = 5 * time.Second
+
+// WebhookUseCase lets admins manage webhook endpoints and inspect and replay their deliveries.
+type WebhookUseCase struct {
+	WebhookRepo domain.WebhookRepositoryInterface
+	Outbox      domain.OutboxRepositoryInterface
+}
+
+func NewWebhookUseCase(webhookRepo domain.WebhookRepositoryInterface, outbox domain.OutboxRepositoryInterface) *WebhookUseCase {
+	return &WebhookUseCase{
+		WebhookRepo: webhookRepo,
+		Outbox:      outbox,
+	}
+}
+
+func (wu *WebhookUseCase) EventTypes() []string {
+	return domain.WebhookEventTypes
+}
+
+// validateWebhookURL accepts absolute http and https URLs whose host resolves to public addresses
+// only. The sender checks the address again on every delivery.
+func validateWebhookURL(value string) error {
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Hostname() == "" {
+		return errors.New("webhook url must be an http or https url")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), webhookLookupTimeout)
+	defer cancel()
+	return infrastructure.ValidateWebhookHost(ctx, parsed.Hostname())
+}
+
+func (wu *WebhookUseCase) CreateWebhook(request domain.CreateWebhookRequest, user_id string) (domain.WebhookEndpoint, error) {
+	if err := validateWebhookURL(request.URL); err != nil {
+		return domain.WebhookEndpoint{}, err
+	}
+	events, err := normalizeChoices(request.Events, domain.WebhookEventTypes, "unknown event type: ")
+	if err != nil {
+		return domain.WebhookEndpoint{}, err
+	}
+	now := time.Now()
+	endpoint, err := wu.WebhookRepo.Create(domain.WebhookEndpoint{
+		URL:         request.URL,
+		Secret:      request.Secret,
+		Events:      events,
+		Description: strings.TrimSpace(request.Description),
+		Active:      true,
+		Created_At:  now,
+		Updated_At:  now,
+	})
+	if err != nil {
+		return domain.WebhookEndpoint{}, errors.New("error creating webhook")
+	}
+	return endpoint, nil
+}
+
+func (wu *WebhookUseCase) GetWebhooks(user_id string) ([]domain.WebhookEndpoint, error) {
+	endpoints, err := wu.WebhookRepo.FindAll()
+	if err != nil {
+		return nil, errors.New("error getting webhooks")
+	}
+	return endpoints, nil
+}
+
+func (wu *WebhookUseCase) GetWebhook(id string, user_id string) (domain.WebhookEndpoint, error) {
+	endpoint, err := wu.WebhookRepo.FindByID(id)
+	if err != nil {
+		return domain.WebhookEndpoint{}, errors.New("webhook not found")
+	}
+	return endpoint, nil
+}
+
+// UpdateWebhook changes the fields set in the request. A new secret applies to every delivery
+// attempted from then on, including retries of earlier events.
+func (wu *WebhookUseCase) UpdateWebhook(id string, request domain.UpdateWebhookRequest, user_id string) (domain.WebhookEndpoint, error) {
+	endpoint, err := wu.GetWebhook(id, user_id)
+	if err != nil {
+		return domain.WebhookEndpoint{}, err
+	}
+	if request.URL != nil {
+		if err := validateWebhookURL(*request.URL); err != nil {
+			return domain.WebhookEndpoint{}, err
+		}
+		endpoint.URL = *request.URL
+	}
+	if request.Secret != nil {
+		endpoint.Secret = *request.Secret
+	}
+	if request.Events != nil {
+		events, err := normalizeChoices(request.Events, domain.WebhookEventTypes, "unknown event type: ")
+		if err != nil {
+			return domain.WebhookEndpoint{}, err
+		}
+		endpoint.Events = events
+	}
+	if request.Description != nil {
+		endpoint.Description = strings.TrimSpace(*request.Description)
+	}
+	if request.Active != nil {
+		endpoint.Active = *request.Active
+	}
+	endpoint.Updated_At = time.Now()
+	if err := wu.WebhookRepo.Update(endpoint); err != nil {
+		return domain.WebhookEndpoint{}, errors.New("error updating webhook")
+	}
+	return endpoint, nil
+}
+
+func (wu *WebhookUseCase) DeleteWebhook(id string, user_id string) error {
+	if err := wu.WebhookRepo.Delete(id); err != nil {
+		return errors.New("webhook not found")
+	}
+	return nil
+}
+
+// GetDeliveries pages through the delivery log of an endpoint, newest first, optionally filtered by status.
+func (wu *WebhookUseCase) GetDeliveries(id string, status string, pageNo, pageSize string, user_id string) ([]domain.OutboxMessage, error) {
+	if _, err := wu.GetWebhook(id, user_id); err != nil {
+		return nil, err
+	}
+	pageS, pageN, err := utils.PagePaginationValidator(pageSize, pageNo)
+	if err != nil {
+		return nil, err
+	}
+	if status != "" && !slices.Contains([]string{domain.OutboxPending, domain.OutboxSending, domain.OutboxSent, domain.OutboxDead}, status) {
+		return nil, errors.New("invalid status")
+	}
+	deliveries, err := wu.Outbox.FindByWebhookEndpoint(id, status, pageN, pageS)
+	if err != nil {
+		return nil, errors.New("error getting deliveries")
+	}
+	return deliveries, nil
+}
+
+// ReplayDelivery queues a delivery of the endpoint again, whatever its status. The replay carries the
+// same event id and body, so receivers that deduplicate on the id see it only once.
+func (wu *WebhookUseCase) ReplayDelivery(id string, delivery_id string, user_id string) error {
+	endpoint, err := wu.GetWebhook(id, user_id)
+	if err != nil {
+		return err
+	}
+	message, err := wu.Outbox.FindByID(delivery_id)
+	if err != nil || message.Webhook == nil || message.Webhook.EndpointID != endpoint.ID {
+		return errors.New("delivery not found")
+	}
+	replay := *message.Webhook
+	replay.ReplayOf = message.ID
+	err = wu.Outbox.EnqueueMessage(context.Background(), domain.OutboxMessage{
+		Channel: domain.ChannelWebhook,
+		Webhook: &replay,
+	})
+	if err != nil {
+		return errors.New("error queueing replay")
+	}
+	return nil
+}